@@ -0,0 +1,85 @@
+// Package allocation decides which warehouses the items of an order are shipped from.
+package allocation
+
+import (
+	"math"
+	"slices"
+)
+
+type Strategy string
+
+const (
+	// Nearest ships from the warehouses closest to the customer first, so that orders arrive sooner. It works like MostStock when the location of the customer is not known.
+	Nearest Strategy = "nearest"
+	// MostStock ships from the warehouses with the most stock of the product first, so that stock runs out evenly.
+	MostStock Strategy = "most_stock"
+)
+
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Stock is the stock of a product in a warehouse.
+type Stock struct {
+	Location    Location
+	WarehouseID int
+	Quantity    int
+}
+
+type Allocation struct {
+	WarehouseID int `json:"warehouseId"`
+	Quantity    int `json:"quantity"`
+}
+
+// Allocate splits the quantity of a product across the warehouses that stock it. Warehouses are taken in the order of the strategy, each giving as much as it has, so that an item ships from as few warehouses as possible. Ties go to the warehouse with the lower ID. Less than the quantity is allocated if the warehouses don't have enough stock.
+func Allocate(stock []Stock, quantity int, strategy Strategy, destination *Location) []Allocation {
+	ordered := slices.Clone(stock)
+	slices.SortStableFunc(ordered, func(a, b Stock) int {
+		if strategy == Nearest && destination != nil {
+			if c := compareFloat(Distance(a.Location, *destination), Distance(b.Location, *destination)); c != 0 {
+				return c
+			}
+		}
+		if a.Quantity != b.Quantity {
+			return b.Quantity - a.Quantity
+		}
+		return a.WarehouseID - b.WarehouseID
+	})
+
+	allocations := make([]Allocation, 0)
+	for _, s := range ordered {
+		if quantity <= 0 {
+			break
+		}
+		if s.Quantity <= 0 {
+			continue
+		}
+		taken := min(s.Quantity, quantity)
+		allocations = append(allocations, Allocation{WarehouseID: s.WarehouseID, Quantity: taken})
+		quantity -= taken
+	}
+	return allocations
+}
+
+// earthRadius is the mean radius of the Earth in kilometres.
+const earthRadius = 6371
+
+// Distance returns the great-circle distance between two locations in kilometres.
+func Distance(a, b Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
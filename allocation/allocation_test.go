@@ -0,0 +1,70 @@
+package allocation_test
+
+import (
+	"testing"
+
+	"github.com/rohitxdev/go-api-starter/allocation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocate(t *testing.T) {
+	mumbai := allocation.Location{Latitude: 19.076, Longitude: 72.8777}
+	delhi := allocation.Location{Latitude: 28.6139, Longitude: 77.209}
+	bengaluru := allocation.Location{Latitude: 12.9716, Longitude: 77.5946}
+	stock := []allocation.Stock{
+		{WarehouseID: 1, Quantity: 5, Location: mumbai},
+		{WarehouseID: 2, Quantity: 10, Location: delhi},
+		{WarehouseID: 3, Quantity: 3, Location: bengaluru},
+	}
+
+	t.Run("Most stock", func(t *testing.T) {
+		allocations := allocation.Allocate(stock, 4, allocation.MostStock, &mumbai)
+		assert.Equal(t, []allocation.Allocation{{WarehouseID: 2, Quantity: 4}}, allocations)
+
+		allocations = allocation.Allocate(stock, 12, allocation.MostStock, nil)
+		assert.Equal(t, []allocation.Allocation{{WarehouseID: 2, Quantity: 10}, {WarehouseID: 1, Quantity: 2}}, allocations)
+	})
+
+	t.Run("Nearest", func(t *testing.T) {
+		pune := allocation.Location{Latitude: 18.5204, Longitude: 73.8567}
+		allocations := allocation.Allocate(stock, 4, allocation.Nearest, &pune)
+		assert.Equal(t, []allocation.Allocation{{WarehouseID: 1, Quantity: 4}}, allocations)
+
+		// Bengaluru is closer to Pune than Delhi is
+		allocations = allocation.Allocate(stock, 7, allocation.Nearest, &pune)
+		assert.Equal(t, []allocation.Allocation{{WarehouseID: 1, Quantity: 5}, {WarehouseID: 3, Quantity: 2}}, allocations)
+	})
+
+	t.Run("Nearest without a location", func(t *testing.T) {
+		allocations := allocation.Allocate(stock, 4, allocation.Nearest, nil)
+		assert.Equal(t, []allocation.Allocation{{WarehouseID: 2, Quantity: 4}}, allocations)
+	})
+
+	t.Run("Ties and empty warehouses", func(t *testing.T) {
+		stock := []allocation.Stock{
+			{WarehouseID: 3, Quantity: 2},
+			{WarehouseID: 1, Quantity: 0},
+			{WarehouseID: 2, Quantity: 2},
+		}
+		allocations := allocation.Allocate(stock, 3, allocation.MostStock, nil)
+		assert.Equal(t, []allocation.Allocation{{WarehouseID: 2, Quantity: 2}, {WarehouseID: 3, Quantity: 1}}, allocations)
+	})
+
+	t.Run("Not enough stock", func(t *testing.T) {
+		allocations := allocation.Allocate(stock, 20, allocation.MostStock, nil)
+		total := 0
+		for _, a := range allocations {
+			total += a.Quantity
+		}
+		assert.Equal(t, 18, total)
+	})
+}
+
+func TestDistance(t *testing.T) {
+	mumbai := allocation.Location{Latitude: 19.076, Longitude: 72.8777}
+	delhi := allocation.Location{Latitude: 28.6139, Longitude: 77.209}
+
+	assert.InDelta(t, 1150, allocation.Distance(mumbai, delhi), 10)
+	assert.InDelta(t, allocation.Distance(mumbai, delhi), allocation.Distance(delhi, mumbai), 1e-9)
+	assert.Zero(t, allocation.Distance(mumbai, mumbai))
+}
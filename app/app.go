@@ -16,6 +16,7 @@ import (
 	"github.com/rohitxdev/go-api-starter/database"
 	"github.com/rohitxdev/go-api-starter/email"
 	"github.com/rohitxdev/go-api-starter/handler"
+	"github.com/rohitxdev/go-api-starter/jobs"
 	"github.com/rohitxdev/go-api-starter/kvstore"
 	"github.com/rohitxdev/go-api-starter/logger"
 	"github.com/rohitxdev/go-api-starter/repo"
@@ -83,6 +84,7 @@ func Run() error {
 		BlobStore: bs,
 		Config:    cfg,
 		Email:     e,
+		Jobs:      jobs.New(logr),
 		KVStore:   kv,
 		Logger:    logr,
 		Repo:      r,
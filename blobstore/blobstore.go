@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -62,6 +63,25 @@ func (s *Store) Put(ctx context.Context, p *PutParams) (string, error) {
 	return req.URL, err
 }
 
+type UploadParams struct {
+	BucketName  string
+	FileName    string
+	ContentType string
+	// Body should be seekable, e.g. a file or a bytes.Reader, so that it can be signed without reading it into memory.
+	Body io.Reader
+}
+
+// Upload uploads a file to S3 bucket, e.g. one generated by the server. Files that clients upload themselves should use Put.
+func (s *Store) Upload(ctx context.Context, p *UploadParams) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &p.BucketName,
+		Key:         &p.FileName,
+		ContentType: &p.ContentType,
+		Body:        p.Body,
+	})
+	return err
+}
+
 /*----------------------------------- Get File From Bucket ----------------------------------- */
 
 type GetParams struct {
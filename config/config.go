@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"time"
 
@@ -46,13 +47,39 @@ type Config struct {
 	SessionSecret string `json:"sessionSecret" validate:"required"`
 	// JWTSecret is the secret key used to sign JWT tokens.
 	JWTSecret string `json:"jwtSecret" validate:"required"`
+	// BaseURL is the public URL of the API, used to build links in emails.
+	BaseURL string `json:"baseUrl"`
 	// AllowedOrigins is a list of origins that are allowed to access the API.
 	AllowedOrigins  []string      `json:"allowedOrigins"`
 	SessionDuration time.Duration `json:"sessionDuration" validate:"required"`
 	// LogInTokenExpiresIn is the duration after which the log-in token in email will expire.
 	LogInTokenExpiresIn time.Duration `json:"logInTokenExpiresIn" validate:"required"`
+	// ReservationDuration is how long stock is held for a customer once they start checking out.
+	ReservationDuration time.Duration `json:"reservationDuration"`
+	// GuestCartDuration is how long the cart of a visitor who is not logged in is kept.
+	GuestCartDuration time.Duration `json:"guestCartDuration"`
+	// AbandonedCartAfter is how long a cart has to be left untouched before its owner is reminded of it.
+	AbandonedCartAfter time.Duration `json:"abandonedCartAfter"`
+	// CartReminderCooldown is the least time between two abandoned cart reminders to the same user.
+	CartReminderCooldown time.Duration `json:"cartReminderCooldown"`
+	// IdempotencyKeyDuration is how long the response to a request with an Idempotency-Key header is kept for replaying to its retries.
+	IdempotencyKeyDuration time.Duration `json:"idempotencyKeyDuration"`
 	// SMTPPort is the port of the SMTP server.
 	SMTPPort int `json:"smtpPort" validate:"required"`
+	// TaxRate is charged on every order, in basis points, e.g. 1800 for 18%.
+	TaxRate int `json:"taxRate" validate:"min=0"`
+	// ShippingFee is charged on every order below FreeShippingThreshold, in the smallest unit of the currency.
+	ShippingFee int `json:"shippingFee" validate:"min=0"`
+	// FreeShippingThreshold is the order amount from which shipping is free. 0 means shipping is never free.
+	FreeShippingThreshold int `json:"freeShippingThreshold" validate:"min=0"`
+	// PointsEarnRate is how many loyalty points an order earns, in basis points of what is paid for its items, e.g. 100 for 1 point per 100. 0 means no points are earned.
+	PointsEarnRate int `json:"pointsEarnRate" validate:"min=0"`
+	// PointValue is what a loyalty point is worth at checkout, in the smallest unit of the currency.
+	PointValue int `json:"pointValue" validate:"min=0"`
+	// PointsExpiryMonths is how long earned loyalty points can be redeemed for.
+	PointsExpiryMonths int `json:"pointsExpiryMonths" validate:"min=0"`
+	// AllocationStrategy picks the warehouses that orders ship from: nearest, from the warehouses closest to the customer, or most_stock, from the warehouses with the most stock.
+	AllocationStrategy string `json:"allocationStrategy" validate:"oneof=nearest most_stock"`
 	// IsDev is a flag indicating whether the server is running in development mode.
 	IsDev           bool `json:"isDev"`
 	UseSecureCookie bool `json:"useSecureCookie"`
@@ -88,6 +115,14 @@ func Load() (*Config, error) {
 	if m["logInTokenExpiresIn"], err = time.ParseDuration(m["logInTokenExpiresIn"].(string)); err != nil {
 		errList = append(errList, fmt.Errorf("Failed to parse log in token expires in: %w", err))
 	}
+	// Optional durations fall back to their defaults when not set.
+	for _, key := range []string{"reservationDuration", "guestCartDuration", "abandonedCartAfter", "cartReminderCooldown", "idempotencyKeyDuration"} {
+		if value, ok := m[key].(string); ok {
+			if m[key], err = time.ParseDuration(value); err != nil {
+				errList = append(errList, fmt.Errorf("Failed to parse %s: %w", key, err))
+			}
+		}
+	}
 
 	if len(errList) > 0 {
 		return nil, errors.Join(errList...)
@@ -114,6 +149,33 @@ func Load() (*Config, error) {
 	cfg.AppVersion = AppVersion
 	cfg.BuildType = BuildType
 	cfg.IsDev = cfg.Env != "production"
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://" + net.JoinHostPort(cfg.Host, cfg.Port)
+	}
+	if cfg.ReservationDuration == 0 {
+		cfg.ReservationDuration = time.Minute * 15
+	}
+	if cfg.GuestCartDuration == 0 {
+		cfg.GuestCartDuration = time.Hour * 24 * 30
+	}
+	if cfg.AbandonedCartAfter == 0 {
+		cfg.AbandonedCartAfter = time.Hour * 24
+	}
+	if cfg.CartReminderCooldown == 0 {
+		cfg.CartReminderCooldown = time.Hour * 24 * 7
+	}
+	if cfg.IdempotencyKeyDuration == 0 {
+		cfg.IdempotencyKeyDuration = time.Hour * 24
+	}
+	if cfg.PointValue == 0 {
+		cfg.PointValue = 1
+	}
+	if cfg.PointsExpiryMonths == 0 {
+		cfg.PointsExpiryMonths = 12
+	}
+	if cfg.AllocationStrategy == "" {
+		cfg.AllocationStrategy = "most_stock"
+	}
 
 	if err = validator.New().Struct(cfg); err != nil {
 		return nil, fmt.Errorf("Failed to validate config: %w", err)
@@ -6,6 +6,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"math/big"
 )
@@ -55,6 +56,9 @@ func DecryptAES(encryptedData []byte, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("could not create GCM: %w", err)
 	}
 	nonceSize := gcm.NonceSize()
+	if len(encryptedData) < nonceSize {
+		return nil, errors.New("encrypted data is too short")
+	}
 
 	//Get nonce from encrypted data
 	nonce, cipher := encryptedData[:nonceSize], encryptedData[nonceSize:]
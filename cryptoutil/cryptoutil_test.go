@@ -20,4 +20,9 @@ func TestCryptoUtil(t *testing.T) {
 
 		assert.Equal(t, plainText, decryptedData)
 	})
+
+	t.Run("Decrypt malformed data", func(t *testing.T) {
+		_, err := cryptoutil.DecryptAES([]byte("short"), []byte("secretkey"))
+		assert.NotNil(t, err)
+	})
 }
@@ -54,20 +54,28 @@ const docTemplate = `{
                 }
             }
         },
-        "/carts": {
+        "/_/cart-reminders/stats": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get cart.",
-                "summary": "Get cart",
+                "description": "Get how many abandoned cart reminders were sent and how many of them led to an order.",
+                "summary": "Get cart reminder stats",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of days to look back, 30 by default",
+                        "name": "days",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.GetCartResponse"
+                            "$ref": "#/definitions/handler.GetCartReminderStatsResponse"
                         }
                     },
                     "401": {
@@ -79,33 +87,59 @@ const docTemplate = `{
                 }
             }
         },
-        "/carts/{productId}": {
-            "post": {
+        "/_/coupons/export": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Add to cart.",
-                "summary": "Add to cart",
+                "description": "Export the coupons created in a date range with how often they were redeemed and the discount they gave. The export is streamed, or with async set, emailed as a download link.",
+                "summary": "Export coupons",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Product ID",
-                        "name": "productId",
-                        "in": "path",
-                        "required": true
+                        "type": "string",
+                        "description": "csv (default) or json",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date (2006-01-02) or time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date (inclusive) or time (exclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Email a download link instead",
+                        "name": "async",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repo.ExportedCoupon"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
                             "$ref": "#/definitions/handler.response"
                         }
                     },
                     "400": {
-                        "description": "invalid product",
+                        "description": "invalid date range",
                         "schema": {
                             "type": "string"
                         }
@@ -117,33 +151,74 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/_/gift-cards": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Delete cart item.",
-                "summary": "Delete cart item",
+                "description": "Get all gift cards, latest first.",
+                "summary": "Get gift cards",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "productId",
-                        "in": "path",
-                        "required": true
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.response"
+                            "$ref": "#/definitions/handler.GetGiftCardsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Issue a gift card, e.g. as a goodwill gesture, optionally emailing its code to the recipient.",
+                "summary": "Issue gift card",
+                "parameters": [
+                    {
+                        "description": "Gift card",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.IssueGiftCardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GiftCardResponse"
                         }
                     },
                     "400": {
-                        "description": "invalid product",
+                        "description": "invalid request",
                         "schema": {
                             "type": "string"
                         }
@@ -153,44 +228,89 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "409": {
+                        "description": "gift card already exists",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/carts/{productId}/{quantity}": {
-            "put": {
+        "/_/gift-cards/{id}": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Update cart item quantity.",
-                "summary": "Update cart item quantity",
+                "description": "Get a gift card with every change to its balance, oldest first.",
+                "summary": "Get gift card",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "productId",
+                        "description": "Gift card ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetGiftCardResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
+                    "404": {
+                        "description": "gift card not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stop a gift card from being redeemed, e.g. when it was refunded or leaked. Its balance is written off.",
+                "summary": "Deactivate gift card",
+                "parameters": [
                     {
                         "type": "integer",
-                        "description": "Quantity",
-                        "name": "quantity",
+                        "description": "Gift card ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Reason",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.DeactivateGiftCardRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.response"
+                            "$ref": "#/definitions/handler.GiftCardResponse"
                         }
                     },
                     "400": {
-                        "description": "invalid product or quantity",
+                        "description": "gift card is not active",
                         "schema": {
                             "type": "string"
                         }
@@ -200,39 +320,63 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "404": {
+                        "description": "gift card not found",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/config": {
+        "/_/inventory/low-stock": {
             "get": {
-                "description": "Get client config.",
-                "summary": "Get config",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the products whose stock is at or below their low stock threshold, lowest stock first.",
+                "summary": "Get low stock products",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/handler.GetLowStockProductsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/coupons": {
+        "/_/inventory/reconciliation": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get available coupons.",
-                "summary": "Get available coupons",
+                "description": "Recompute the stock of every product from the inventory ledger and from its warehouses, and compare them with the stock on record.",
+                "summary": "Reconcile stock",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only return products whose stock doesn't match the ledger or warehouses",
+                        "name": "mismatchedOnly",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.GetAvailableCouponsResponse"
+                            "$ref": "#/definitions/handler.ReconcileStockResponse"
                         }
                     },
                     "401": {
@@ -244,16 +388,22 @@ const docTemplate = `{
                 }
             }
         },
-        "/coupons/all": {
+        "/_/inventory/transfers": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get all coupons.",
-                "summary": "Get all coupons",
+                "description": "Get the stock transfers between warehouses, latest first.",
+                "summary": "Get stock transfers",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only the transfers of this product",
+                        "name": "productId",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "description": "Page number",
@@ -271,7 +421,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.GetAllCouponsResponse"
+                            "$ref": "#/definitions/handler.GetStockTransfersResponse"
                         }
                     },
                     "401": {
@@ -281,22 +431,37 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/me": {
-            "get": {
+            },
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get user.",
-                "summary": "Get user",
+                "description": "Move stock of a product from one warehouse to another. The stock of the product stays the same, and both sides of the transfer are recorded in the inventory ledger.",
+                "summary": "Transfer stock",
+                "parameters": [
+                    {
+                        "description": "Transfer",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.TransferStockRequest"
+                        }
+                    }
+                ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/repo.User"
+                            "$ref": "#/definitions/handler.TransferStockResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "not enough stock in the warehouse",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "401": {
@@ -304,24 +469,48 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "404": {
+                        "description": "product or warehouse not found",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/orders": {
-            "post": {
+        "/_/orders/export": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Create order.",
-                "summary": "Create order",
+                "description": "Export the orders placed in a date range with their items, discounts and coupon codes. CSV exports have a row for every item. The export is streamed, or with async set, emailed as a download link.",
+                "summary": "Export orders",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Coupon code",
-                        "name": "couponCode",
+                        "description": "csv (default) or json",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date (2006-01-02) or time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date (inclusive) or time (exclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Email a download link instead",
+                        "name": "async",
                         "in": "query"
                     }
                 ],
@@ -329,11 +518,20 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.CreateOrderResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repo.ExportedOrder"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
                         }
                     },
                     "400": {
-                        "description": "invalid coupon",
+                        "description": "invalid date range",
                         "schema": {
                             "type": "string"
                         }
@@ -347,34 +545,29 @@ const docTemplate = `{
                 }
             }
         },
-        "/orders/all": {
+        "/_/orders/{id}/allocations": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get all orders.",
-                "summary": "Get all orders",
+                "description": "Get the warehouses that the items of an order ship from.",
+                "summary": "Get order allocations",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page size",
-                        "name": "pageSize",
-                        "in": "query"
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.GetAllOrdersResponse"
+                            "$ref": "#/definitions/handler.GetOrderAllocationsResponse"
                         }
                     },
                     "401": {
@@ -382,108 +575,5392 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "404": {
+                        "description": "order not found",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/products": {
-            "get": {
+        "/_/orders/{id}/refunds": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get products.",
-                "summary": "Get products",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                "description": "Refund an order fully, or partially by order item. Coupon discounts are spread proportionally across the refunded items. Gift cards bought with the order are deactivated, and only what is left on them is refunded. The share of the order paid with gift cards and store credit is always refunded as store credit.",
+                "summary": "Create refund",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Refund",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.GetProductsResponse"
+                            "$ref": "#/definitions/handler.CreateRefundRequest"
                         }
                     }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "handler.CreateOrderResponse": {
-            "type": "object",
-            "properties": {
-                "order": {
-                    "$ref": "#/definitions/repo.Order"
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateRefundResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid refund",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "order not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/products/{id}/low-stock-threshold": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Set the stock at or below which admins are alerted about a product. 0 turns alerts off.",
+                "summary": "Set low stock threshold",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Threshold",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SetLowStockThresholdRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/products/{id}/stock": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Restock a product or correct its stock in a warehouse by hand. Every change is recorded in the inventory ledger.",
+                "summary": "Adjust stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Stock change",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdjustStockRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdjustStockResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "not enough stock, or a restock that doesn't add stock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "product or warehouse not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "stock is held by customers checking out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/products/{id}/stock-history": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the inventory movements of a product, latest first.",
+                "summary": "Get stock history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetStockHistoryResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/products/{id}/warehouses": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the stock of a product in each warehouse, the default warehouse first. The stock of the product is their sum.",
+                "summary": "Get product stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetWarehouseStockResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/promotions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all promotions, highest priority first.",
+                "summary": "Get promotions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetPromotionsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a promotion that is applied automatically to every cart that qualifies for it: buy x get y, a percentage off a category, or a percentage off by quantity tiers. Promotions are applied highest priority first, before coupons.",
+                "summary": "Create promotion",
+                "parameters": [
+                    {
+                        "description": "Promotion",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.PromotionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.PromotionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/promotions/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replace a promotion. Set isActive to false to end it early. Orders it was already applied to are not affected.",
+                "summary": "Update promotion",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Promotion ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Promotion",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.PromotionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.PromotionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "promotion not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/returns": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the returns with a status, oldest first. By default, these are the returns waiting for approval.",
+                "summary": "Get all returns",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Status, requested by default",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetReturnsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/returns/{id}/status": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Move a return to its next status. Completing a return refunds the returned quantity and restocks it if it was found resellable on inspection.",
+                "summary": "Update return status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Return ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Status",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateReturnStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateReturnStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid status",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "return not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "return can't move to this status",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/reviews": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the reviews with a status, oldest first. By default, these are the reviews waiting for moderation.",
+                "summary": "Get reviews",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "pending (default), approved or rejected",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetReviewsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/reviews/{id}/status": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Approve or reject a review. Only approved reviews are shown and count towards the rating of the product.",
+                "summary": "Moderate review",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Review ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Status",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SetReviewStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReviewResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "review not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/reward-rules": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all reward rules with how many coupons each has issued.",
+                "summary": "Get reward rules",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetRewardRulesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a rule that issues a personal coupon for every nth order of a user, for the order that takes their total spend over a threshold, or for their first order. Rules are evaluated when an order is placed, and fire at most once per order.",
+                "summary": "Create reward rule",
+                "parameters": [
+                    {
+                        "description": "Reward rule",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.RewardRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.RewardRuleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/reward-rules/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replace a reward rule. Set isActive to false to stop it from issuing coupons. Coupons that were already issued are not affected.",
+                "summary": "Update reward rule",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Reward rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reward rule",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.RewardRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.RewardRuleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "reward rule not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the number of orders, items purchased, revenue, discounts given, average order value and coupons issued and redeemed in a date range, in total and per day or week. Amounts are in the smallest unit of the currency.",
+                "summary": "Get sales stats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start date (2006-01-02) or time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date (inclusive) or time (exclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "day (default) or week",
+                        "name": "interval",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repo.SalesStats"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid date range",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/users/{id}/store-credit": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the store credit of a user and every change to it, latest first.",
+                "summary": "Get store credit of user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetStoreCreditResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Credit the store credit account of a user.",
+                "summary": "Issue store credit",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Store credit",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.IssueStoreCreditRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.IssueStoreCreditResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "user not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/warehouses": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get every warehouse with the stock in it, the default one first.",
+                "summary": "Get warehouses",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetWarehousesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add a warehouse without any stock. Stock is added to it by restocking or transferring products to it.",
+                "summary": "Create warehouse",
+                "parameters": [
+                    {
+                        "description": "Warehouse",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateWarehouseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.WarehouseResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "warehouse with this name already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/warehouses/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Rename or move a warehouse, or make it the default one.",
+                "summary": "Update warehouse",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Warehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Warehouse",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateWarehouseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.WarehouseResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "warehouse not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "warehouse with this name already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/_/warehouses/{id}/stock": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the products in stock in a warehouse, most stock first.",
+                "summary": "Get warehouse stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Warehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetWarehouseStockResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "warehouse not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/carts": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get cart. Visitors who are not logged in get their guest cart.",
+                "summary": "Get cart",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetCartResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replace the whole cart. Lines asking for more than is in stock are clamped, and lines for products that are out of stock or don't exist are rejected; both are listed in the response.",
+                "summary": "Replace cart",
+                "parameters": [
+                    {
+                        "description": "Cart",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReplaceCartRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repo.ReplaceCartResult"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/carts/reminders": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Turn abandoned cart reminder emails on or off.",
+                "summary": "Set cart reminders",
+                "parameters": [
+                    {
+                        "description": "Preference",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SetCartRemindersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/carts/reminders/unsubscribe": {
+            "get": {
+                "description": "Unsubscribe link sent in abandoned cart reminder emails.",
+                "summary": "Unsubscribe from cart reminders",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unsubscribe token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "unsubscribed page",
+                        "schema": {
+                            "type": "html"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/carts/summary": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the totals of the cart as they would be charged if the order was placed now, with the promotions running, and optionally with a coupon applied and loyalty points spent. Products frequently bought together with the ones in the cart are recommended.",
+                "summary": "Get cart summary",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Coupon code",
+                        "name": "couponCode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Loyalty points to spend",
+                        "name": "points",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetCartSummaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid coupon or not enough points",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/carts/{productId}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add to cart. Visitors who are not logged in add to their guest cart, which is merged into their cart when they log in.",
+                "summary": "Add to cart",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "400": {
+                        "description": "product is out of stock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete cart item.",
+                "summary": "Delete cart item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid product",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/carts/{productId}/save-for-later": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Move a product from the cart to a wishlist.",
+                "summary": "Save for later",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Wishlist",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SaveForLaterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist or cart item not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/carts/{productId}/{quantity}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update cart item quantity. Use PUT /carts instead.",
+                "summary": "Update cart item quantity",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Quantity",
+                        "name": "quantity",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid product or quantity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "cart item not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/config": {
+            "get": {
+                "description": "Get client config.",
+                "summary": "Get config",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/coupons": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get available coupons.",
+                "summary": "Get available coupons",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetAvailableCouponsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a percent or fixed amount coupon, for a single user or for everyone, with optional minimum order amount, validity window and redemption limits.",
+                "summary": "Create coupon",
+                "parameters": [
+                    {
+                        "description": "Coupon",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateCouponRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateCouponResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "coupon already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/coupons/all": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all coupons.",
+                "summary": "Get all coupons",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetAllCouponsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/gift-cards/{code}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the balance of a gift card by its code.",
+                "summary": "Get gift card balance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Gift card code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GiftCardBalance"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "gift card not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/me": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get user.",
+                "summary": "Get user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repo.User"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/points": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the loyalty points balance of the user, the points that expire next, and the points ledger, latest first.",
+                "summary": "Get points",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetPointsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/store-credit": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the store credit of the user and every change to it, latest first.",
+                "summary": "Get store credit",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetStoreCreditResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create order, optionally spending loyalty points on it. Fewer points are spent if the order is worth less than them. The total can be paid, in part or in full, with a gift card and then with store credit. The points, coupons and gift cards that the order earns are returned with it. The user is emailed a receipt with the invoice attached, and the codes of the gift cards.",
+                "summary": "Create order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Coupon code",
+                        "name": "couponCode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Loyalty points to spend",
+                        "name": "points",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Gift card code",
+                        "name": "giftCardCode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Pay with store credit",
+                        "name": "useStoreCredit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Latitude of the shipping address",
+                        "name": "latitude",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude of the shipping address",
+                        "name": "longitude",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key of the order, to retry placing it safely",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid coupon, gift card or location, not enough points or empty cart",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "gift card not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "out of stock, or an order with the same idempotency key is in progress",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "idempotency key was used for a different request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/all": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all orders.",
+                "summary": "Get all orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetAllOrdersResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/checkout": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Hold the stock of every item in the cart for a limited time, so that it can't be sold to anyone else until the order is placed.",
+                "summary": "Start checkout",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.StartCheckoutResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "cart is empty",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "out of stock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Release the stock held for checkout.",
+                "summary": "Cancel checkout",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}/invoice": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Redirect to a short-lived link to the PDF invoice of the order. The invoice is issued on first request if the order doesn't have one yet. Admins can get the invoice of any order.",
+                "summary": "Get invoice",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "order can't be invoiced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "order not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}/returns": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Request the return of an item of a completed order. Photos can be added to the request until an admin approves or rejects it.",
+                "summary": "Request return",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Return",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateReturnRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReturnResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid return",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "order not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/products": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get products.",
+                "summary": "Get products",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetProductsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}/notify-me": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get an email when an out of stock product is back in stock.",
+                "summary": "Subscribe to back-in-stock notification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "400": {
+                        "description": "product is in stock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stop waiting for a product to be back in stock.",
+                "summary": "Unsubscribe from back-in-stock notification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}/notify-me/unsubscribe": {
+            "get": {
+                "description": "Unsubscribe link sent in back-in-stock emails.",
+                "summary": "Unsubscribe from back-in-stock notification by link",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unsubscribe token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "unsubscribed page",
+                        "schema": {
+                            "type": "html"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}/recommendations": {
+            "get": {
+                "description": "Get the products that are frequently bought together with the product, best first. Products that are out of stock are left out.",
+                "summary": "Get recommendations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of recommendations, 10 by default",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetRecommendationsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}/reviews": {
+            "get": {
+                "description": "Get the approved reviews of the product, latest first. The average rating and the number of ratings are part of the product.",
+                "summary": "Get product reviews",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetProductReviewsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Change the rating and text of the user's review of the product. The review is hidden again until an admin approves it.",
+                "summary": "Update review",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReviewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReviewResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "review not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Rate a product from 1 to 5 stars, with an optional text. Only customers who bought the product and didn't get a full refund for it can review it, once. The review is shown once an admin approves it.",
+                "summary": "Review product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReviewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReviewResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "product was not purchased",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "review already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete the user's review of the product.",
+                "summary": "Delete review",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "review not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/returns": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the returns of the user, latest first.",
+                "summary": "Get returns",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetReturnsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/returns/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a return with short-lived links to its photos. Admins can get any return.",
+                "summary": "Get return",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Return ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReturnResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "return not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/returns/{id}/photos": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a short-lived link to upload a photo of the returned item to. Photos can be added until the return is approved or rejected.",
+                "summary": "Add return photo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Return ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Photo",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.AddReturnPhotoRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AddReturnPhotoResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "too many photos",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "return not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "return is no longer open",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the subscriptions of the user that are not cancelled, with their items.",
+                "summary": "Get subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetSubscriptionsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Reorder the items in the cart, as they are now, every number of days or weeks. Orders are placed like any other order, and skipped, with an email to the user, when some items are out of stock.",
+                "summary": "Create subscription",
+                "parameters": [
+                    {
+                        "description": "Schedule",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "empty cart or invalid schedule",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a subscription of the user with its items.",
+                "summary": "Get subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cancel the subscription for good.",
+                "summary": "Cancel subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "subscription is already cancelled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/pause": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stop placing orders for the subscription until it is resumed.",
+                "summary": "Pause subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "subscription is not active",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/resume": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Resume a paused subscription. If its next order was due while it was paused, it is placed one interval from now.",
+                "summary": "Resume subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "subscription is not paused",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/skip": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Skip the next order of the subscription.",
+                "summary": "Skip subscription order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "subscription is cancelled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishlists": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the wishlists of the user, without their items.",
+                "summary": "Get wishlists",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetWishlistsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a named wishlist.",
+                "summary": "Create wishlist",
+                "parameters": [
+                    {
+                        "description": "Wishlist",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateWishlistRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.WishlistResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "wishlist already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishlists/shared/{token}": {
+            "get": {
+                "description": "Get a wishlist that has been shared, with its items.",
+                "summary": "Get shared wishlist",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Share token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.WishlistResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishlists/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a wishlist with its items. Each item tells if it is in stock and if its price dropped since it was added.",
+                "summary": "Get wishlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Wishlist ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.WishlistResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a wishlist and its items.",
+                "summary": "Delete wishlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Wishlist ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishlists/{id}/items/{productId}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add a product to a wishlist. Adding a product that is already in the wishlist is a no-op.",
+                "summary": "Add to wishlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Wishlist ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist or product not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove a product from a wishlist.",
+                "summary": "Remove from wishlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Wishlist ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishlists/{id}/items/{productId}/move-to-cart": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Take a product off the wishlist and put it in the cart.",
+                "summary": "Move wishlist item to cart",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Wishlist ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist or item not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "out of stock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishlists/{id}/share": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a link that lets anyone view the wishlist. Sharing again replaces the previous link.",
+                "summary": "Share wishlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Wishlist ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ShareWishlistResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Disable the link of a shared wishlist.",
+                "summary": "Stop sharing wishlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Wishlist ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.response"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "wishlist not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handler.AddReturnPhotoRequest": {
+            "type": "object",
+            "required": [
+                "contentType",
+                "id"
+            ],
+            "properties": {
+                "contentType": {
+                    "type": "string",
+                    "enum": [
+                        "image/jpeg",
+                        "image/png",
+                        "image/webp"
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.AddReturnPhotoResponse": {
+            "type": "object",
+            "properties": {
+                "photo": {
+                    "$ref": "#/definitions/repo.ReturnPhoto"
+                },
+                "uploadUrl": {
+                    "description": "UploadURL is where the photo is to be uploaded, with a PUT request with the same content type.",
+                    "type": "string"
+                }
+            }
+        },
+        "handler.AdjustStockRequest": {
+            "type": "object",
+            "required": [
+                "kind",
+                "productID",
+                "quantity"
+            ],
+            "properties": {
+                "kind": {
+                    "description": "Kind is either restock, which only adds stock, or adjustment.",
+                    "type": "string",
+                    "enum": [
+                        "restock",
+                        "adjustment"
+                    ]
+                },
+                "productID": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "description": "Quantity is the signed change in stock.",
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string",
+                    "maxLength": 512
+                },
+                "warehouseId": {
+                    "description": "WarehouseID is the warehouse whose stock changes. It defaults to the default warehouse.",
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.AdjustStockResponse": {
+            "type": "object",
+            "properties": {
+                "movement": {
+                    "$ref": "#/definitions/repo.InventoryMovement"
+                }
+            }
+        },
+        "handler.CartSummaryItem": {
+            "type": "object",
+            "properties": {
+                "discount": {
+                    "description": "Discount is what promotions took off the item.",
+                    "type": "integer"
+                },
+                "product": {
+                    "$ref": "#/definitions/repo.Product"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "subtotal": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.CreateCouponRequest": {
+            "type": "object",
+            "required": [
+                "discountType",
+                "discountValue"
+            ],
+            "properties": {
+                "code": {
+                    "description": "Code is generated if left empty.",
+                    "type": "string",
+                    "maxLength": 128
+                },
+                "discountType": {
+                    "type": "string",
+                    "enum": [
+                        "percent",
+                        "fixed"
+                    ]
+                },
+                "discountValue": {
+                    "description": "DiscountValue is a percentage for percent coupons, and an amount in the smallest unit of the currency for fixed ones.",
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "maxDiscount": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "maxRedemptions": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "maxRedemptionsPerUser": {
+                    "description": "MaxRedemptionsPerUser defaults to 1.",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "minOrderAmount": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "startsAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "description": "UserID restricts the coupon to a single user. Leave empty for a coupon anyone can redeem.",
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "handler.CreateCouponResponse": {
+            "type": "object",
+            "properties": {
+                "coupon": {
+                    "$ref": "#/definitions/repo.Coupon"
+                }
+            }
+        },
+        "handler.CreateOrderResponse": {
+            "type": "object",
+            "properties": {
+                "order": {
+                    "$ref": "#/definitions/repo.Order"
+                }
+            }
+        },
+        "handler.CreateRefundItem": {
+            "type": "object",
+            "required": [
+                "orderItemId",
+                "quantity"
+            ],
+            "properties": {
+                "orderItemId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "handler.CreateRefundRequest": {
+            "type": "object",
+            "required": [
+                "orderID"
+            ],
+            "properties": {
+                "items": {
+                    "description": "Items to be refunded. Leave empty to refund the whole order.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.CreateRefundItem"
+                    }
+                },
+                "orderID": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string",
+                    "maxLength": 512
+                },
+                "restock": {
+                    "description": "Restock puts the refunded quantities back into stock.",
+                    "type": "boolean"
+                },
+                "toStoreCredit": {
+                    "description": "ToStoreCredit refunds the whole amount as store credit instead of to the payment method.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handler.CreateRefundResponse": {
+            "type": "object",
+            "properties": {
+                "refund": {
+                    "$ref": "#/definitions/repo.Refund"
+                }
+            }
+        },
+        "handler.CreateReturnRequest": {
+            "type": "object",
+            "required": [
+                "orderID",
+                "orderItemId",
+                "quantity",
+                "reason"
+            ],
+            "properties": {
+                "comment": {
+                    "type": "string",
+                    "maxLength": 2000
+                },
+                "orderID": {
+                    "type": "integer"
+                },
+                "orderItemId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "reason": {
+                    "type": "string",
+                    "enum": [
+                        "damaged",
+                        "defective",
+                        "wrong_item",
+                        "not_as_described",
+                        "no_longer_needed",
+                        "other"
+                    ]
+                }
+            }
+        },
+        "handler.CreateSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "every",
+                "unit"
+            ],
+            "properties": {
+                "every": {
+                    "type": "integer",
+                    "maximum": 365,
+                    "minimum": 1
+                },
+                "firstOrderAt": {
+                    "description": "FirstOrderAt is when the first order is placed. It defaults to one interval from now.",
+                    "type": "string"
+                },
+                "unit": {
+                    "description": "Unit is either day or week.",
+                    "type": "string",
+                    "enum": [
+                        "day",
+                        "week"
+                    ]
+                }
+            }
+        },
+        "handler.CreateWarehouseRequest": {
+            "type": "object",
+            "required": [
+                "latitude",
+                "longitude",
+                "name"
+            ],
+            "properties": {
+                "latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 128
+                }
+            }
+        },
+        "handler.CreateWishlistRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "handler.DeactivateGiftCardRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "note": {
+                    "type": "string",
+                    "maxLength": 512
+                }
+            }
+        },
+        "handler.GetAllCouponsResponse": {
+            "type": "object",
+            "properties": {
+                "coupons": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Coupon"
+                    }
+                }
+            }
+        },
+        "handler.GetAllOrdersResponse": {
+            "type": "object",
+            "properties": {
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Order"
+                    }
+                }
+            }
+        },
+        "handler.GetAvailableCouponsResponse": {
+            "type": "object",
+            "properties": {
+                "coupons": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Coupon"
+                    }
+                }
+            }
+        },
+        "handler.GetCartReminderStatsResponse": {
+            "type": "object",
+            "properties": {
+                "conversionRate": {
+                    "description": "ConversionRate is the share of reminders that led to an order.",
+                    "type": "number"
+                },
+                "converted": {
+                    "type": "integer"
+                },
+                "days": {
+                    "type": "integer"
+                },
+                "revenue": {
+                    "description": "Revenue is the total amount of the orders placed after a reminder.",
+                    "type": "integer"
+                },
+                "sent": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.GetCartResponse": {
+            "type": "object",
+            "properties": {
+                "cart": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.CartItem"
+                    }
+                }
+            }
+        },
+        "handler.GetCartSummaryResponse": {
+            "type": "object",
+            "properties": {
+                "coupon": {
+                    "$ref": "#/definitions/repo.Coupon"
+                },
+                "discount": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.CartSummaryItem"
+                    }
+                },
+                "pointsDiscount": {
+                    "type": "integer"
+                },
+                "pointsRedeemed": {
+                    "description": "PointsRedeemed are the loyalty points that would be spent, and PointsDiscount what they are worth.",
+                    "type": "integer"
+                },
+                "promotionDiscount": {
+                    "type": "integer"
+                },
+                "promotions": {
+                    "description": "Promotions explain the promotion discount.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pricing.AppliedPromotion"
+                    }
+                },
+                "recommendations": {
+                    "description": "Recommendations are products that are frequently bought together with the ones in the cart.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Recommendation"
+                    }
+                },
+                "shipping": {
+                    "type": "integer"
+                },
+                "subtotal": {
+                    "type": "integer"
+                },
+                "tax": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.GetGiftCardResponse": {
+            "type": "object",
+            "properties": {
+                "giftCard": {
+                    "$ref": "#/definitions/repo.GiftCard"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.GiftCardTransaction"
+                    }
+                }
+            }
+        },
+        "handler.GetGiftCardsResponse": {
+            "type": "object",
+            "properties": {
+                "giftCards": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.GiftCard"
+                    }
+                }
+            }
+        },
+        "handler.GetLowStockProductsResponse": {
+            "type": "object",
+            "properties": {
+                "products": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Product"
+                    }
+                }
+            }
+        },
+        "handler.GetOrderAllocationsResponse": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.OrderAllocation"
+                    }
+                }
+            }
+        },
+        "handler.GetPointsResponse": {
+            "type": "object",
+            "properties": {
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.PointsEntry"
+                    }
+                },
+                "pointValue": {
+                    "description": "PointValue is what a point is worth at checkout, in the smallest unit of the currency.",
+                    "type": "integer"
+                },
+                "points": {
+                    "$ref": "#/definitions/repo.PointsBalance"
+                }
+            }
+        },
+        "handler.GetProductReviewsResponse": {
+            "type": "object",
+            "properties": {
+                "reviews": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Review"
+                    }
+                }
+            }
+        },
+        "handler.GetProductsResponse": {
+            "type": "object",
+            "properties": {
+                "products": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Product"
+                    }
+                }
+            }
+        },
+        "handler.GetPromotionsResponse": {
+            "type": "object",
+            "properties": {
+                "promotions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Promotion"
+                    }
+                }
+            }
+        },
+        "handler.GetRecommendationsResponse": {
+            "type": "object",
+            "properties": {
+                "recommendations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Recommendation"
+                    }
+                }
+            }
+        },
+        "handler.GetReturnsResponse": {
+            "type": "object",
+            "properties": {
+                "returns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Return"
+                    }
+                }
+            }
+        },
+        "handler.GetReviewsResponse": {
+            "type": "object",
+            "properties": {
+                "reviews": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Review"
+                    }
+                }
+            }
+        },
+        "handler.GetRewardRulesResponse": {
+            "type": "object",
+            "properties": {
+                "rewardRules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.RewardRule"
+                    }
+                }
+            }
+        },
+        "handler.GetStockHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "movements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.InventoryMovement"
+                    }
+                }
+            }
+        },
+        "handler.GetStockTransfersResponse": {
+            "type": "object",
+            "properties": {
+                "transfers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.StockTransfer"
+                    }
+                }
+            }
+        },
+        "handler.GetStoreCreditResponse": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.StoreCreditTransaction"
+                    }
+                }
+            }
+        },
+        "handler.GetSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "subscriptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Subscription"
+                    }
+                }
+            }
+        },
+        "handler.GetWarehouseStockResponse": {
+            "type": "object",
+            "properties": {
+                "stock": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.WarehouseStock"
+                    }
+                }
+            }
+        },
+        "handler.GetWarehousesResponse": {
+            "type": "object",
+            "properties": {
+                "warehouses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Warehouse"
+                    }
+                }
+            }
+        },
+        "handler.GetWishlistsResponse": {
+            "type": "object",
+            "properties": {
+                "wishlists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Wishlist"
+                    }
+                }
+            }
+        },
+        "handler.GiftCardBalance": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "isActive": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handler.GiftCardResponse": {
+            "type": "object",
+            "properties": {
+                "giftCard": {
+                    "$ref": "#/definitions/repo.GiftCard"
+                }
+            }
+        },
+        "handler.IssueGiftCardRequest": {
+            "type": "object",
+            "required": [
+                "balance"
+            ],
+            "properties": {
+                "balance": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "code": {
+                    "description": "Code is generated if left empty.",
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string",
+                    "maxLength": 512
+                },
+                "recipientEmail": {
+                    "description": "RecipientEmail is emailed the code of the gift card, if set.",
+                    "type": "string"
+                }
+            }
+        },
+        "handler.IssueStoreCreditRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "userID"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "note": {
+                    "type": "string",
+                    "maxLength": 512
+                },
+                "userID": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.IssueStoreCreditResponse": {
+            "type": "object",
+            "properties": {
+                "transaction": {
+                    "$ref": "#/definitions/repo.StoreCreditTransaction"
+                }
+            }
+        },
+        "handler.PromotionRequest": {
+            "type": "object",
+            "required": [
+                "kind",
+                "name"
+            ],
+            "properties": {
+                "buyQuantity": {
+                    "description": "BuyQuantity and GetQuantity are required for buy_x_get_y promotions. GetPercent is how much is taken off the units got, 100 for free.",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "category": {
+                    "description": "Category is required for category_percent promotions.",
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "endsAt": {
+                    "type": "string"
+                },
+                "getPercent": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0
+                },
+                "getQuantity": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "isActive": {
+                    "type": "boolean"
+                },
+                "isStackable": {
+                    "description": "IsStackable defaults to true. A product discounted by a promotion that isn't stackable gets no other promotion.",
+                    "type": "boolean"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "buy_x_get_y",
+                        "category_percent",
+                        "quantity_tiers"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 128
+                },
+                "percent": {
+                    "description": "Percent is required for category_percent promotions.",
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "productIds": {
+                    "description": "ProductIDs limits buy_x_get_y and quantity_tiers promotions to these products. Leave empty for every product.",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "startsAt": {
+                    "type": "string"
+                },
+                "tiers": {
+                    "description": "Tiers are required for quantity_tiers promotions.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.PromotionTier"
+                    }
+                }
+            }
+        },
+        "handler.PromotionResponse": {
+            "type": "object",
+            "properties": {
+                "promotion": {
+                    "$ref": "#/definitions/repo.Promotion"
+                }
+            }
+        },
+        "handler.PromotionTier": {
+            "type": "object",
+            "required": [
+                "minQuantity",
+                "percent"
+            ],
+            "properties": {
+                "minQuantity": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "percent": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                }
+            }
+        },
+        "handler.ReconcileStockResponse": {
+            "type": "object",
+            "properties": {
+                "products": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.StockReconciliation"
+                    }
+                }
+            }
+        },
+        "handler.ReplaceCartItem": {
+            "type": "object",
+            "required": [
+                "productId",
+                "quantity"
+            ],
+            "properties": {
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "handler.ReplaceCartRequest": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "items": {
+                        "$ref": "#/definitions/handler.ReplaceCartItem"
+                    }
+                }
+            }
+        },
+        "handler.ReturnResponse": {
+            "type": "object",
+            "properties": {
+                "return": {
+                    "$ref": "#/definitions/repo.Return"
+                }
+            }
+        },
+        "handler.ReviewRequest": {
+            "type": "object",
+            "required": [
+                "productID",
+                "rating"
+            ],
+            "properties": {
+                "body": {
+                    "type": "string",
+                    "maxLength": 5000
+                },
+                "productID": {
+                    "type": "integer"
+                },
+                "rating": {
+                    "type": "integer",
+                    "maximum": 5,
+                    "minimum": 1
+                }
+            }
+        },
+        "handler.ReviewResponse": {
+            "type": "object",
+            "properties": {
+                "review": {
+                    "$ref": "#/definitions/repo.Review"
+                }
+            }
+        },
+        "handler.RewardRuleRequest": {
+            "type": "object",
+            "required": [
+                "discountType",
+                "discountValue",
+                "kind",
+                "name"
+            ],
+            "properties": {
+                "couponValidDays": {
+                    "description": "CouponValidDays is how long the issued coupon can be redeemed for. Leave empty for coupons that don't expire.",
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "discountType": {
+                    "type": "string",
+                    "enum": [
+                        "percent",
+                        "fixed"
+                    ]
+                },
+                "discountValue": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "isActive": {
+                    "type": "boolean"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "every_nth_order",
+                        "spend_threshold",
+                        "first_order"
+                    ]
+                },
+                "maxDiscount": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 128
+                },
+                "orderInterval": {
+                    "description": "OrderInterval is required for every_nth_order rules.",
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "spendThreshold": {
+                    "description": "SpendThreshold is required for spend_threshold rules.",
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "handler.RewardRuleResponse": {
+            "type": "object",
+            "properties": {
+                "rewardRule": {
+                    "$ref": "#/definitions/repo.RewardRule"
+                }
+            }
+        },
+        "handler.SaveForLaterRequest": {
+            "type": "object",
+            "required": [
+                "productID",
+                "wishlistId"
+            ],
+            "properties": {
+                "productID": {
+                    "type": "integer"
+                },
+                "wishlistId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.SetCartRemindersRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handler.SetLowStockThresholdRequest": {
+            "type": "object",
+            "required": [
+                "productID",
+                "threshold"
+            ],
+            "properties": {
+                "productID": {
+                    "type": "integer"
+                },
+                "threshold": {
+                    "description": "Threshold is the stock at or below which admins are alerted. 0 turns alerts off.",
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "handler.SetReviewStatusRequest": {
+            "type": "object",
+            "required": [
+                "id",
+                "status"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "approved",
+                        "rejected"
+                    ]
+                }
+            }
+        },
+        "handler.ShareWishlistResponse": {
+            "type": "object",
+            "properties": {
+                "shareToken": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.StartCheckoutResponse": {
+            "type": "object",
+            "properties": {
+                "reservations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Reservation"
+                    }
+                }
+            }
+        },
+        "handler.SubscriptionResponse": {
+            "type": "object",
+            "properties": {
+                "subscription": {
+                    "$ref": "#/definitions/repo.Subscription"
+                }
+            }
+        },
+        "handler.TransferStockRequest": {
+            "type": "object",
+            "required": [
+                "fromWarehouseId",
+                "productId",
+                "quantity",
+                "toWarehouseId"
+            ],
+            "properties": {
+                "fromWarehouseId": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "reason": {
+                    "type": "string",
+                    "maxLength": 512
+                },
+                "toWarehouseId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.TransferStockResponse": {
+            "type": "object",
+            "properties": {
+                "transfer": {
+                    "$ref": "#/definitions/repo.StockTransfer"
+                }
+            }
+        },
+        "handler.UpdateReturnStatusRequest": {
+            "type": "object",
+            "required": [
+                "id",
+                "status"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "isResellable": {
+                    "description": "IsResellable is required for inspected. Resellable items are put back into stock when the return is completed.",
+                    "type": "boolean"
+                },
+                "note": {
+                    "type": "string",
+                    "maxLength": 512
+                },
+                "status": {
+                    "description": "Status moves requested returns to approved or rejected, approved ones to received, received ones to inspected and inspected ones to completed.",
+                    "type": "string",
+                    "enum": [
+                        "approved",
+                        "rejected",
+                        "received",
+                        "inspected",
+                        "completed"
+                    ]
+                }
+            }
+        },
+        "handler.UpdateReturnStatusResponse": {
+            "type": "object",
+            "properties": {
+                "refund": {
+                    "description": "Refund is set when the return is completed.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/repo.Refund"
+                        }
+                    ]
+                },
+                "return": {
+                    "$ref": "#/definitions/repo.Return"
+                }
+            }
+        },
+        "handler.UpdateWarehouseRequest": {
+            "type": "object",
+            "required": [
+                "id",
+                "latitude",
+                "longitude",
+                "name"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "makeDefault": {
+                    "description": "MakeDefault makes the warehouse the one that takes the stock products are created with, in place of the current one.",
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 128
+                }
+            }
+        },
+        "handler.WarehouseResponse": {
+            "type": "object",
+            "properties": {
+                "warehouse": {
+                    "$ref": "#/definitions/repo.Warehouse"
+                }
+            }
+        },
+        "handler.WishlistResponse": {
+            "type": "object",
+            "properties": {
+                "wishlist": {
+                    "$ref": "#/definitions/repo.Wishlist"
+                }
+            }
+        },
+        "handler.response": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "pricing.AppliedPromotion": {
+            "type": "object",
+            "properties": {
+                "discount": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "productIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "pricing.Tier": {
+            "type": "object",
+            "properties": {
+                "minQuantity": {
+                    "type": "integer"
+                },
+                "percent": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.CartItem": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.CartLineIssue": {
+            "type": "object",
+            "properties": {
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "description": "Quantity is what was put in the cart instead. It is 0 for rejected lines.",
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requestedQuantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.Coupon": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "discountType": {
+                    "description": "DiscountType is either CouponPercent or CouponFixed.",
+                    "type": "string"
+                },
+                "discountValue": {
+                    "description": "DiscountValue is a percentage for percent coupons, and an amount in the smallest unit of the currency for fixed ones.",
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "maxDiscount": {
+                    "description": "MaxDiscount caps the discount of percent coupons.",
+                    "type": "integer"
+                },
+                "maxRedemptions": {
+                    "description": "MaxRedemptions is how many times the coupon can be redeemed in total. nil means no limit.",
+                    "type": "integer"
+                },
+                "maxRedemptionsPerUser": {
+                    "type": "integer"
+                },
+                "minOrderAmount": {
+                    "type": "integer"
+                },
+                "redemptionCount": {
+                    "type": "integer"
+                },
+                "startsAt": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "description": "UserID is the only user who can redeem the coupon. It is nil for coupons anyone can redeem.",
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.ExportedCoupon": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "discountType": {
+                    "description": "DiscountType is either CouponPercent or CouponFixed.",
+                    "type": "string"
+                },
+                "discountValue": {
+                    "description": "DiscountValue is a percentage for percent coupons, and an amount in the smallest unit of the currency for fixed ones.",
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "maxDiscount": {
+                    "description": "MaxDiscount caps the discount of percent coupons.",
+                    "type": "integer"
+                },
+                "maxRedemptions": {
+                    "description": "MaxRedemptions is how many times the coupon can be redeemed in total. nil means no limit.",
+                    "type": "integer"
+                },
+                "maxRedemptionsPerUser": {
+                    "type": "integer"
+                },
+                "minOrderAmount": {
+                    "type": "integer"
+                },
+                "redemptionCount": {
+                    "type": "integer"
+                },
+                "startsAt": {
+                    "type": "string"
+                },
+                "totalDiscount": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "description": "UserID is the only user who can redeem the coupon. It is nil for coupons anyone can redeem.",
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.ExportedOrder": {
+            "type": "object",
+            "properties": {
+                "couponCode": {
+                    "type": "string"
+                },
+                "couponId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "discountedAmount": {
+                    "type": "integer"
+                },
+                "giftCardAmount": {
+                    "description": "GiftCardAmount and StoreCreditAmount are the parts of the total paid with a gift card and store credit. The rest is paid by the customer.",
+                    "type": "integer"
+                },
+                "giftCards": {
+                    "description": "GiftCards are the gift cards bought with the order. It is only set when the order is placed.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.GiftCard"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.ExportedOrderItem"
+                    }
+                },
+                "pointsDiscount": {
+                    "type": "integer"
+                },
+                "pointsEarned": {
+                    "description": "PointsEarned are the loyalty points the order earned. It is only set when the order is placed.",
+                    "type": "integer"
+                },
+                "pointsRedeemed": {
+                    "description": "PointsRedeemed are the loyalty points spent on the order, and PointsDiscount what they were worth.",
+                    "type": "integer"
+                },
+                "promotionDiscount": {
+                    "description": "PromotionDiscount is what promotions took off the order, and DiscountedAmount what the coupon did.",
+                    "type": "integer"
+                },
+                "promotions": {
+                    "description": "Promotions explain the promotion discount.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pricing.AppliedPromotion"
+                    }
+                },
+                "refundedAmount": {
+                    "type": "integer"
+                },
+                "rewards": {
+                    "description": "Rewards are the coupons the order earned. It is only set when the order is placed.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Coupon"
+                    }
+                },
+                "shippingAmount": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "storeCreditAmount": {
+                    "type": "integer"
+                },
+                "taxAmount": {
+                    "type": "integer"
+                },
+                "totalAmount": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userEmail": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.ExportedOrderItem": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "discount": {
+                    "description": "Discount is what promotions took off the item.",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "orderId": {
+                    "type": "integer"
+                },
+                "price": {
+                    "description": "Price is the unit price of the product at the time of purchase.",
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "productName": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "refundedQuantity": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "repo.GiftCard": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "initialBalance": {
+                    "type": "integer"
+                },
+                "isActive": {
+                    "type": "boolean"
+                },
+                "issuedBy": {
+                    "type": "integer"
+                },
+                "orderId": {
+                    "type": "integer"
+                },
+                "purchasedBy": {
+                    "description": "PurchasedBy and OrderID are set for gift cards that were bought, and IssuedBy for the ones issued by an admin.",
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "repo.GiftCardTransaction": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "balanceAfter": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "integer"
+                },
+                "giftCardId": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "orderId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.InventoryMovement": {
+            "type": "object",
+            "properties": {
+                "actorId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "orderId": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "description": "Quantity is the signed change in stock.",
+                    "type": "integer"
+                },
+                "quantityAfter": {
+                    "description": "QuantityAfter is the stock left after the movement across all warehouses. It is nil for reservations, as they don't change the stock.",
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "warehouseId": {
+                    "description": "WarehouseID is the warehouse whose stock changed. It is nil for reservations, as they hold stock of the product as a whole.",
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.Order": {
+            "type": "object",
+            "properties": {
+                "couponCode": {
+                    "type": "string"
+                },
+                "couponId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "discountedAmount": {
+                    "type": "integer"
+                },
+                "giftCardAmount": {
+                    "description": "GiftCardAmount and StoreCreditAmount are the parts of the total paid with a gift card and store credit. The rest is paid by the customer.",
+                    "type": "integer"
+                },
+                "giftCards": {
+                    "description": "GiftCards are the gift cards bought with the order. It is only set when the order is placed.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.GiftCard"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "pointsDiscount": {
+                    "type": "integer"
+                },
+                "pointsEarned": {
+                    "description": "PointsEarned are the loyalty points the order earned. It is only set when the order is placed.",
+                    "type": "integer"
+                },
+                "pointsRedeemed": {
+                    "description": "PointsRedeemed are the loyalty points spent on the order, and PointsDiscount what they were worth.",
+                    "type": "integer"
+                },
+                "promotionDiscount": {
+                    "description": "PromotionDiscount is what promotions took off the order, and DiscountedAmount what the coupon did.",
+                    "type": "integer"
+                },
+                "promotions": {
+                    "description": "Promotions explain the promotion discount.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pricing.AppliedPromotion"
+                    }
+                },
+                "refundedAmount": {
+                    "type": "integer"
+                },
+                "rewards": {
+                    "description": "Rewards are the coupons the order earned. It is only set when the order is placed.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.Coupon"
+                    }
+                },
+                "shippingAmount": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "storeCreditAmount": {
+                    "type": "integer"
+                },
+                "taxAmount": {
+                    "type": "integer"
+                },
+                "totalAmount": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.OrderAllocation": {
+            "type": "object",
+            "properties": {
+                "orderItemId": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "warehouseId": {
+                    "type": "integer"
+                },
+                "warehouseName": {
+                    "type": "string"
+                }
+            }
+        },
+        "repo.PointsBalance": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "expiringPoints": {
+                    "type": "integer"
+                },
+                "nextExpiryAt": {
+                    "description": "NextExpiryAt is when ExpiringPoints expire. It is nil if no points are going to expire.",
+                    "type": "string"
+                }
+            }
+        },
+        "repo.PointsEntry": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "orderId": {
+                    "type": "integer"
+                },
+                "points": {
+                    "description": "Points are positive for earn entries and negative for the rest.",
+                    "type": "integer"
                 }
             }
         },
-        "handler.GetAllCouponsResponse": {
+        "repo.Product": {
             "type": "object",
             "properties": {
-                "coupons": {
+                "averageRating": {
+                    "description": "AverageRating is the average of the approved reviews, rounded to one decimal. It is 0 for products without any.",
+                    "type": "number"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "imageUrl": {
+                    "type": "string"
+                },
+                "isGiftCard": {
+                    "description": "IsGiftCard is set for products that are bought as gift cards worth their price.",
+                    "type": "boolean"
+                },
+                "lowStockThreshold": {
+                    "description": "LowStockThreshold is the stock at or below which admins are alerted. 0 turns alerts off.",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "description": "Price is in the smallest unit of the currency",
+                    "type": "integer"
+                },
+                "quantityAvailable": {
+                    "description": "QuantityAvailable is the quantity left minus what is held by other customers' checkouts.",
+                    "type": "integer"
+                },
+                "quantityLeft": {
+                    "description": "QuantityLeft is the stock across all warehouses.",
+                    "type": "integer"
+                },
+                "ratingCount": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "warehouseCount": {
+                    "description": "WarehouseCount is how many warehouses have the product in stock.",
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.Promotion": {
+            "type": "object",
+            "properties": {
+                "buyQuantity": {
+                    "type": "integer"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "endsAt": {
+                    "type": "string"
+                },
+                "getPercent": {
+                    "type": "integer"
+                },
+                "getQuantity": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isActive": {
+                    "type": "boolean"
+                },
+                "isStackable": {
+                    "type": "boolean"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "percent": {
+                    "type": "integer"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "productIds": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/repo.Coupon"
+                        "type": "integer"
+                    }
+                },
+                "startsAt": {
+                    "type": "string"
+                },
+                "tiers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pricing.Tier"
                     }
+                },
+                "updatedAt": {
+                    "type": "string"
                 }
             }
         },
-        "handler.GetAllOrdersResponse": {
+        "repo.Recommendation": {
             "type": "object",
             "properties": {
-                "orders": {
+                "price": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "productName": {
+                    "type": "string"
+                },
+                "score": {
+                    "description": "Score ranks the recommendations, higher first. For a single product, it is the share of its orders that also had the recommended one.",
+                    "type": "number"
+                }
+            }
+        },
+        "repo.Refund": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isRestocked": {
+                    "type": "boolean"
+                },
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/repo.Order"
+                        "$ref": "#/definitions/repo.RefundItem"
+                    }
+                },
+                "orderId": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "storeCreditAmount": {
+                    "description": "StoreCreditAmount is the part of the amount that was credited to the store credit of the customer. The rest goes back to their payment method.",
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "repo.RefundItem": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "orderItemId": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "refundId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.ReplaceCartResult": {
+            "type": "object",
+            "properties": {
+                "cart": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.CartItem"
+                    }
+                },
+                "clamped": {
+                    "description": "Clamped lines were put in the cart with less quantity than asked for.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.CartLineIssue"
+                    }
+                },
+                "rejected": {
+                    "description": "Rejected lines were left out of the cart.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.CartLineIssue"
+                    }
+                }
+            }
+        },
+        "repo.Reservation": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.Return": {
+            "type": "object",
+            "properties": {
+                "adminNote": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isResellable": {
+                    "description": "IsResellable is set when the item is inspected. Resellable items are restocked when the return is completed.",
+                    "type": "boolean"
+                },
+                "orderId": {
+                    "type": "integer"
+                },
+                "orderItemId": {
+                    "type": "integer"
+                },
+                "photos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.ReturnPhoto"
                     }
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "refundId": {
+                    "description": "RefundID is set when the return is completed.",
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.ReturnPhoto": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "url": {
+                    "description": "URL is a short-lived link to the photo, set by the handler.",
+                    "type": "string"
+                }
+            }
+        },
+        "repo.Review": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "orderId": {
+                    "description": "OrderID is the order in which the user bought the product.",
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "rating": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "Status is ReviewPending until an admin approves or rejects the review. Only approved reviews are shown with the product.",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
                 }
             }
         },
-        "handler.GetAvailableCouponsResponse": {
+        "repo.RewardRule": {
             "type": "object",
             "properties": {
-                "coupons": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/repo.Coupon"
-                    }
+                "couponValidDays": {
+                    "description": "CouponValidDays is how long the issued coupon can be redeemed for. nil means it doesn't expire.",
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "discountType": {
+                    "type": "string"
+                },
+                "discountValue": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isActive": {
+                    "type": "boolean"
+                },
+                "issuedCount": {
+                    "description": "IssuedCount is how many coupons the rule has issued.",
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "maxDiscount": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "orderInterval": {
+                    "type": "integer"
+                },
+                "spendThreshold": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
                 }
             }
         },
-        "handler.GetCartResponse": {
+        "repo.SalesStats": {
             "type": "object",
             "properties": {
-                "cart": {
+                "averageOrderValue": {
+                    "type": "integer"
+                },
+                "couponsIssued": {
+                    "description": "CouponsIssued are the coupons created in the range, and CouponsRedeemed how often any coupon was redeemed in it.",
+                    "type": "integer"
+                },
+                "couponsRedeemed": {
+                    "type": "integer"
+                },
+                "discount": {
+                    "type": "integer"
+                },
+                "itemsPurchased": {
+                    "type": "integer"
+                },
+                "orders": {
+                    "type": "integer"
+                },
+                "periods": {
+                    "description": "Periods are the sales of every day or week in the range that had any.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/repo.CartItem"
+                        "$ref": "#/definitions/repo.SalesStatsPeriod"
                     }
+                },
+                "refundedAmount": {
+                    "type": "integer"
+                },
+                "revenue": {
+                    "type": "integer"
                 }
             }
         },
-        "handler.GetProductsResponse": {
+        "repo.SalesStatsPeriod": {
             "type": "object",
             "properties": {
-                "products": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/repo.Product"
-                    }
+                "discount": {
+                    "description": "Discount is what promotions, coupons and loyalty points took off the orders.",
+                    "type": "integer"
+                },
+                "itemsPurchased": {
+                    "type": "integer"
+                },
+                "orders": {
+                    "type": "integer"
+                },
+                "refundedAmount": {
+                    "type": "integer"
+                },
+                "revenue": {
+                    "description": "Revenue is what the orders were worth after discounts, and RefundedAmount what was refunded of it since.",
+                    "type": "integer"
+                },
+                "start": {
+                    "description": "Start is the first day of the period, e.g. 2026-10-12. Weeks start on Monday.",
+                    "type": "string"
                 }
             }
         },
-        "handler.response": {
+        "repo.StockReconciliation": {
             "type": "object",
             "properties": {
-                "message": {
+                "isConsistent": {
+                    "type": "boolean"
+                },
+                "ledgerQuantity": {
+                    "description": "LedgerQuantity is the stock as recomputed from the inventory ledger.",
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "productName": {
                     "type": "string"
+                },
+                "quantityLeft": {
+                    "type": "integer"
+                },
+                "warehouseQuantity": {
+                    "description": "WarehouseQuantity is the stock as added up from the warehouses.",
+                    "type": "integer"
                 }
             }
         },
-        "repo.CartItem": {
+        "repo.StockTransfer": {
             "type": "object",
             "properties": {
                 "createdAt": {
                     "type": "string"
                 },
+                "createdBy": {
+                    "type": "integer"
+                },
+                "fromWarehouseId": {
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -493,61 +5970,78 @@ const docTemplate = `{
                 "quantity": {
                     "type": "integer"
                 },
-                "updatedAt": {
+                "reason": {
                     "type": "string"
                 },
-                "userId": {
+                "toWarehouseId": {
                     "type": "integer"
                 }
             }
         },
-        "repo.Coupon": {
+        "repo.StoreCreditTransaction": {
             "type": "object",
             "properties": {
-                "code": {
-                    "type": "string"
+                "amount": {
+                    "type": "integer"
+                },
+                "balanceAfter": {
+                    "type": "integer"
                 },
                 "createdAt": {
                     "type": "string"
                 },
-                "discountPercent": {
+                "createdBy": {
                     "type": "integer"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "isUsed": {
-                    "type": "boolean"
+                "kind": {
+                    "type": "string"
                 },
-                "updatedAt": {
+                "note": {
                     "type": "string"
                 },
+                "orderId": {
+                    "type": "integer"
+                },
+                "refundId": {
+                    "type": "integer"
+                },
                 "userId": {
                     "type": "integer"
                 }
             }
         },
-        "repo.Order": {
+        "repo.Subscription": {
             "type": "object",
             "properties": {
-                "couponId": {
-                    "type": "integer"
-                },
                 "createdAt": {
                     "type": "string"
                 },
-                "discountedAmount": {
+                "id": {
                     "type": "integer"
                 },
-                "id": {
+                "intervalDays": {
                     "type": "integer"
                 },
-                "status": {
-                    "type": "string"
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.SubscriptionItem"
+                    }
                 },
-                "totalAmount": {
+                "lastOrderId": {
+                    "description": "LastOrderID is the order the subscription placed last.",
                     "type": "integer"
                 },
+                "nextOrderAt": {
+                    "description": "NextOrderAt is when the next order is due. Paused subscriptions catch up from the time they are resumed.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
                 "updatedAt": {
                     "type": "string"
                 },
@@ -556,30 +6050,17 @@ const docTemplate = `{
                 }
             }
         },
-        "repo.Product": {
+        "repo.SubscriptionItem": {
             "type": "object",
             "properties": {
-                "createdAt": {
-                    "type": "string"
-                },
-                "id": {
+                "productId": {
                     "type": "integer"
                 },
-                "imageUrl": {
-                    "type": "string"
-                },
-                "name": {
+                "productName": {
                     "type": "string"
                 },
-                "price": {
-                    "description": "Price is in the smallest unit of the currency",
-                    "type": "integer"
-                },
-                "quantityLeft": {
+                "quantity": {
                     "type": "integer"
-                },
-                "updatedAt": {
-                    "type": "string"
                 }
             }
         },
@@ -613,6 +6094,9 @@ const docTemplate = `{
                 "isVerified": {
                     "type": "boolean"
                 },
+                "passwordHash": {
+                    "type": "string"
+                },
                 "phoneNumber": {
                     "type": "string"
                 },
@@ -623,6 +6107,123 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "repo.Warehouse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isDefault": {
+                    "description": "IsDefault is set for the warehouse that takes the stock products are created with, and stock that is restocked without a warehouse of its own.",
+                    "type": "boolean"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "description": "Quantity is the stock of all products in the warehouse.",
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "repo.WarehouseStock": {
+            "type": "object",
+            "properties": {
+                "productId": {
+                    "type": "integer"
+                },
+                "productName": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "warehouseId": {
+                    "type": "integer"
+                },
+                "warehouseName": {
+                    "type": "string"
+                }
+            }
+        },
+        "repo.Wishlist": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repo.WishlistItem"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "shareToken": {
+                    "description": "ShareToken lets anyone with the link view the wishlist. It is nil if the wishlist is not shared.",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "repo.WishlistItem": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isInStock": {
+                    "type": "boolean"
+                },
+                "isPriceDropped": {
+                    "type": "boolean"
+                },
+                "price": {
+                    "description": "Price is the current price of the product.",
+                    "type": "integer"
+                },
+                "priceWhenAdded": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "integer"
+                },
+                "productName": {
+                    "type": "string"
+                },
+                "wishlistId": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
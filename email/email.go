@@ -62,8 +62,14 @@ type BaseOpts struct {
 	NoStack bool
 }
 
-// 'send' sends an email with raw content of the specified MIME type.
-func (c *Client) send(opts *BaseOpts, mimeType string, body string, attachments ...Attachment) error {
+// part is a version of the body of an email.
+type part struct {
+	mimeType string
+	body     string
+}
+
+// 'send' sends an email with raw content. The first part is the body, and the rest are alternatives to it, least preferred first.
+func (c *Client) send(opts *BaseOpts, parts []part, attachments ...Attachment) error {
 	msg := gomail.NewMessage()
 
 	msg.SetHeaders(map[string][]string{
@@ -85,7 +91,10 @@ func (c *Client) send(opts *BaseOpts, mimeType string, body string, attachments
 		msg.SetHeader("Bcc", opts.Bcc...)
 	}
 
-	msg.SetBody(mimeType, body)
+	msg.SetBody(parts[0].mimeType, parts[0].body)
+	for _, alternative := range parts[1:] {
+		msg.AddAlternative(alternative.mimeType, alternative.body)
+	}
 
 	for _, attachment := range attachments {
 		if attachment.ContentType == "" {
@@ -111,15 +120,36 @@ func (c *Client) send(opts *BaseOpts, mimeType string, body string, attachments
 
 // SendHtml sends an HTML email using a template.
 func (c *Client) SendHTML(opts *BaseOpts, templateName string, data map[string]any, attachments ...Attachment) error {
+	body, err := c.execute(templateName, data)
+	if err != nil {
+		return err
+	}
+	return c.send(opts, []part{{"text/html", body}}, attachments...)
+}
+
+// SendHTMLWithText sends an HTML email with a plain text alternative, for email clients that don't show HTML. Both are rendered from templates with the same data.
+func (c *Client) SendHTMLWithText(opts *BaseOpts, htmlTemplateName string, textTemplateName string, data map[string]any, attachments ...Attachment) error {
+	text, err := c.execute(textTemplateName, data)
+	if err != nil {
+		return err
+	}
+	html, err := c.execute(htmlTemplateName, data)
+	if err != nil {
+		return err
+	}
+	return c.send(opts, []part{{"text/plain", text}, {"text/html", html}}, attachments...)
+}
+
+func (c *Client) execute(templateName string, data map[string]any) (string, error) {
 	var buf bytes.Buffer
 	if err := c.templates.ExecuteTemplate(&buf, templateName, data); err != nil {
 		// '%q' prints the template name in quotes
-		return fmt.Errorf("Failed to execute template %q: %w", templateName, err)
+		return "", fmt.Errorf("Failed to execute template %q: %w", templateName, err)
 	}
-	return c.send(opts, "text/html", buf.String(), attachments...)
+	return buf.String(), nil
 }
 
 // SendText sends a plain text email.
 func (c *Client) SendText(opts *BaseOpts, body string, attachments ...Attachment) error {
-	return c.send(opts, "text/plain", body, attachments...)
+	return c.send(opts, []part{{"text/plain", body}}, attachments...)
 }
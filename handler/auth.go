@@ -59,6 +59,7 @@ func (h *Handler) LogIn(c echo.Context) error {
 	if _, err = CreateSession(c, h.Config.SessionDuration, user.ID, h.Config.UseSecureCookie); err != nil {
 		return err
 	}
+	h.mergeGuestCart(c, user.ID)
 	return c.JSON(http.StatusOK, response{Message: "Logged in successfully"})
 }
 
@@ -90,11 +91,9 @@ func (h *Handler) SignUp(c echo.Context) error {
 	if err = h.Repo.SetIsVerified(c.Request().Context(), userID, true); err != nil {
 		return err
 	}
-	if _, err = h.Repo.CreateCoupon(c.Request().Context(), userID, "UNIBLOX10", 10); err != nil {
-		return err
-	}
 	if _, err = CreateSession(c, h.Config.SessionDuration, userID, h.Config.UseSecureCookie); err != nil {
 		return err
 	}
+	h.mergeGuestCart(c, userID)
 	return c.JSON(http.StatusCreated, response{Message: "Signed up successfully"})
 }
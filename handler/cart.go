@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/pricing"
 	"github.com/rohitxdev/go-api-starter/repo"
 )
 
@@ -12,7 +14,7 @@ type GetCartResponse struct {
 }
 
 // @Summary Get cart
-// @Description Get cart.
+// @Description Get cart. Visitors who are not logged in get their guest cart.
 // @Router /carts [get]
 // @Security ApiKeyAuth
 // @Success 200 {object} GetCartResponse
@@ -20,7 +22,11 @@ type GetCartResponse struct {
 func (h *Handler) GetCart(c echo.Context) error {
 	user := getUser(c)
 	if user == nil {
-		return c.NoContent(http.StatusUnauthorized)
+		cart, err := h.getGuestCart(c.Request().Context(), h.getGuestID(c, false))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, GetCartResponse{Cart: cart})
 	}
 
 	cart, err := h.Repo.GetCart(c.Request().Context(), user.ID)
@@ -34,34 +40,202 @@ func (h *Handler) GetCart(c echo.Context) error {
 	return c.JSON(http.StatusOK, GetCartResponse{Cart: cart})
 }
 
+type GetCartSummaryRequest struct {
+	CouponCode string `query:"couponCode"`
+	Points     int    `query:"points" validate:"min=0"`
+}
+
+type CartSummaryItem struct {
+	Product  repo.Product `json:"product"`
+	Quantity int          `json:"quantity"`
+	Subtotal int          `json:"subtotal"`
+	// Discount is what promotions took off the item.
+	Discount int `json:"discount"`
+}
+
+type GetCartSummaryResponse struct {
+	Coupon *repo.Coupon      `json:"coupon,omitempty"`
+	Items  []CartSummaryItem `json:"items"`
+	// Promotions explain the promotion discount.
+	Promotions        []pricing.AppliedPromotion `json:"promotions"`
+	Subtotal          int                        `json:"subtotal"`
+	PromotionDiscount int                        `json:"promotionDiscount"`
+	Discount          int                        `json:"discount"`
+	// PointsRedeemed are the loyalty points that would be spent, and PointsDiscount what they are worth.
+	PointsRedeemed int `json:"pointsRedeemed"`
+	PointsDiscount int `json:"pointsDiscount"`
+	Tax            int `json:"tax"`
+	Shipping       int `json:"shipping"`
+	Total          int `json:"total"`
+	// Recommendations are products that are frequently bought together with the ones in the cart.
+	Recommendations []repo.Recommendation `json:"recommendations"`
+}
+
+// @Summary Get cart summary
+// @Description Get the totals of the cart as they would be charged if the order was placed now, with the promotions running, and optionally with a coupon applied and loyalty points spent. Products frequently bought together with the ones in the cart are recommended.
+// @Router /carts/summary [get]
+// @Security ApiKeyAuth
+// @Param couponCode query string false "Coupon code"
+// @Param points query int false "Loyalty points to spend"
+// @Success 200 {object} GetCartSummaryResponse
+// @Failure 400 {string} string "invalid coupon or not enough points"
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetCartSummary(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req GetCartSummaryRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	cartItems, products, err := h.Repo.GetCartWithProducts(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	if len(cartItems) != len(products) {
+		return errors.New("cart and products length mismatch")
+	}
+
+	lines := make([]pricing.Line, 0, len(cartItems))
+	for i, cartItem := range cartItems {
+		lines = append(lines, pricing.Line{ProductID: cartItem.ProductID, UnitPrice: products[i].Price, Quantity: cartItem.Quantity, Category: products[i].Category, NoPromotions: products[i].IsGiftCard})
+	}
+	opts := h.pricingOptions()
+	if opts.Promotions, err = h.Repo.GetActivePromotions(c.Request().Context()); err != nil {
+		return err
+	}
+	summary := pricing.Calculate(lines, opts)
+
+	var coupon *repo.Coupon
+	if req.CouponCode != "" {
+		if coupon, err = h.Repo.GetRedeemableCoupon(c.Request().Context(), req.CouponCode, user.ID, summary.Subtotal); err != nil {
+			return couponError(c, err)
+		}
+		coupon.Apply(&opts)
+		summary = pricing.Calculate(lines, opts)
+	}
+
+	var pointsRedeemed int
+	if req.Points > 0 {
+		balance, err := h.Repo.GetPointsBalance(c.Request().Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		if balance.Balance < req.Points {
+			return c.JSON(http.StatusBadRequest, response{Message: "Not enough points"})
+		}
+		pointsRedeemed, opts.PointsAmount = h.pointsProgram().Redeemable(req.Points, summary.Subtotal-summary.ExemptSubtotal-summary.PromotionDiscount-summary.Discount)
+		summary = pricing.Calculate(lines, opts)
+	}
+
+	items := make([]CartSummaryItem, 0, len(summary.Lines))
+	for i, line := range summary.Lines {
+		items = append(items, CartSummaryItem{Product: products[i], Quantity: line.Quantity, Subtotal: line.Subtotal, Discount: line.Discount})
+	}
+
+	productIDs := make([]int, 0, len(cartItems))
+	for _, cartItem := range cartItems {
+		productIDs = append(productIDs, cartItem.ProductID)
+	}
+	recommendations, err := h.Repo.GetCartRecommendations(c.Request().Context(), productIDs, cartRecommendationsLimit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetCartSummaryResponse{
+		Coupon:            coupon,
+		Items:             items,
+		Promotions:        summary.Promotions,
+		Subtotal:          summary.Subtotal,
+		PromotionDiscount: summary.PromotionDiscount,
+		Discount:          summary.Discount,
+		PointsRedeemed:    pointsRedeemed,
+		PointsDiscount:    summary.PointsDiscount,
+		Tax:               summary.Tax,
+		Shipping:          summary.Shipping,
+		Total:             summary.Total,
+		Recommendations:   recommendations,
+	})
+}
+
 type AddToCartRequest struct {
 	ProductID int `param:"productId" validate:"required"`
 }
 
 // @Summary Add to cart
-// @Description Add to cart.
+// @Description Add to cart. Visitors who are not logged in add to their guest cart, which is merged into their cart when they log in.
 // @Router /carts/{productId} [post]
 // @Security ApiKeyAuth
 // @Param productId path int true "Product ID"
 // @Success 200 {object} response
-// @Failure 400 {string} string "invalid product"
+// @Failure 400 {string} string "product is out of stock"
 // @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "product not found"
 func (h *Handler) AddToCart(c echo.Context) error {
+	var req AddToCartRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
 	user := getUser(c)
 	if user == nil {
-		return c.NoContent(http.StatusUnauthorized)
+		return h.addToGuestCart(c, req.ProductID)
 	}
 
-	var req AddToCartRequest
+	if err := h.Repo.AddToCart(c.Request().Context(), user.ID, req.ProductID); err != nil {
+		switch {
+		case errors.Is(err, repo.ErrProductNotFound):
+			return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+		case errors.Is(err, repo.ErrInsufficientStock):
+			return c.JSON(http.StatusBadRequest, response{Message: "Product is out of stock"})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, response{Message: "Cart item added."})
+}
+
+type ReplaceCartItem struct {
+	ProductID int `json:"productId" validate:"required"`
+	Quantity  int `json:"quantity" validate:"required,min=1"`
+}
+
+type ReplaceCartRequest struct {
+	Items []ReplaceCartItem `json:"items" validate:"max=100,dive"`
+}
+
+// @Summary Replace cart
+// @Description Replace the whole cart. Lines asking for more than is in stock are clamped, and lines for products that are out of stock or don't exist are rejected; both are listed in the response.
+// @Router /carts [put]
+// @Security ApiKeyAuth
+// @Param body body ReplaceCartRequest true "Cart"
+// @Success 200 {object} repo.ReplaceCartResult
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) ReplaceCart(c echo.Context) error {
+	var req ReplaceCartRequest
 	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
 
-	if err := h.Repo.AddToCart(c.Request().Context(), user.ID, req.ProductID); err != nil {
+	lines := make([]repo.CartLine, 0, len(req.Items))
+	for _, item := range req.Items {
+		lines = append(lines, repo.CartLine{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+
+	user := getUser(c)
+	if user == nil {
+		return h.replaceGuestCart(c, lines)
+	}
+
+	result, err := h.Repo.ReplaceCart(c.Request().Context(), user.ID, lines)
+	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusCreated, response{Message: "Cart item added."})
+	return c.JSON(http.StatusOK, result)
 }
 
 type UpdateCartItemQuantityRequest struct {
@@ -70,7 +244,8 @@ type UpdateCartItemQuantityRequest struct {
 }
 
 // @Summary Update cart item quantity
-// @Description Update cart item quantity.
+// @Description Update cart item quantity. Use PUT /carts instead.
+// @Deprecated
 // @Router /carts/{productId}/{quantity} [put]
 // @Security ApiKeyAuth
 // @Param productId path int true "Product ID"
@@ -78,17 +253,18 @@ type UpdateCartItemQuantityRequest struct {
 // @Success 200 {object} response
 // @Failure 400 {string} string "invalid product or quantity"
 // @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "cart item not found"
 func (h *Handler) UpdateCartItemQuantity(c echo.Context) error {
-	user := getUser(c)
-	if user == nil {
-		return c.NoContent(http.StatusUnauthorized)
-	}
-
 	var req UpdateCartItemQuantityRequest
 	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
 
+	user := getUser(c)
+	if user == nil {
+		return h.updateGuestCartItemQuantity(c, req.ProductID, req.Quantity)
+	}
+
 	if err := h.Repo.UpdateCartItemQuantity(c.Request().Context(), user.ID, req.ProductID, req.Quantity); err != nil {
 		return err
 	}
@@ -109,16 +285,16 @@ type DeleteCartItemRequest struct {
 // @Failure 400 {string} string "invalid product"
 // @Failure 401 {string} string "invalid session"
 func (h *Handler) DeleteCartItem(c echo.Context) error {
-	user := getUser(c)
-	if user == nil {
-		return c.NoContent(http.StatusUnauthorized)
-	}
-
 	var req DeleteCartItemRequest
 	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
 
+	user := getUser(c)
+	if user == nil {
+		return h.deleteGuestCartItem(c, req.ProductID)
+	}
+
 	if err := h.Repo.DeleteCartItem(c.Request().Context(), user.ID, req.ProductID); err != nil {
 		return err
 	}
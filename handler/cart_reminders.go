@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// cartReminderBatchSize is the most abandoned cart reminders sent in one run of the job.
+const cartReminderBatchSize = 100
+
+// sendCartReminders emails users who left items in their cart a while ago.
+func (h *Handler) sendCartReminders(ctx context.Context) error {
+	carts, err := h.Repo.GetAbandonedCarts(ctx, h.Config.AbandonedCartAfter, h.Config.CartReminderCooldown, cartReminderBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, cart := range carts {
+		if err = h.sendCartReminder(ctx, &cart); err != nil {
+			// The user will be picked up again on the next run.
+			h.Logger.Err(err).Int("userId", cart.UserID).Msg("Failed to send cart reminder")
+		}
+	}
+	return nil
+}
+
+func (h *Handler) sendCartReminder(ctx context.Context, cart *repo.AbandonedCart) error {
+	cartItems, products, err := h.Repo.GetCartWithProducts(ctx, cart.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get cart: %w", err)
+	}
+	if len(cartItems) == 0 {
+		return nil
+	}
+
+	var subtotal int
+	items := make([]map[string]any, 0, len(cartItems))
+	for i, cartItem := range cartItems {
+		subtotal += products[i].Price * cartItem.Quantity
+		items = append(items, map[string]any{
+			"name":     products[i].Name,
+			"quantity": cartItem.Quantity,
+			"amount":   formatAmount(products[i].Price * cartItem.Quantity),
+		})
+	}
+
+	token, err := h.createToken(fmt.Sprintf("cart-reminders:%d", cart.UserID))
+	if err != nil {
+		return err
+	}
+	unsubscribeURL := h.Config.BaseURL + "/carts/reminders/unsubscribe?token=" + url.QueryEscape(token)
+
+	err = h.Email.SendHTML(&email.BaseOpts{
+		Subject:         "You left something in your cart",
+		FromAddress:     h.Config.SenderEmail,
+		FromName:        h.Config.AppName,
+		ToAddresses:     []string{cart.Email},
+		UnsubscribeLink: "<" + unsubscribeURL + ">",
+		NoStack:         true,
+	}, "abandoned-cart.tmpl", map[string]any{
+		"items":          items,
+		"subtotal":       formatAmount(subtotal),
+		"cartURL":        h.Config.BaseURL + "/carts",
+		"unsubscribeURL": unsubscribeURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.Repo.CreateCartReminder(ctx, cart.UserID, len(cartItems))
+}
+
+type SetCartRemindersRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// @Summary Set cart reminders
+// @Description Turn abandoned cart reminder emails on or off.
+// @Router /carts/reminders [put]
+// @Security ApiKeyAuth
+// @Param body body SetCartRemindersRequest true "Preference"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) SetCartReminders(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req SetCartRemindersRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.SetWantsCartReminders(c.Request().Context(), user.ID, req.Enabled); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Cart reminder preference updated."})
+}
+
+type UnsubscribeFromCartRemindersRequest struct {
+	Token string `query:"token" validate:"required"`
+}
+
+// @Summary Unsubscribe from cart reminders
+// @Description Unsubscribe link sent in abandoned cart reminder emails.
+// @Router /carts/reminders/unsubscribe [get]
+// @Param token query string true "Unsubscribe token"
+// @Success 200 {html} string "unsubscribed page"
+// @Failure 400 {string} string "invalid token"
+func (h *Handler) UnsubscribeFromCartReminders(c echo.Context) error {
+	var req UnsubscribeFromCartRemindersRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	payload, err := h.readToken(req.Token)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid token"})
+	}
+	var userID int
+	if n, _ := fmt.Sscanf(payload, "cart-reminders:%d", &userID); n != 1 {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid token"})
+	}
+
+	if err = h.Repo.SetWantsCartReminders(c.Request().Context(), userID, false); err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "unsubscribed.tmpl", nil)
+}
+
+type GetCartReminderStatsRequest struct {
+	Days int `query:"days" validate:"omitempty,min=1,max=365"`
+}
+
+type GetCartReminderStatsResponse struct {
+	*repo.CartReminderStats
+	// ConversionRate is the share of reminders that led to an order.
+	ConversionRate float64 `json:"conversionRate"`
+	Days           int     `json:"days"`
+}
+
+// @Summary Get cart reminder stats
+// @Description Get how many abandoned cart reminders were sent and how many of them led to an order.
+// @Router /_/cart-reminders/stats [get]
+// @Security ApiKeyAuth
+// @Param days query int false "Number of days to look back, 30 by default"
+// @Success 200 {object} GetCartReminderStatsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetCartReminderStats(c echo.Context) error {
+	var req GetCartReminderStatsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Days == 0 {
+		req.Days = 30
+	}
+
+	stats, err := h.Repo.GetCartReminderStats(c.Request().Context(), time.Now().AddDate(0, 0, -req.Days))
+	if err != nil {
+		return err
+	}
+
+	res := GetCartReminderStatsResponse{CartReminderStats: stats, Days: req.Days}
+	if stats.Sent > 0 {
+		res.ConversionRate = float64(stats.Converted) / float64(stats.Sent)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -87,14 +87,14 @@ func TestCart(t *testing.T) {
 			wantStatus int
 		}{
 			{
-				name: "Unauthorized",
+				name: "Guest",
 				args: args{
 					reqOpts: &httpRequestOpts{
 						method: http.MethodGet,
 						path:   "/carts",
 					},
 				},
-				wantStatus: http.StatusUnauthorized,
+				wantStatus: http.StatusOK,
 			},
 			{
 				name: "Authorized",
@@ -121,6 +121,20 @@ func TestCart(t *testing.T) {
 				},
 				wantStatus: http.StatusCreated,
 			},
+			{
+				name: "Add unknown product to cart",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPost,
+						path:   "/carts/100000",
+						headers: map[string]string{
+							"Content-Type": "application/json",
+						},
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusNotFound,
+			},
 			{
 				name: "Update cart item quantity",
 				args: args{
@@ -193,6 +207,242 @@ func TestCart(t *testing.T) {
 		}
 	})
 
+	t.Run("Guest cart", func(t *testing.T) {
+		type args struct {
+			isAuthenticated bool
+			reqOpts         *httpRequestOpts
+		}
+		tests := []struct {
+			name       string
+			args       args
+			wantStatus int
+		}{
+			{
+				name: "Add to cart",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPost,
+						path:   "/carts/1",
+					},
+				},
+				wantStatus: http.StatusCreated,
+			},
+			{
+				name: "Add unknown product",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPost,
+						path:   "/carts/1000000000",
+					},
+				},
+				wantStatus: http.StatusNotFound,
+			},
+			{
+				name: "Delete cart item",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodDelete,
+						path:   "/carts/1",
+					},
+				},
+				wantStatus: http.StatusOK,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, err := createHttpRequest(tt.args.reqOpts)
+				if tt.args.isAuthenticated {
+					req.Header.Set("Cookie", cookie)
+				}
+				assert.Nil(t, err)
+				res := httptest.NewRecorder()
+				h.ServeHTTP(res, req)
+				assert.Equal(t, tt.wantStatus, res.Code)
+			})
+		}
+	})
+
+	t.Run("PUT /carts", func(t *testing.T) {
+		type args struct {
+			isAuthenticated bool
+			reqOpts         *httpRequestOpts
+		}
+		tests := []struct {
+			name       string
+			args       args
+			wantStatus int
+		}{
+			{
+				name: "Replace cart",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPut,
+						path:   "/carts",
+						headers: map[string]string{
+							"Content-Type": "application/json",
+						},
+						body: echo.Map{
+							"items": []echo.Map{
+								echo.Map{
+									"productId": 1,
+									"quantity":  1,
+								},
+								echo.Map{
+									"productId": 1000000000,
+									"quantity":  1,
+								},
+							},
+						},
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusOK,
+			},
+			{
+				name: "Add to cart twice",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPost,
+						path:   "/carts/1",
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusCreated,
+			},
+			{
+				name: "Invalid quantity",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPut,
+						path:   "/carts",
+						headers: map[string]string{
+							"Content-Type": "application/json",
+						},
+						body: echo.Map{
+							"items": []echo.Map{
+								echo.Map{
+									"productId": 1,
+									"quantity":  0,
+								},
+							},
+						},
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusUnprocessableEntity,
+			},
+			{
+				name: "Guest",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPut,
+						path:   "/carts",
+						headers: map[string]string{
+							"Content-Type": "application/json",
+						},
+						body: echo.Map{
+							"items": []echo.Map{
+								echo.Map{
+									"productId": 1,
+									"quantity":  1,
+								},
+							},
+						},
+					},
+				},
+				wantStatus: http.StatusOK,
+			},
+			{
+				name: "Empty cart",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPut,
+						path:   "/carts",
+						headers: map[string]string{
+							"Content-Type": "application/json",
+						},
+						body: echo.Map{
+							"items": []any{},
+						},
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusOK,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, err := createHttpRequest(tt.args.reqOpts)
+				if tt.args.isAuthenticated {
+					req.Header.Set("Cookie", cookie)
+				}
+				assert.Nil(t, err)
+				res := httptest.NewRecorder()
+				h.ServeHTTP(res, req)
+				assert.Equal(t, tt.wantStatus, res.Code)
+			})
+		}
+	})
+
+	t.Run("GET /carts/summary", func(t *testing.T) {
+		type args struct {
+			isAuthenticated bool
+			reqOpts         *httpRequestOpts
+		}
+		tests := []struct {
+			name       string
+			args       args
+			wantStatus int
+		}{
+			{
+				name: "Unauthorized",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodGet,
+						path:   "/carts/summary",
+					},
+				},
+				wantStatus: http.StatusUnauthorized,
+			},
+			{
+				name: "Authorized",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodGet,
+						path:   "/carts/summary",
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusOK,
+			},
+			{
+				name: "Invalid coupon",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodGet,
+						path:   "/carts/summary?couponCode=INVALID",
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusBadRequest,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, err := createHttpRequest(tt.args.reqOpts)
+				if tt.args.isAuthenticated {
+					req.Header.Set("Cookie", cookie)
+				}
+				assert.Nil(t, err)
+				res := httptest.NewRecorder()
+				h.ServeHTTP(res, req)
+				assert.Equal(t, tt.wantStatus, res.Code)
+			})
+		}
+	})
 }
 
 func createTestSessionCookie(e *echo.Echo, jwtSecret string) (string, error) {
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/oklog/ulid/v2"
@@ -80,8 +82,20 @@ func (h *Handler) GetAvailableCoupons(c echo.Context) error {
 }
 
 type CreateCouponRequest struct {
-	DiscountPercent int `json:"discountPercent" validate:"required,min=0,max=100"`
-	UserID          int `json:"userId" validate:"required"`
+	StartsAt  *time.Time `json:"startsAt"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	// UserID restricts the coupon to a single user. Leave empty for a coupon anyone can redeem.
+	UserID         *int `json:"userId" validate:"omitempty,min=1"`
+	MaxDiscount    *int `json:"maxDiscount" validate:"omitempty,min=1"`
+	MaxRedemptions *int `json:"maxRedemptions" validate:"omitempty,min=1"`
+	// Code is generated if left empty.
+	Code         string `json:"code" validate:"max=128"`
+	DiscountType string `json:"discountType" validate:"required,oneof=percent fixed"`
+	// DiscountValue is a percentage for percent coupons, and an amount in the smallest unit of the currency for fixed ones.
+	DiscountValue  int `json:"discountValue" validate:"required,min=1"`
+	MinOrderAmount int `json:"minOrderAmount" validate:"min=0"`
+	// MaxRedemptionsPerUser defaults to 1.
+	MaxRedemptionsPerUser int `json:"maxRedemptionsPerUser" validate:"min=0"`
 }
 
 type CreateCouponResponse struct {
@@ -89,29 +103,65 @@ type CreateCouponResponse struct {
 }
 
 // @Summary Create coupon
-// @Description Create coupon.
+// @Description Create a percent or fixed amount coupon, for a single user or for everyone, with optional minimum order amount, validity window and redemption limits.
 // @Router /coupons [post]
 // @Security ApiKeyAuth
-// @Param discountPercent path int true "Discount percent"
-// @Param userId path int true "User ID"
+// @Param body body CreateCouponRequest true "Coupon"
 // @Success 200 {object} CreateCouponResponse
 // @Failure 400 {string} string "invalid request"
 // @Failure 401 {string} string "invalid session"
+// @Failure 409 {string} string "coupon already exists"
 func (h *Handler) CreateCoupon(c echo.Context) error {
 	var req CreateCouponRequest
 	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
 
-	user := getUser(c)
-	if user == nil {
-		return c.NoContent(http.StatusUnauthorized)
+	if req.DiscountType == repo.CouponPercent && req.DiscountValue > 100 {
+		return c.JSON(http.StatusBadRequest, response{Message: "Discount can't be more than 100%"})
+	}
+	if req.StartsAt != nil && req.ExpiresAt != nil && !req.StartsAt.Before(*req.ExpiresAt) {
+		return c.JSON(http.StatusBadRequest, response{Message: "Coupon must start before it expires"})
+	}
+	if req.Code == "" {
+		req.Code = ulid.Make().String()
 	}
 
-	coupon, err := h.Repo.CreateCoupon(c.Request().Context(), user.ID, ulid.Make().String(), req.DiscountPercent)
+	coupon, err := h.Repo.CreateCoupon(c.Request().Context(), &repo.CreateCouponParams{
+		Code:                  req.Code,
+		UserID:                req.UserID,
+		DiscountType:          req.DiscountType,
+		DiscountValue:         req.DiscountValue,
+		MaxDiscount:           req.MaxDiscount,
+		MinOrderAmount:        req.MinOrderAmount,
+		StartsAt:              req.StartsAt,
+		ExpiresAt:             req.ExpiresAt,
+		MaxRedemptions:        req.MaxRedemptions,
+		MaxRedemptionsPerUser: req.MaxRedemptionsPerUser,
+	})
 	if err != nil {
+		if errors.Is(err, repo.ErrCouponAlreadyExists) {
+			return c.JSON(http.StatusConflict, response{Message: "A coupon with this code already exists"})
+		}
 		return err
 	}
 
 	return c.JSON(http.StatusOK, CreateCouponResponse{Coupon: coupon})
 }
+
+// couponError responds to the reasons a coupon can't be redeemed.
+func couponError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repo.ErrCouponNotFound):
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid coupon"})
+	case errors.Is(err, repo.ErrCouponNotActive):
+		return c.JSON(http.StatusBadRequest, response{Message: "Coupon is not active yet"})
+	case errors.Is(err, repo.ErrCouponExpired):
+		return c.JSON(http.StatusBadRequest, response{Message: "Coupon has expired"})
+	case errors.Is(err, repo.ErrCouponUsageLimitReached):
+		return c.JSON(http.StatusBadRequest, response{Message: "Coupon has already been used"})
+	case errors.Is(err, repo.ErrCouponMinimumNotMet):
+		return c.JSON(http.StatusBadRequest, response{Message: "Order amount is below the coupon minimum"})
+	}
+	return err
+}
@@ -135,14 +135,53 @@ func TestCoupons(t *testing.T) {
 							"Content-Type": "application/json",
 						},
 						body: echo.Map{
-							"discountPercent": 10,
-							"userId":          1,
+							"discountType":  "percent",
+							"discountValue": 10,
+							"userId":        1,
 						},
 					},
 					isAuthenticated: true,
 				},
 				wantStatus: http.StatusOK,
 			},
+			{
+				name: "Create coupon with percent over 100",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPost,
+						path:   "/coupons",
+						headers: map[string]string{
+							"Cookie":       cookie,
+							"Content-Type": "application/json",
+						},
+						body: echo.Map{
+							"discountType":  "percent",
+							"discountValue": 150,
+						},
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusBadRequest,
+			},
+			{
+				name: "Create coupon with invalid discount type",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodPost,
+						path:   "/coupons",
+						headers: map[string]string{
+							"Cookie":       cookie,
+							"Content-Type": "application/json",
+						},
+						body: echo.Map{
+							"discountType":  "bogo",
+							"discountValue": 10,
+						},
+					},
+					isAuthenticated: true,
+				},
+				wantStatus: http.StatusBadRequest,
+			},
 		}
 
 		for _, tt := range tests {
@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"github.com/rohitxdev/go-api-starter/blobstore"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+const (
+	// exportFlushInterval is how many records are written before the response is flushed to the client.
+	exportFlushInterval = 100
+	// exportTimeout is how long an async export can take, and exportLinkDuration how long its download link works.
+	exportTimeout      = time.Minute * 30
+	exportLinkDuration = time.Hour * 24
+)
+
+type ExportRequest struct {
+	// Format is either csv or json. It defaults to csv.
+	Format string `query:"format" validate:"omitempty,oneof=csv json"`
+	// From and To are dates (2006-01-02) or times (RFC 3339). A date in To includes the whole day.
+	From string `query:"from"`
+	To   string `query:"to"`
+	// Async exports are uploaded to the blob store, and a download link is emailed to the admin.
+	Async bool `query:"async"`
+}
+
+// exportWriter writes records as CSV rows or as a JSON array.
+type exportWriter struct {
+	w     io.Writer
+	csv   *csv.Writer
+	json  *json.Encoder
+	count int
+	// flush is called every exportFlushInterval records. It may be nil.
+	flush func()
+}
+
+func newExportWriter(w io.Writer, format string, header []string) (*exportWriter, error) {
+	ew := &exportWriter{w: w}
+	if format == "json" {
+		ew.json = json.NewEncoder(w)
+		_, err := io.WriteString(w, "[")
+		return ew, err
+	}
+	ew.csv = csv.NewWriter(w)
+	return ew, ew.csv.Write(header)
+}
+
+// write writes the record as JSON, or its rows as CSV.
+func (ew *exportWriter) write(record any, rows ...[]string) error {
+	if ew.json != nil {
+		if ew.count > 0 {
+			if _, err := io.WriteString(ew.w, ","); err != nil {
+				return err
+			}
+		}
+		if err := ew.json.Encode(record); err != nil {
+			return err
+		}
+	} else {
+		for _, row := range rows {
+			for i := range row {
+				row[i] = escapeCSVCell(row[i])
+			}
+		}
+		if err := ew.csv.WriteAll(rows); err != nil {
+			return err
+		}
+	}
+	ew.count++
+	if ew.flush != nil && ew.count%exportFlushInterval == 0 {
+		ew.flush()
+	}
+	return nil
+}
+
+// escapeCSVCell keeps spreadsheets from running a cell as a formula, as a customer email or a coupon code could start with one. Numbers are left as they are.
+func escapeCSVCell(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+func (ew *exportWriter) close() error {
+	if ew.json != nil {
+		_, err := io.WriteString(ew.w, "]")
+		return err
+	}
+	ew.csv.Flush()
+	return ew.csv.Error()
+}
+
+// exportSource writes every record of an export between 'from' and 'to'.
+type exportSource func(ctx context.Context, ew *exportWriter, from *time.Time, to *time.Time) error
+
+// export streams the records of the source to the client, or with async set, uploads them to the blob store and emails a download link to the admin.
+func (h *Handler) export(c echo.Context, name string, header []string, source exportSource) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req ExportRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	from, err := parseTimeParam(req.From, false)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid from"})
+	}
+	to, err := parseTimeParam(req.To, true)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid to"})
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return c.JSON(http.StatusBadRequest, response{Message: "From must be before to"})
+	}
+
+	contentType := "text/csv"
+	if req.Format == "json" {
+		contentType = echo.MIMEApplicationJSON
+	}
+	fileName := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102T150405"), req.Format)
+
+	if req.Async {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			defer cancel()
+			if err := h.exportToBlobStore(ctx, user, "exports/"+ulid.Make().String()+"/"+fileName, contentType, req.Format, header, from, to, source); err != nil {
+				h.Logger.Err(err).Str("export", name).Int("userId", user.ID).Msg("Failed to export")
+			}
+		}()
+		return c.JSON(http.StatusAccepted, response{Message: "The export will be emailed to you when it's ready"})
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+	res.WriteHeader(http.StatusOK)
+	ew, err := newExportWriter(res, req.Format, header)
+	if err != nil {
+		return err
+	}
+	ew.flush = func() {
+		if ew.csv != nil {
+			ew.csv.Flush()
+		}
+		res.Flush()
+	}
+	// The status is already sent, so a failed export can only end the response early
+	if err = source(c.Request().Context(), ew, from, to); err == nil {
+		err = ew.close()
+	}
+	if err != nil {
+		h.Logger.Err(err).Str("export", name).Msg("Failed to export")
+	}
+	return nil
+}
+
+// exportToBlobStore writes the export to a temporary file, uploads it to the blob store and emails a download link to the user.
+func (h *Handler) exportToBlobStore(ctx context.Context, user *repo.User, fileName string, contentType string, format string, header []string, from *time.Time, to *time.Time, source exportSource) error {
+	file, err := os.CreateTemp("", "export-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	ew, err := newExportWriter(file, format, header)
+	if err != nil {
+		return err
+	}
+	if err = source(ctx, ew, from, to); err != nil {
+		return err
+	}
+	if err = ew.close(); err != nil {
+		return err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	err = h.BlobStore.Upload(ctx, &blobstore.UploadParams{
+		BucketName:  h.Config.S3BucketName,
+		FileName:    fileName,
+		ContentType: contentType,
+		Body:        file,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload export: %w", err)
+	}
+	url, err := h.BlobStore.Get(ctx, &blobstore.GetParams{
+		BucketName: h.Config.S3BucketName,
+		FileName:   fileName,
+		ExpiresIn:  exportLinkDuration,
+	})
+	if err != nil {
+		return err
+	}
+
+	h.sendEmail(&email.BaseOpts{
+		Subject:     "Your export is ready",
+		ToAddresses: []string{user.Email},
+	}, "export-ready.tmpl", map[string]any{
+		"records":   ew.count,
+		"url":       url,
+		"expiresIn": fmt.Sprintf("%d hours", int(exportLinkDuration.Hours())),
+	})
+	return nil
+}
+
+// @Summary Export orders
+// @Description Export the orders placed in a date range with their items, discounts and coupon codes. CSV exports have a row for every item. The export is streamed, or with async set, emailed as a download link.
+// @Router /_/orders/export [get]
+// @Security ApiKeyAuth
+// @Param format query string false "csv (default) or json"
+// @Param from query string false "Start date (2006-01-02) or time (RFC 3339)"
+// @Param to query string false "End date (inclusive) or time (exclusive)"
+// @Param async query bool false "Email a download link instead"
+// @Success 200 {array} repo.ExportedOrder
+// @Success 202 {object} response
+// @Failure 400 {string} string "invalid date range"
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) ExportOrders(c echo.Context) error {
+	header := []string{
+		"order_id", "created_at", "status", "user_id", "user_email", "coupon_code",
+		"product_id", "product_name", "quantity", "unit_price", "item_discount", "refunded_quantity",
+		"promotion_discount", "coupon_discount", "points_discount", "tax", "shipping", "total",
+		"gift_card_amount", "store_credit_amount", "refunded_amount",
+	}
+	return h.export(c, "orders", header, func(ctx context.Context, ew *exportWriter, from *time.Time, to *time.Time) error {
+		return h.Repo.ExportOrders(ctx, from, to, func(o *repo.ExportedOrder) error {
+			rows := make([][]string, len(o.Items))
+			for i, item := range o.Items {
+				rows[i] = []string{
+					strconv.Itoa(o.ID), o.CreatedAt, o.Status, strconv.Itoa(o.UserID), o.UserEmail, o.CouponCode,
+					strconv.Itoa(item.ProductID), item.ProductName, strconv.Itoa(item.Quantity), formatAmount(item.Price), formatAmount(item.Discount), strconv.Itoa(item.RefundedQuantity),
+					formatAmount(o.PromotionDiscount), formatAmount(o.DiscountedAmount), formatAmount(o.PointsDiscount), formatAmount(o.TaxAmount), formatAmount(o.ShippingAmount), formatAmount(o.TotalAmount),
+					formatAmount(o.GiftCardAmount), formatAmount(o.StoreCreditAmount), formatAmount(o.RefundedAmount),
+				}
+			}
+			return ew.write(o, rows...)
+		})
+	})
+}
+
+// @Summary Export coupons
+// @Description Export the coupons created in a date range with how often they were redeemed and the discount they gave. The export is streamed, or with async set, emailed as a download link.
+// @Router /_/coupons/export [get]
+// @Security ApiKeyAuth
+// @Param format query string false "csv (default) or json"
+// @Param from query string false "Start date (2006-01-02) or time (RFC 3339)"
+// @Param to query string false "End date (inclusive) or time (exclusive)"
+// @Param async query bool false "Email a download link instead"
+// @Success 200 {array} repo.ExportedCoupon
+// @Success 202 {object} response
+// @Failure 400 {string} string "invalid date range"
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) ExportCoupons(c echo.Context) error {
+	header := []string{
+		"coupon_id", "created_at", "code", "discount_type", "discount_value", "max_discount", "min_order_amount",
+		"starts_at", "expires_at", "user_id", "max_redemptions", "max_redemptions_per_user", "redemption_count", "total_discount",
+	}
+	return h.export(c, "coupons", header, func(ctx context.Context, ew *exportWriter, from *time.Time, to *time.Time) error {
+		return h.Repo.ExportCoupons(ctx, from, to, func(coupon *repo.ExportedCoupon) error {
+			return ew.write(coupon, []string{
+				strconv.Itoa(coupon.ID), coupon.CreatedAt, coupon.Code, coupon.DiscountType, strconv.Itoa(coupon.DiscountValue), formatOptionalAmount(coupon.MaxDiscount), formatAmount(coupon.MinOrderAmount),
+				formatOptionalString(coupon.StartsAt), formatOptionalString(coupon.ExpiresAt), formatOptionalInt(coupon.UserID), formatOptionalInt(coupon.MaxRedemptions), strconv.Itoa(coupon.MaxRedemptionsPerUser), strconv.Itoa(coupon.RedemptionCount), formatAmount(coupon.TotalDiscount),
+			})
+		})
+	})
+}
+
+// formatOptionalInt, formatOptionalAmount and formatOptionalString format missing values as empty CSV fields.
+func formatOptionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func formatOptionalAmount(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return formatAmount(*value)
+}
+
+func formatOptionalString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
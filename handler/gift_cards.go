@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// giftCardError responds to the reasons a gift card can't be used.
+func giftCardError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repo.ErrGiftCardNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Gift card not found"})
+	case errors.Is(err, repo.ErrGiftCardNotActive):
+		return c.JSON(http.StatusBadRequest, response{Message: "Gift card is not active"})
+	case errors.Is(err, repo.ErrGiftCardExpired):
+		return c.JSON(http.StatusBadRequest, response{Message: "Gift card has expired"})
+	case errors.Is(err, repo.ErrGiftCardEmpty):
+		return c.JSON(http.StatusBadRequest, response{Message: "Gift card has no balance left"})
+	}
+	return err
+}
+
+// sendGiftCards emails the codes of the gift cards.
+func (h *Handler) sendGiftCards(to string, subject string, message string, giftCards []repo.GiftCard) {
+	cards := make([]map[string]any, 0, len(giftCards))
+	for _, giftCard := range giftCards {
+		cards = append(cards, map[string]any{
+			"code":    giftCard.Code,
+			"balance": formatAmount(giftCard.Balance),
+		})
+	}
+	h.sendEmail(&email.BaseOpts{
+		Subject:     subject,
+		ToAddresses: []string{to},
+	}, "gift-cards.tmpl", map[string]any{
+		"message":   message,
+		"giftCards": cards,
+	})
+}
+
+type GetGiftCardRequest struct {
+	Code string `param:"code" validate:"required"`
+}
+
+type GiftCardBalance struct {
+	ExpiresAt *string `json:"expiresAt"`
+	Code      string  `json:"code"`
+	Balance   int     `json:"balance"`
+	IsActive  bool    `json:"isActive"`
+}
+
+// @Summary Get gift card balance
+// @Description Get the balance of a gift card by its code.
+// @Router /gift-cards/{code} [get]
+// @Security ApiKeyAuth
+// @Param code path string true "Gift card code"
+// @Success 200 {object} GiftCardBalance
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "gift card not found"
+func (h *Handler) GetGiftCardBalance(c echo.Context) error {
+	var req GetGiftCardRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	giftCard, err := h.Repo.GetGiftCardByCode(c.Request().Context(), req.Code)
+	if err != nil {
+		return giftCardError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, GiftCardBalance{
+		Code:      giftCard.Code,
+		Balance:   giftCard.Balance,
+		ExpiresAt: giftCard.ExpiresAt,
+		IsActive:  giftCard.IsActive,
+	})
+}
+
+type IssueGiftCardRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+	// Code is generated if left empty.
+	Code string `json:"code" validate:"max=64"`
+	// RecipientEmail is emailed the code of the gift card, if set.
+	RecipientEmail string `json:"recipientEmail" validate:"omitempty,email"`
+	Note           string `json:"note" validate:"max=512"`
+	Balance        int    `json:"balance" validate:"required,min=1"`
+}
+
+type GiftCardResponse struct {
+	GiftCard *repo.GiftCard `json:"giftCard"`
+}
+
+// @Summary Issue gift card
+// @Description Issue a gift card, e.g. as a goodwill gesture, optionally emailing its code to the recipient.
+// @Router /_/gift-cards [post]
+// @Security ApiKeyAuth
+// @Param body body IssueGiftCardRequest true "Gift card"
+// @Success 201 {object} GiftCardResponse
+// @Failure 400 {string} string "invalid request"
+// @Failure 401 {string} string "invalid session"
+// @Failure 409 {string} string "gift card already exists"
+func (h *Handler) IssueGiftCard(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req IssueGiftCardRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return c.JSON(http.StatusBadRequest, response{Message: "Gift card can't expire in the past"})
+	}
+
+	giftCard, err := h.Repo.CreateGiftCard(c.Request().Context(), &repo.CreateGiftCardParams{
+		Code:      req.Code,
+		Balance:   req.Balance,
+		ExpiresAt: req.ExpiresAt,
+		IssuedBy:  &user.ID,
+		Note:      req.Note,
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrGiftCardAlreadyExists) {
+			return c.JSON(http.StatusConflict, response{Message: "A gift card with this code already exists"})
+		}
+		return err
+	}
+
+	if req.RecipientEmail != "" {
+		h.sendGiftCards(req.RecipientEmail, "You have received a gift card", "Hi, you have received a gift card.", []repo.GiftCard{*giftCard})
+	}
+
+	return c.JSON(http.StatusCreated, GiftCardResponse{GiftCard: giftCard})
+}
+
+type GetGiftCardsResponse struct {
+	GiftCards []repo.GiftCard `json:"giftCards"`
+}
+
+// @Summary Get gift cards
+// @Description Get all gift cards, latest first.
+// @Router /_/gift-cards [get]
+// @Security ApiKeyAuth
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetGiftCardsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetGiftCards(c echo.Context) error {
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	giftCards, err := h.Repo.GetGiftCards(c.Request().Context(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetGiftCardsResponse{GiftCards: giftCards})
+}
+
+type GiftCardIDRequest struct {
+	ID int `param:"id" validate:"required"`
+}
+
+type GetGiftCardResponse struct {
+	GiftCard     *repo.GiftCard             `json:"giftCard"`
+	Transactions []repo.GiftCardTransaction `json:"transactions"`
+}
+
+// @Summary Get gift card
+// @Description Get a gift card with every change to its balance, oldest first.
+// @Router /_/gift-cards/{id} [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Gift card ID"
+// @Success 200 {object} GetGiftCardResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "gift card not found"
+func (h *Handler) GetGiftCard(c echo.Context) error {
+	var req GiftCardIDRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	giftCard, err := h.Repo.GetGiftCard(c.Request().Context(), req.ID)
+	if err != nil {
+		return giftCardError(c, err)
+	}
+	transactions, err := h.Repo.GetGiftCardTransactions(c.Request().Context(), req.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetGiftCardResponse{GiftCard: giftCard, Transactions: transactions})
+}
+
+type DeactivateGiftCardRequest struct {
+	Note string `json:"note" validate:"max=512"`
+	ID   int    `param:"id" validate:"required"`
+}
+
+// @Summary Deactivate gift card
+// @Description Stop a gift card from being redeemed, e.g. when it was refunded or leaked. Its balance is written off.
+// @Router /_/gift-cards/{id} [delete]
+// @Security ApiKeyAuth
+// @Param id path int true "Gift card ID"
+// @Param body body DeactivateGiftCardRequest false "Reason"
+// @Success 200 {object} GiftCardResponse
+// @Failure 400 {string} string "gift card is not active"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "gift card not found"
+func (h *Handler) DeactivateGiftCard(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req DeactivateGiftCardRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	giftCard, err := h.Repo.DeactivateGiftCard(c.Request().Context(), req.ID, user.ID, req.Note)
+	if err != nil {
+		return giftCardError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, GiftCardResponse{GiftCard: giftCard})
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"github.com/rohitxdev/go-api-starter/kvstore"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// guestCartCookie holds the anonymous ID of a visitor's cart until they log in.
+const guestCartCookie = "guest_cart"
+
+func guestCartKey(guestID string) string {
+	return "guest-cart:" + guestID
+}
+
+// getGuestID returns the guest cart ID of the visitor. If they don't have one yet and create is set, a new one is issued.
+func (h *Handler) getGuestID(c echo.Context, create bool) string {
+	if cookie, err := c.Cookie(guestCartCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if !create {
+		return ""
+	}
+	guestID := ulid.Make().String()
+	h.setGuestCartCookie(c, guestID, int(h.Config.GuestCartDuration.Seconds()))
+	return guestID
+}
+
+func (h *Handler) setGuestCartCookie(c echo.Context, value string, maxAge int) {
+	cookie := &http.Cookie{
+		Name:     guestCartCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.Config.UseSecureCookie,
+	}
+	if cookie.Secure {
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	c.SetCookie(cookie)
+}
+
+func (h *Handler) getGuestCart(ctx context.Context, guestID string) ([]repo.CartItem, error) {
+	cart := make([]repo.CartItem, 0)
+	if guestID == "" {
+		return cart, nil
+	}
+	value, err := h.KVStore.Get(ctx, guestCartKey(guestID))
+	if err != nil {
+		if errors.Is(err, kvstore.ErrKeyNotFound) || errors.Is(err, kvstore.ErrKeyExpired) {
+			return cart, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(value), &cart); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// saveGuestCart stores the guest cart. Every change pushes its expiry back.
+func (h *Handler) saveGuestCart(ctx context.Context, guestID string, cart []repo.CartItem) error {
+	if len(cart) == 0 {
+		return h.KVStore.Delete(ctx, guestCartKey(guestID))
+	}
+	data, err := json.Marshal(cart)
+	if err != nil {
+		return err
+	}
+	return h.KVStore.Set(ctx, guestCartKey(guestID), string(data), kvstore.WithExpiry(h.Config.GuestCartDuration))
+}
+
+func (h *Handler) addToGuestCart(c echo.Context, productID int) error {
+	ctx := c.Request().Context()
+	product, err := h.Repo.GetProduct(ctx, productID)
+	if err != nil {
+		if errors.Is(err, repo.ErrProductNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+		}
+		return err
+	}
+	if product.QuantityAvailable <= 0 {
+		return c.JSON(http.StatusBadRequest, response{Message: "Product is out of stock"})
+	}
+
+	guestID := h.getGuestID(c, true)
+	cart, err := h.getGuestCart(ctx, guestID)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(cart, func(item repo.CartItem) bool { return item.ProductID == productID }) {
+		now := time.Now().UTC().Format(time.RFC3339)
+		cart = append(cart, repo.CartItem{ProductID: productID, Quantity: 1, CreatedAt: now, UpdatedAt: now})
+	}
+	if err = h.saveGuestCart(ctx, guestID, cart); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, response{Message: "Cart item added."})
+}
+
+func (h *Handler) updateGuestCartItemQuantity(c echo.Context, productID int, quantity int) error {
+	ctx := c.Request().Context()
+	product, err := h.Repo.GetProduct(ctx, productID)
+	if err != nil {
+		if errors.Is(err, repo.ErrProductNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+		}
+		return err
+	}
+	if quantity > product.QuantityAvailable {
+		return c.JSON(http.StatusBadRequest, response{Message: "Not enough stock"})
+	}
+
+	guestID := h.getGuestID(c, false)
+	cart, err := h.getGuestCart(ctx, guestID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(cart, func(item repo.CartItem) bool { return item.ProductID == productID })
+	if i < 0 {
+		return c.JSON(http.StatusNotFound, response{Message: "Cart item not found"})
+	}
+	cart[i].Quantity = quantity
+	cart[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err = h.saveGuestCart(ctx, guestID, cart); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Cart item quantity updated."})
+}
+
+func (h *Handler) deleteGuestCartItem(c echo.Context, productID int) error {
+	ctx := c.Request().Context()
+	guestID := h.getGuestID(c, false)
+	if guestID == "" {
+		return c.JSON(http.StatusOK, response{Message: "Cart item deleted."})
+	}
+	cart, err := h.getGuestCart(ctx, guestID)
+	if err != nil {
+		return err
+	}
+	cart = slices.DeleteFunc(cart, func(item repo.CartItem) bool { return item.ProductID == productID })
+	if err = h.saveGuestCart(ctx, guestID, cart); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Cart item deleted."})
+}
+
+func (h *Handler) replaceGuestCart(c echo.Context, lines []repo.CartLine) error {
+	ctx := c.Request().Context()
+	guestID := h.getGuestID(c, len(lines) > 0)
+
+	result := repo.NewReplaceCartResult()
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, line := range repo.NormalizeCartLines(lines) {
+		exists := true
+		available := 0
+		product, err := h.Repo.GetProduct(ctx, line.ProductID)
+		if err != nil {
+			if !errors.Is(err, repo.ErrProductNotFound) {
+				return err
+			}
+			exists = false
+		} else {
+			available = product.QuantityAvailable
+		}
+
+		if quantity := result.Resolve(line, available, exists); quantity > 0 {
+			result.Cart = append(result.Cart, repo.CartItem{ProductID: line.ProductID, Quantity: quantity, CreatedAt: now, UpdatedAt: now})
+		}
+	}
+	if guestID != "" {
+		if err := h.saveGuestCart(ctx, guestID, result.Cart); err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// mergeGuestCart moves the visitor's guest cart, if any, into the cart of the user who just logged in. Failing to merge doesn't fail the log in.
+func (h *Handler) mergeGuestCart(c echo.Context, userID int) {
+	guestID := h.getGuestID(c, false)
+	if guestID == "" {
+		return
+	}
+	ctx := c.Request().Context()
+
+	cart, err := h.getGuestCart(ctx, guestID)
+	if err != nil {
+		h.Logger.Err(err).Int("userId", userID).Msg("Failed to get guest cart")
+		return
+	}
+	if len(cart) > 0 {
+		if err = h.Repo.MergeCart(ctx, userID, cart); err != nil {
+			h.Logger.Err(err).Int("userId", userID).Msg("Failed to merge guest cart")
+			return
+		}
+	}
+	if err = h.KVStore.Delete(ctx, guestCartKey(guestID)); err != nil {
+		h.Logger.Err(err).Int("userId", userID).Msg("Failed to delete guest cart")
+	}
+	h.setGuestCartCookie(c, "", -1)
+}
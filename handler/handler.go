@@ -22,6 +22,7 @@ import (
 	"github.com/rohitxdev/go-api-starter/config"
 	"github.com/rohitxdev/go-api-starter/docs"
 	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/jobs"
 	"github.com/rohitxdev/go-api-starter/kvstore"
 	"github.com/rohitxdev/go-api-starter/repo"
 	"github.com/rs/zerolog"
@@ -31,12 +32,19 @@ type Services struct {
 	BlobStore *blobstore.Store
 	Config    *config.Config
 	Email     *email.Client
-	KVStore   *kvstore.Store
-	Logger    *zerolog.Logger
-	Repo      *repo.Repo
+	// Jobs runs the background jobs. It may be nil, in which case no background jobs are run.
+	Jobs    *jobs.Scheduler
+	KVStore *kvstore.Store
+	Logger  *zerolog.Logger
+	Repo    *repo.Repo
 }
 
 func (s *Services) Close() error {
+	if s.Jobs != nil {
+		if err := s.Jobs.Close(); err != nil {
+			return fmt.Errorf("Failed to stop background jobs: %w", err)
+		}
+	}
 	if err := s.KVStore.Close(); err != nil {
 		return fmt.Errorf("Failed to close KV store: %w", err)
 	}
@@ -129,9 +137,11 @@ func New(svc *Services) (*echo.Echo, error) {
 	}))
 
 	// This middleware causes data races. See https://github.com/labstack/echo/issues/1761. But it's not a big deal.
+	// Exports are skipped because they are streamed, while this middleware buffers the response.
 	e.Pre(middleware.TimeoutWithConfig(middleware.TimeoutConfig{
 		Timeout: 10 * time.Second, Skipper: func(c echo.Context) bool {
-			return strings.HasPrefix(c.Request().URL.Path, "/debug/pprof")
+			path := c.Request().URL.Path
+			return strings.HasPrefix(path, "/debug/pprof") || strings.HasSuffix(path, "/export")
 		},
 	}))
 
@@ -214,6 +224,7 @@ func New(svc *Services) (*echo.Echo, error) {
 	pprof.Register(e)
 
 	setUpRoutes(e, svc)
+	setUpJobs(svc)
 
 	return e, nil
 }
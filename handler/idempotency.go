@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+const (
+	headerIdempotencyKey = "Idempotency-Key"
+	// headerIdempotentReplayed is set on responses that were replayed from an earlier request with the same key.
+	headerIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// responseRecorder copies the body of a response as it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotencyScope returns who sent the request: the logged in user, or the visitor with a guest cart. It is empty for anyone else.
+func (h *Handler) idempotencyScope(c echo.Context) string {
+	if user, err := h.sessionUser(c); err == nil && user != nil {
+		return "user:" + strconv.Itoa(user.ID)
+	}
+	if guestID := h.getGuestID(c, false); guestID != "" {
+		return "guest:" + guestID
+	}
+	return ""
+}
+
+// idempotent makes mutating requests with an Idempotency-Key header safe to retry. The response to the first request with a key is stored, and replayed to the retries with the same method, URI and body. A key reused for a different request is rejected. Responses with a server error are not stored, so that the request can be retried.
+func (h *Handler) idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		key := req.Header.Get(headerIdempotencyKey)
+		if key == "" || req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+			return next(c)
+		}
+		if len(key) > 255 {
+			return c.JSON(http.StatusBadRequest, response{Message: "Idempotency key is too long"})
+		}
+		scope := h.idempotencyScope(c)
+		if scope == "" {
+			return next(c)
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		hash.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		stored, err := h.Repo.ClaimIdempotencyKey(req.Context(), scope, key, fingerprint, time.Now().Add(h.Config.IdempotencyKeyDuration))
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrIdempotencyKeyMismatch):
+				return c.JSON(http.StatusUnprocessableEntity, response{Message: "Idempotency key was already used for a different request"})
+			case errors.Is(err, repo.ErrIdempotencyKeyInProgress):
+				return c.JSON(http.StatusConflict, response{Message: "A request with this idempotency key is in progress"})
+			}
+			return err
+		}
+		if stored != nil {
+			c.Response().Header().Set(headerIdempotentReplayed, "true")
+			return c.Blob(stored.StatusCode, stored.ContentType, stored.Body)
+		}
+
+		// The outcome has to be recorded even if the client has gone away, or its retry would be stuck behind this request
+		ctx := context.WithoutCancel(req.Context())
+		// The key is released unless the response was stored, including when the handler panics, so that the request can be retried
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := h.Repo.ReleaseIdempotencyKey(ctx, scope, key); err != nil {
+				h.Logger.Error().Err(err).Str("key", key).Msg("Failed to release idempotency key")
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = recorder
+		if err = next(c); err != nil {
+			c.Error(err)
+		}
+
+		res := c.Response()
+		if res.Status >= http.StatusInternalServerError {
+			return nil
+		}
+		err = h.Repo.CompleteIdempotencyKey(ctx, scope, key, &repo.StoredResponse{
+			StatusCode:  res.Status,
+			ContentType: res.Header().Get(echo.HeaderContentType),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			h.Logger.Error().Err(err).Str("key", key).Msg("Failed to store idempotent response")
+			return nil
+		}
+		completed = true
+		return nil
+	}
+}
+
+func (h *Handler) deleteExpiredIdempotencyKeys(ctx context.Context) error {
+	count, err := h.Repo.DeleteExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		h.Logger.Info().Int64("count", count).Msg("Deleted expired idempotency keys")
+	}
+	return nil
+}
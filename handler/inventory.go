@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+var (
+	productQuantityLeft = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "product_quantity_left",
+		Help: "Stock left of each product.",
+	}, []string{"product_id", "product_name"})
+	productLowStock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "product_low_stock",
+		Help: "Whether the stock of each product is at or below its low stock threshold.",
+	}, []string{"product_id", "product_name"})
+)
+
+type AdjustStockRequest struct {
+	// Kind is either restock, which only adds stock, or adjustment.
+	Kind   string `json:"kind" validate:"required,oneof=restock adjustment"`
+	Reason string `json:"reason" validate:"max=512"`
+	// WarehouseID is the warehouse whose stock changes. It defaults to the default warehouse.
+	WarehouseID *int `json:"warehouseId"`
+	// Quantity is the signed change in stock.
+	Quantity  int `json:"quantity" validate:"required"`
+	ProductID int `param:"id" validate:"required"`
+}
+
+type AdjustStockResponse struct {
+	Movement *repo.InventoryMovement `json:"movement"`
+}
+
+// @Summary Adjust stock
+// @Description Restock a product or correct its stock in a warehouse by hand. Every change is recorded in the inventory ledger.
+// @Router /_/products/{id}/stock [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Param body body AdjustStockRequest true "Stock change"
+// @Success 200 {object} AdjustStockResponse
+// @Failure 400 {string} string "not enough stock, or a restock that doesn't add stock"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "product or warehouse not found"
+// @Failure 409 {string} string "stock is held by customers checking out"
+func (h *Handler) AdjustStock(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req AdjustStockRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Kind == repo.MovementRestock && req.Quantity <= 0 {
+		return c.JSON(http.StatusBadRequest, response{Message: "Restocks must add stock"})
+	}
+
+	movement, err := h.Repo.AdjustStock(c.Request().Context(), &repo.AdjustStockParams{
+		ProductID:   req.ProductID,
+		WarehouseID: req.WarehouseID,
+		Kind:        req.Kind,
+		Quantity:    req.Quantity,
+		Reason:      req.Reason,
+		ActorID:     user.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrProductNotFound):
+			return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+		case errors.Is(err, repo.ErrWarehouseNotFound):
+			return c.JSON(http.StatusNotFound, response{Message: "Warehouse not found"})
+		case errors.Is(err, repo.ErrInsufficientStock):
+			return c.JSON(http.StatusBadRequest, response{Message: "Stock can't go below zero"})
+		case errors.Is(err, repo.ErrStockHeld):
+			return c.JSON(http.StatusConflict, response{Message: "Stock is held by customers checking out"})
+		}
+		return err
+	}
+
+	h.notifyIfBackInStock(*movement)
+
+	return c.JSON(http.StatusOK, AdjustStockResponse{Movement: movement})
+}
+
+// notifyIfBackInStock lets the waitlists of the products that the movements added stock to know, in the background. Products whose stock is still all held by checkouts are skipped by notifyBackInStock.
+func (h *Handler) notifyIfBackInStock(movements ...repo.InventoryMovement) {
+	productIDs := make([]int, 0)
+	for _, movement := range movements {
+		if movement.Quantity > 0 {
+			productIDs = append(productIDs, movement.ProductID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+		defer cancel()
+
+		for _, productID := range productIDs {
+			if err := h.notifyBackInStock(ctx, productID); err != nil {
+				h.Logger.Err(err).Int("productId", productID).Msg("Failed to notify waitlist")
+			}
+		}
+	}()
+}
+
+type GetStockHistoryRequest struct {
+	ProductID int `param:"id" validate:"required"`
+}
+
+type GetStockHistoryResponse struct {
+	Movements []repo.InventoryMovement `json:"movements"`
+}
+
+// @Summary Get stock history
+// @Description Get the inventory movements of a product, latest first.
+// @Router /_/products/{id}/stock-history [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetStockHistoryResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetStockHistory(c echo.Context) error {
+	var req GetStockHistoryRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	movements, err := h.Repo.GetInventoryMovements(c.Request().Context(), req.ProductID, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetStockHistoryResponse{Movements: movements})
+}
+
+type ReconcileStockRequest struct {
+	MismatchedOnly bool `query:"mismatchedOnly"`
+}
+
+type ReconcileStockResponse struct {
+	Products []repo.StockReconciliation `json:"products"`
+}
+
+// @Summary Reconcile stock
+// @Description Recompute the stock of every product from the inventory ledger and from its warehouses, and compare them with the stock on record.
+// @Router /_/inventory/reconciliation [get]
+// @Security ApiKeyAuth
+// @Param mismatchedOnly query bool false "Only return products whose stock doesn't match the ledger or warehouses"
+// @Success 200 {object} ReconcileStockResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) ReconcileStock(c echo.Context) error {
+	var req ReconcileStockRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	reconciliations, err := h.Repo.ReconcileStock(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	if req.MismatchedOnly {
+		mismatched := make([]repo.StockReconciliation, 0)
+		for _, rec := range reconciliations {
+			if !rec.IsConsistent {
+				mismatched = append(mismatched, rec)
+			}
+		}
+		reconciliations = mismatched
+	}
+
+	return c.JSON(http.StatusOK, ReconcileStockResponse{Products: reconciliations})
+}
+
+// checkStockConsistency logs every product whose stock doesn't add up to its inventory ledger or its warehouses.
+func (h *Handler) checkStockConsistency(ctx context.Context) error {
+	reconciliations, err := h.Repo.ReconcileStock(ctx)
+	if err != nil {
+		return err
+	}
+	for _, rec := range reconciliations {
+		if !rec.IsConsistent {
+			h.Logger.Warn().
+				Int("productId", rec.ProductID).
+				Int("quantityLeft", rec.QuantityLeft).
+				Int("ledgerQuantity", rec.LedgerQuantity).
+				Int("warehouseQuantity", rec.WarehouseQuantity).
+				Msg("Stock doesn't match inventory ledger or warehouses")
+		}
+	}
+	return nil
+}
+
+type SetLowStockThresholdRequest struct {
+	// Threshold is the stock at or below which admins are alerted. 0 turns alerts off.
+	Threshold *int `json:"threshold" validate:"required,min=0"`
+	ProductID int  `param:"id" validate:"required"`
+}
+
+// @Summary Set low stock threshold
+// @Description Set the stock at or below which admins are alerted about a product. 0 turns alerts off.
+// @Router /_/products/{id}/low-stock-threshold [put]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Param body body SetLowStockThresholdRequest true "Threshold"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "product not found"
+func (h *Handler) SetLowStockThreshold(c echo.Context) error {
+	var req SetLowStockThresholdRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.SetLowStockThreshold(c.Request().Context(), req.ProductID, *req.Threshold); err != nil {
+		if errors.Is(err, repo.ErrProductNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Low stock threshold updated"})
+}
+
+type GetLowStockProductsResponse struct {
+	Products []repo.Product `json:"products"`
+}
+
+// @Summary Get low stock products
+// @Description Get the products whose stock is at or below their low stock threshold, lowest stock first.
+// @Router /_/inventory/low-stock [get]
+// @Security ApiKeyAuth
+// @Success 200 {object} GetLowStockProductsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetLowStockProducts(c echo.Context) error {
+	products, err := h.Repo.GetLowStockProducts(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetLowStockProductsResponse{Products: products})
+}
+
+// sendLowStockDigest emails every admin a list of the products that are running low on stock.
+func (h *Handler) sendLowStockDigest(ctx context.Context) error {
+	products, err := h.Repo.GetLowStockProducts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return nil
+	}
+	admins, err := h.Repo.GetUserEmailsByRole(ctx, string(RoleAdmin))
+	if err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		h.Logger.Warn().Int("count", len(products)).Msg("Products are low on stock but there are no admins to alert")
+		return nil
+	}
+
+	// Each admin gets their own email, so that they don't see each other's addresses and a bad one doesn't fail the rest
+	for _, admin := range admins {
+		h.sendEmail(&email.BaseOpts{
+			Subject:     fmt.Sprintf("%d products are low on stock", len(products)),
+			ToAddresses: []string{admin},
+		}, "low-stock.tmpl", map[string]any{
+			"products": products,
+		})
+	}
+	return nil
+}
+
+// updateStockMetrics exports the stock of every product but gift cards to Prometheus.
+func (h *Handler) updateStockMetrics(ctx context.Context) error {
+	products, err := h.Repo.GetProducts(ctx)
+	if err != nil {
+		return err
+	}
+
+	productQuantityLeft.Reset()
+	productLowStock.Reset()
+	for _, p := range products {
+		if p.IsGiftCard {
+			continue
+		}
+		id := strconv.Itoa(p.ID)
+		productQuantityLeft.WithLabelValues(id, p.Name).Set(float64(p.QuantityLeft))
+		lowStock := 0.0
+		if p.LowStockThreshold > 0 && p.QuantityLeft <= p.LowStockThreshold {
+			lowStock = 1
+		}
+		productLowStock.WithLabelValues(id, p.Name).Set(lowStock)
+	}
+	return nil
+}
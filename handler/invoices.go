@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/blobstore"
+	"github.com/rohitxdev/go-api-starter/invoice"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// issueInvoice numbers the invoice of the order, if it doesn't have one yet, and stores its PDF in the blob store. The PDF is returned if it had to be rendered, i.e. unless it was already stored.
+func (h *Handler) issueInvoice(ctx context.Context, order *repo.Order, customer *repo.User) (*repo.Invoice, []byte, error) {
+	inv, err := h.Repo.CreateInvoice(ctx, order.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inv.FileName != nil {
+		return inv, nil, nil
+	}
+
+	items, totals, payments, err := h.describeOrder(ctx, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	billTo := []string{customer.Email}
+	if customer.FullName != nil && *customer.FullName != "" {
+		billTo = append([]string{*customer.FullName}, billTo...)
+	}
+	date := inv.CreatedAt
+	if len(date) > len("2006-01-02") {
+		date = date[:len("2006-01-02")]
+	}
+	pdf := invoice.Render(&invoice.Invoice{
+		Number:    inv.InvoiceNumber,
+		Date:      date,
+		Seller:    h.Config.AppName,
+		BillTo:    billTo,
+		Reference: fmt.Sprintf("Order #%d", order.ID),
+		Lines:     items,
+		Totals:    totals,
+		Total:     formatAmount(order.TotalAmount),
+		Payments:  payments,
+	})
+
+	fileName := fmt.Sprintf("invoices/%d/%s.pdf", inv.Year, inv.InvoiceNumber)
+	err = h.BlobStore.Upload(ctx, &blobstore.UploadParams{
+		BucketName:  h.Config.S3BucketName,
+		FileName:    fileName,
+		ContentType: "application/pdf",
+		Body:        bytes.NewReader(pdf),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to upload invoice: %w", err)
+	}
+	if err = h.Repo.SetInvoiceFile(ctx, inv.ID, fileName); err != nil {
+		return nil, nil, err
+	}
+	inv.FileName = &fileName
+	return inv, pdf, nil
+}
+
+type GetInvoiceRequest struct {
+	OrderID int `param:"id" validate:"required"`
+}
+
+// @Summary Get invoice
+// @Description Redirect to a short-lived link to the PDF invoice of the order. The invoice is issued on first request if the order doesn't have one yet. Admins can get the invoice of any order.
+// @Router /orders/{id}/invoice [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Order ID"
+// @Success 302
+// @Failure 400 {string} string "order can't be invoiced"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "order not found"
+func (h *Handler) GetInvoice(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req GetInvoiceRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	order, err := h.Repo.GetOrder(ctx, req.OrderID)
+	if err != nil {
+		if errors.Is(err, repo.ErrOrderNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Order not found"})
+		}
+		return err
+	}
+	customer := user
+	if order.UserID != user.ID {
+		if user.Role != string(RoleAdmin) {
+			return c.JSON(http.StatusNotFound, response{Message: "Order not found"})
+		}
+		if customer, err = h.Repo.GetUserById(ctx, order.UserID); err != nil {
+			return err
+		}
+	}
+
+	inv, _, err := h.issueInvoice(ctx, order, customer)
+	if err != nil {
+		if errors.Is(err, repo.ErrOrderNotInvoiceable) {
+			return c.JSON(http.StatusBadRequest, response{Message: "Order can't be invoiced"})
+		}
+		return err
+	}
+
+	url, err := h.BlobStore.Get(ctx, &blobstore.GetParams{
+		BucketName: h.Config.S3BucketName,
+		FileName:   *inv.FileName,
+		ExpiresIn:  time.Minute * 5,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, url)
+}
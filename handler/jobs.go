@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+func setUpJobs(svc *Services) {
+	if svc.Jobs == nil {
+		return
+	}
+	h := &Handler{svc}
+
+	svc.Jobs.Every("release-expired-reservations", time.Minute, h.releaseExpiredReservations)
+	svc.Jobs.Every("check-stock-consistency", time.Hour*24, h.checkStockConsistency)
+	svc.Jobs.Every("send-low-stock-digest", time.Hour*24, h.sendLowStockDigest)
+	svc.Jobs.Every("update-stock-metrics", time.Minute, h.updateStockMetrics)
+	svc.Jobs.Every("send-cart-reminders", time.Hour, h.sendCartReminders)
+	svc.Jobs.Every("expire-points", time.Hour, h.expirePoints)
+	svc.Jobs.Every("delete-expired-idempotency-keys", time.Hour, h.deleteExpiredIdempotencyKeys)
+	svc.Jobs.Every("refresh-product-affinities", time.Hour*24, h.refreshProductAffinities)
+	svc.Jobs.Every("place-subscription-orders", time.Minute*15, h.placeSubscriptionOrders)
+}
+
+func (h *Handler) releaseExpiredReservations(ctx context.Context) error {
+	productIDs, err := h.Repo.DeleteExpiredReservations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+	h.Logger.Info().Int("count", len(productIDs)).Msg("Released expired reservations")
+
+	// The released stock may be all that the waitlists of the products were waiting for
+	slices.Sort(productIDs)
+	for _, productID := range slices.Compact(productIDs) {
+		if err = h.notifyBackInStock(ctx, productID); err != nil {
+			h.Logger.Err(err).Int("productId", productID).Msg("Failed to notify waitlist")
+		}
+	}
+	return nil
+}
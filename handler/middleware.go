@@ -5,6 +5,7 @@ import (
 
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
 )
 
 type role string
@@ -19,24 +20,36 @@ var roles = map[role]uint8{
 	RoleAdmin: 2,
 }
 
+// sessionUser returns the logged in user, or nil if the request has no session.
+func (h *Handler) sessionUser(c echo.Context) (*repo.User, error) {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return nil, err
+	}
+	userID, ok := sess.Values["userId"].(int)
+	if !ok {
+		return nil, nil
+	}
+	user, err := h.Repo.GetUserById(c.Request().Context(), userID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, err)
+	}
+	if user.AccountStatus != "active" {
+		return nil, echo.ErrForbidden
+	}
+	return user, nil
+}
+
 func (h *Handler) require(r role) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			sess, err := session.Get("session", c)
+			user, err := h.sessionUser(c)
 			if err != nil {
 				return err
 			}
-			userID, ok := sess.Values["userId"].(int)
-			if !ok {
+			if user == nil {
 				return echo.ErrUnauthorized
 			}
-			user, err := h.Repo.GetUserById(c.Request().Context(), userID)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, err)
-			}
-			if user.AccountStatus != "active" {
-				return echo.ErrForbidden
-			}
 			if roles[role(user.Role)] < roles[role(r)] {
 				return echo.ErrForbidden
 			}
@@ -45,3 +58,17 @@ func (h *Handler) require(r role) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// allowGuest lets through visitors who are not logged in. The user is set only if there is a session.
+func (h *Handler) allowGuest(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, err := h.sessionUser(c)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			c.Set("user", user)
+		}
+		return next(c)
+	}
+}
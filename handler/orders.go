@@ -3,17 +3,26 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
-	"github.com/oklog/ulid/v2"
+	"github.com/rohitxdev/go-api-starter/allocation"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/invoice"
+	"github.com/rohitxdev/go-api-starter/pricing"
 	"github.com/rohitxdev/go-api-starter/repo"
 )
 
 type CreateOrderRequest struct {
-	CouponCode string `query:"couponCode"`
+	CouponCode     string `query:"couponCode"`
+	GiftCardCode   string `query:"giftCardCode"`
+	Points         int    `query:"points"`
+	UseStoreCredit bool   `query:"useStoreCredit"`
 }
 
 type CreateOrderResponse struct {
@@ -21,13 +30,22 @@ type CreateOrderResponse struct {
 }
 
 // @Summary Create order
-// @Description Create order.
+// @Description Create order, optionally spending loyalty points on it. Fewer points are spent if the order is worth less than them. The total can be paid, in part or in full, with a gift card and then with store credit. The points, coupons and gift cards that the order earns are returned with it. The user is emailed a receipt with the invoice attached, and the codes of the gift cards.
 // @Router /orders [post]
 // @Security ApiKeyAuth
 // @Param couponCode query string false "Coupon code"
+// @Param points query int false "Loyalty points to spend"
+// @Param giftCardCode query string false "Gift card code"
+// @Param useStoreCredit query bool false "Pay with store credit"
+// @Param latitude query number false "Latitude of the shipping address"
+// @Param longitude query number false "Longitude of the shipping address"
+// @Param Idempotency-Key header string false "Unique key of the order, to retry placing it safely"
 // @Success 200 {object} CreateOrderResponse
-// @Failure 400 {string} string "invalid coupon"
+// @Failure 400 {string} string "invalid coupon, gift card or location, not enough points or empty cart"
+// @Failure 404 {string} string "gift card not found"
 // @Failure 401 {string} string "invalid session"
+// @Failure 409 {string} string "out of stock, or an order with the same idempotency key is in progress"
+// @Failure 422 {string} string "idempotency key was used for a different request"
 func (h *Handler) CreateOrder(c echo.Context) error {
 	user := getUser(c)
 	if user == nil {
@@ -36,80 +54,242 @@ func (h *Handler) CreateOrder(c echo.Context) error {
 
 	var req CreateOrderRequest
 	req.CouponCode = c.QueryParam("couponCode")
-
-	var coupon *repo.Coupon
-	if req.CouponCode != "" {
-		coupons, err := h.Repo.GetAvailableCoupons(c.Request().Context(), user.ID)
-		if err != nil {
-			return err
-		}
-		for _, c := range coupons {
-			if c.Code == req.CouponCode {
-				coupon = &c
-				break
-			}
+	req.GiftCardCode = c.QueryParam("giftCardCode")
+	req.UseStoreCredit = c.QueryParam("useStoreCredit") == "true"
+	if points := c.QueryParam("points"); points != "" {
+		var err error
+		if req.Points, err = strconv.Atoi(points); err != nil || req.Points < 0 {
+			return c.JSON(http.StatusBadRequest, response{Message: "Invalid points"})
 		}
-		if coupon.IsUsed || coupon.UserID != user.ID {
-			return c.JSON(http.StatusBadRequest, response{Message: "Invalid coupon"})
+	}
+	// The location of the customer is optional, but both of its coordinates are needed to ship from the nearest warehouses
+	var destination *allocation.Location
+	if latitude, longitude := c.QueryParam("latitude"), c.QueryParam("longitude"); latitude != "" || longitude != "" {
+		var latErr, lonErr error
+		destination = &allocation.Location{}
+		destination.Latitude, latErr = strconv.ParseFloat(latitude, 64)
+		destination.Longitude, lonErr = strconv.ParseFloat(longitude, 64)
+		if latErr != nil || lonErr != nil || math.Abs(destination.Latitude) > 90 || math.Abs(destination.Longitude) > 180 {
+			return c.JSON(http.StatusBadRequest, response{Message: "Invalid location"})
 		}
 	}
 
-	var preTotalAmount int
-
-	cartItems, products, err := h.Repo.GetCartWithProducts(c.Request().Context(), user.ID)
+	cartItems, err := h.Repo.GetCart(c.Request().Context(), user.ID)
 	if err != nil {
 		return err
 	}
 
-	if len(cartItems) != len(products) {
-		return errors.New("cart and products length mismatch")
-	}
-
-	for i, cartItem := range cartItems {
-		preTotalAmount += cartItem.Quantity * products[i].Price
-	}
-
-	order, err := h.Repo.CreateOrder(c.Request().Context(), cartItems, user.ID, preTotalAmount, coupon)
+	order, err := h.Repo.CreateOrder(c.Request().Context(), &repo.CreateOrderParams{
+		Cart:           cartItems,
+		UserID:         user.ID,
+		Pricing:        h.pricingOptions(),
+		Points:         h.pointsProgram(),
+		CouponCode:     req.CouponCode,
+		RedeemPoints:   req.Points,
+		GiftCardCode:   req.GiftCardCode,
+		UseStoreCredit: req.UseStoreCredit,
+		Allocation:     h.allocationStrategy(),
+		Destination:    destination,
+	})
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, repo.ErrCartEmpty):
+			return c.JSON(http.StatusBadRequest, response{Message: "Cart is empty"})
+		case errors.Is(err, repo.ErrInsufficientPoints):
+			return c.JSON(http.StatusBadRequest, response{Message: "Not enough points"})
+		case errors.Is(err, repo.ErrInsufficientStock):
+			return c.JSON(http.StatusConflict, response{Message: "Some items in the cart are out of stock"})
+		case errors.Is(err, repo.ErrGiftCardNotFound), errors.Is(err, repo.ErrGiftCardNotActive), errors.Is(err, repo.ErrGiftCardExpired), errors.Is(err, repo.ErrGiftCardEmpty):
+			return giftCardError(c, err)
+		}
+		return couponError(c, err)
 	}
 
 	if err = h.Repo.DiscardCart(c.Request().Context(), user.ID); err != nil {
 		return err
 	}
 
-	// Create coupon for next order if user is eligible
+	if len(order.GiftCards) > 0 {
+		h.sendGiftCards(user.Email, fmt.Sprintf("Your gift cards from order #%d", order.ID), "", order.GiftCards)
+	}
+
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 		defer cancel()
 
-		coupons, err := h.Repo.GetAvailableCoupons(ctx, user.ID)
-		if err != nil {
-			h.Logger.Err(err).Msg("Failed to get available coupons")
-			return
+		if err := h.sendReceipt(ctx, order, user); err != nil {
+			h.Logger.Err(err).Int("orderId", order.ID).Msg("Failed to send receipt")
 		}
-		// Return if user already has a coupon
-		if len(coupons) > 0 {
-			return
+	}()
+
+	return c.JSON(http.StatusCreated, CreateOrderResponse{Order: order})
+}
+
+// sendReceipt emails an itemised receipt of the order, with the discounts it got and the rewards it earned. The invoice of the order is attached to it, unless it couldn't be issued or was issued before.
+func (h *Handler) sendReceipt(ctx context.Context, order *repo.Order, customer *repo.User) error {
+	items, totals, payments, err := h.describeOrder(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	rewards := make([]map[string]any, 0, len(order.Rewards))
+	for _, coupon := range order.Rewards {
+		discount := formatAmount(coupon.DiscountValue) + " off"
+		if coupon.DiscountType == repo.CouponPercent {
+			discount = fmt.Sprintf("%d%% off", coupon.DiscountValue)
 		}
-		ordersCount, err := h.Repo.GetOrdersCountForUser(ctx, user.ID)
-		if err != nil {
-			h.Logger.Err(err).Msg("Failed to get orders count for user")
-			return
+		var expiresAt string
+		if coupon.ExpiresAt != nil && len(*coupon.ExpiresAt) >= len("2006-01-02") {
+			expiresAt = (*coupon.ExpiresAt)[:len("2006-01-02")]
 		}
+		rewards = append(rewards, map[string]any{
+			"code":      coupon.Code,
+			"discount":  discount,
+			"expiresAt": expiresAt,
+		})
+	}
 
-		// Create a coupon if orders count is divisible by 5
-		if (ordersCount+1)%5 != 0 {
-			return
+	var attachments []email.Attachment
+	if _, pdf, err := h.issueInvoice(ctx, order, customer); err != nil {
+		h.Logger.Err(err).Int("orderId", order.ID).Msg("Failed to issue invoice")
+	} else if pdf != nil {
+		// An invoice that was issued before is left out, as it can be downloaded from the order
+		attachments = append(attachments, email.Attachment{
+			Filename:    fmt.Sprintf("invoice-%d.pdf", order.ID),
+			ContentType: "application/pdf",
+			Data:        pdf,
+		})
+	}
+
+	h.sendEmailWithRetries(&email.BaseOpts{
+		Subject:     fmt.Sprintf("Receipt for your order #%d", order.ID),
+		ToAddresses: []string{customer.Email},
+	}, "order-receipt.tmpl", "order-receipt.txt.tmpl", map[string]any{
+		"orderId":      order.ID,
+		"items":        items,
+		"totals":       totals,
+		"total":        formatAmount(order.TotalAmount),
+		"payments":     payments,
+		"pointsEarned": order.PointsEarned,
+		"rewards":      rewards,
+	}, attachments...)
+	return nil
+}
+
+// describeOrder returns the items of the order, the subtotal, discounts, tax and shipping that make up its total, and how it was paid for, ready to be printed on a receipt or an invoice.
+func (h *Handler) describeOrder(ctx context.Context, order *repo.Order) (items []invoice.Line, totals []invoice.Total, payments []invoice.Total, err error) {
+	orderItems, err := h.Repo.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+
+	var subtotal int
+	items = make([]invoice.Line, 0, len(orderItems))
+	for _, item := range orderItems {
+		name := fmt.Sprintf("Product #%d", item.ProductID)
+		if product, err := h.Repo.GetProduct(ctx, item.ProductID); err == nil {
+			name = product.Name
 		}
+		subtotal += item.Price * item.Quantity
+		items = append(items, invoice.Line{
+			Description: name,
+			Quantity:    item.Quantity,
+			UnitPrice:   formatAmount(item.Price),
+			Amount:      formatAmount(item.Price * item.Quantity),
+		})
+	}
+
+	totals = []invoice.Total{{Label: "Subtotal", Amount: formatAmount(subtotal)}}
+	for _, promotion := range order.Promotions {
+		totals = append(totals, invoice.Total{Label: promotion.Name, Amount: formatAmount(-promotion.Discount)})
+	}
+	if order.DiscountedAmount > 0 {
+		totals = append(totals, invoice.Total{Label: strings.TrimSpace("Coupon " + order.CouponCode), Amount: formatAmount(-order.DiscountedAmount)})
+	}
+	if order.PointsDiscount > 0 {
+		totals = append(totals, invoice.Total{Label: fmt.Sprintf("%d loyalty points", order.PointsRedeemed), Amount: formatAmount(-order.PointsDiscount)})
+	}
+	totals = append(totals,
+		invoice.Total{Label: "Tax", Amount: formatAmount(order.TaxAmount)},
+		invoice.Total{Label: "Shipping", Amount: formatAmount(order.ShippingAmount)},
+	)
+
+	payments = make([]invoice.Total, 0)
+	if order.GiftCardAmount > 0 {
+		payments = append(payments, invoice.Total{Label: "Paid with gift card", Amount: formatAmount(order.GiftCardAmount)})
+	}
+	if order.StoreCreditAmount > 0 {
+		payments = append(payments, invoice.Total{Label: "Paid with store credit", Amount: formatAmount(order.StoreCreditAmount)})
+	}
+	if len(payments) > 0 {
+		payments = append(payments, invoice.Total{Label: "Paid", Amount: formatAmount(order.TotalAmount - order.GiftCardAmount - order.StoreCreditAmount)})
+	}
+	return items, totals, payments, nil
+}
+
+// pricingOptions returns the tax and shipping settings that every cart and order is priced with.
+func (h *Handler) pricingOptions() pricing.Options {
+	return pricing.Options{
+		TaxRate:               h.Config.TaxRate,
+		ShippingFee:           h.Config.ShippingFee,
+		FreeShippingThreshold: h.Config.FreeShippingThreshold,
+	}
+}
+
+// allocationStrategy returns how the warehouses that orders ship from are picked.
+func (h *Handler) allocationStrategy() allocation.Strategy {
+	return allocation.Strategy(h.Config.AllocationStrategy)
+}
+
+type StartCheckoutResponse struct {
+	Reservations []repo.Reservation `json:"reservations"`
+}
+
+// @Summary Start checkout
+// @Description Hold the stock of every item in the cart for a limited time, so that it can't be sold to anyone else until the order is placed.
+// @Router /orders/checkout [post]
+// @Security ApiKeyAuth
+// @Success 200 {object} StartCheckoutResponse
+// @Failure 400 {string} string "cart is empty"
+// @Failure 401 {string} string "invalid session"
+// @Failure 409 {string} string "out of stock"
+func (h *Handler) StartCheckout(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
 
-		if _, err = h.Repo.CreateCoupon(ctx, user.ID, ulid.Make().String(), 10); err != nil {
-			h.Logger.Err(err).Msg("Failed to create coupon")
-			return
+	reservations, err := h.Repo.ReserveCart(c.Request().Context(), user.ID, h.Config.ReservationDuration)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrCartEmpty):
+			return c.JSON(http.StatusBadRequest, response{Message: "Cart is empty"})
+		case errors.Is(err, repo.ErrInsufficientStock):
+			return c.JSON(http.StatusConflict, response{Message: "Some items in the cart are out of stock"})
 		}
-	}()
+		return err
+	}
 
-	return c.JSON(http.StatusCreated, CreateOrderResponse{Order: order})
+	return c.JSON(http.StatusOK, StartCheckoutResponse{Reservations: reservations})
+}
+
+// @Summary Cancel checkout
+// @Description Release the stock held for checkout.
+// @Router /orders/checkout [delete]
+// @Security ApiKeyAuth
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) CancelCheckout(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	if err := h.Repo.ReleaseReservations(c.Request().Context(), user.ID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Checkout cancelled."})
 }
 
 type GetAllOrdersRequest struct {
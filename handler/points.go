@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+type GetPointsResponse struct {
+	Points  *repo.PointsBalance `json:"points"`
+	History []repo.PointsEntry  `json:"history"`
+	// PointValue is what a point is worth at checkout, in the smallest unit of the currency.
+	PointValue int `json:"pointValue"`
+}
+
+// @Summary Get points
+// @Description Get the loyalty points balance of the user, the points that expire next, and the points ledger, latest first.
+// @Router /me/points [get]
+// @Security ApiKeyAuth
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetPointsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetPoints(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	balance, err := h.Repo.GetPointsBalance(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	history, err := h.Repo.GetPointsHistory(c.Request().Context(), user.ID, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetPointsResponse{Points: balance, History: history, PointValue: h.Config.PointValue})
+}
+
+// pointsProgram returns the loyalty points settings that every order is placed with.
+func (h *Handler) pointsProgram() repo.PointsProgram {
+	return repo.PointsProgram{
+		EarnRate:     h.Config.PointsEarnRate,
+		PointValue:   h.Config.PointValue,
+		ExpiryMonths: h.Config.PointsExpiryMonths,
+	}
+}
+
+func (h *Handler) expirePoints(ctx context.Context) error {
+	count, err := h.Repo.ExpirePoints(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		h.Logger.Info().Int64("count", count).Msg("Expired loyalty points")
+	}
+	return nil
+}
@@ -81,4 +81,46 @@ func TestGetProducts(t *testing.T) {
 		h.ServeHTTP(res, req)
 		assert.Equal(t, http.StatusOK, res.Code)
 	})
+
+	t.Run("GET /products/:id/recommendations", func(t *testing.T) {
+		type args struct {
+			reqOpts *httpRequestOpts
+		}
+		tests := []struct {
+			name       string
+			args       args
+			wantStatus int
+		}{
+			{
+				name: "Missing product",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodGet,
+						path:   "/products/999999/recommendations",
+					},
+				},
+				wantStatus: http.StatusNotFound,
+			},
+			{
+				name: "Invalid limit",
+				args: args{
+					reqOpts: &httpRequestOpts{
+						method: http.MethodGet,
+						path:   "/products/1/recommendations?limit=100",
+					},
+				},
+				wantStatus: http.StatusUnprocessableEntity,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, err := createHttpRequest(tt.args.reqOpts)
+				assert.Nil(t, err)
+				res := httptest.NewRecorder()
+				h.ServeHTTP(res, req)
+				assert.Equal(t, tt.wantStatus, res.Code)
+			})
+		}
+	})
 }
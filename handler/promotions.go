@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/pricing"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+type PromotionTier struct {
+	MinQuantity int `json:"minQuantity" validate:"required,min=1"`
+	Percent     int `json:"percent" validate:"required,min=1,max=100"`
+}
+
+type PromotionRequest struct {
+	StartsAt *time.Time `json:"startsAt"`
+	EndsAt   *time.Time `json:"endsAt"`
+	IsActive *bool      `json:"isActive"`
+	// IsStackable defaults to true. A product discounted by a promotion that isn't stackable gets no other promotion.
+	IsStackable *bool  `json:"isStackable"`
+	Name        string `json:"name" validate:"required,max=128"`
+	Kind        string `json:"kind" validate:"required,oneof=buy_x_get_y category_percent quantity_tiers"`
+	// Category is required for category_percent promotions.
+	Category string `json:"category" validate:"max=64"`
+	// ProductIDs limits buy_x_get_y and quantity_tiers promotions to these products. Leave empty for every product.
+	ProductIDs []int `json:"productIds" validate:"dive,min=1"`
+	// Tiers are required for quantity_tiers promotions.
+	Tiers []PromotionTier `json:"tiers" validate:"dive"`
+	// BuyQuantity and GetQuantity are required for buy_x_get_y promotions. GetPercent is how much is taken off the units got, 100 for free.
+	BuyQuantity int `json:"buyQuantity" validate:"min=0"`
+	GetQuantity int `json:"getQuantity" validate:"min=0"`
+	GetPercent  int `json:"getPercent" validate:"min=0,max=100"`
+	// Percent is required for category_percent promotions.
+	Percent  int `json:"percent" validate:"min=0,max=100"`
+	Priority int `json:"priority"`
+}
+
+// params checks the fields that depend on the kind of promotion, and returns the request as repo params.
+func (req *PromotionRequest) params() (*repo.PromotionParams, error) {
+	switch {
+	case req.Kind == pricing.PromotionBuyXGetY && (req.BuyQuantity == 0 || req.GetQuantity == 0 || req.GetPercent == 0):
+		return nil, errors.New("Buy quantity, get quantity and get percent are required for buy x get y promotions")
+	case req.Kind == pricing.PromotionCategoryPercent && (req.Category == "" || req.Percent == 0):
+		return nil, errors.New("Category and percent are required for category promotions")
+	case req.Kind == pricing.PromotionQuantityTiers && len(req.Tiers) == 0:
+		return nil, errors.New("Tiers are required for quantity tier promotions")
+	case req.StartsAt != nil && req.EndsAt != nil && !req.StartsAt.Before(*req.EndsAt):
+		return nil, errors.New("Promotion must start before it ends")
+	}
+
+	p := &repo.PromotionParams{
+		Name:        req.Name,
+		Kind:        req.Kind,
+		Priority:    req.Priority,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		IsStackable: req.IsStackable == nil || *req.IsStackable,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	}
+	// Only keep the fields that the kind of promotion uses
+	switch req.Kind {
+	case pricing.PromotionBuyXGetY:
+		p.ProductIDs = req.ProductIDs
+		p.BuyQuantity = req.BuyQuantity
+		p.GetQuantity = req.GetQuantity
+		p.GetPercent = req.GetPercent
+	case pricing.PromotionCategoryPercent:
+		p.Category = req.Category
+		p.Percent = req.Percent
+	case pricing.PromotionQuantityTiers:
+		p.ProductIDs = req.ProductIDs
+		for _, tier := range req.Tiers {
+			p.Tiers = append(p.Tiers, pricing.Tier{MinQuantity: tier.MinQuantity, Percent: tier.Percent})
+		}
+	}
+	return p, nil
+}
+
+type GetPromotionsResponse struct {
+	Promotions []repo.Promotion `json:"promotions"`
+}
+
+// @Summary Get promotions
+// @Description Get all promotions, highest priority first.
+// @Router /_/promotions [get]
+// @Security ApiKeyAuth
+// @Success 200 {object} GetPromotionsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetPromotions(c echo.Context) error {
+	promotions, err := h.Repo.GetPromotions(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetPromotionsResponse{Promotions: promotions})
+}
+
+type PromotionResponse struct {
+	Promotion *repo.Promotion `json:"promotion"`
+}
+
+// @Summary Create promotion
+// @Description Create a promotion that is applied automatically to every cart that qualifies for it: buy x get y, a percentage off a category, or a percentage off by quantity tiers. Promotions are applied highest priority first, before coupons.
+// @Router /_/promotions [post]
+// @Security ApiKeyAuth
+// @Param body body PromotionRequest true "Promotion"
+// @Success 201 {object} PromotionResponse
+// @Failure 400 {string} string "invalid request"
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) CreatePromotion(c echo.Context) error {
+	var req PromotionRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	p, err := req.params()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	promotion, err := h.Repo.CreatePromotion(c.Request().Context(), p)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, PromotionResponse{Promotion: promotion})
+}
+
+type UpdatePromotionRequest struct {
+	PromotionRequest
+	ID int `param:"id" validate:"required"`
+}
+
+// @Summary Update promotion
+// @Description Replace a promotion. Set isActive to false to end it early. Orders it was already applied to are not affected.
+// @Router /_/promotions/{id} [put]
+// @Security ApiKeyAuth
+// @Param id path int true "Promotion ID"
+// @Param body body PromotionRequest true "Promotion"
+// @Success 200 {object} PromotionResponse
+// @Failure 400 {string} string "invalid request"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "promotion not found"
+func (h *Handler) UpdatePromotion(c echo.Context) error {
+	var req UpdatePromotionRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	p, err := req.params()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	promotion, err := h.Repo.UpdatePromotion(c.Request().Context(), req.ID, p)
+	if err != nil {
+		if errors.Is(err, repo.ErrPromotionNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Promotion not found"})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, PromotionResponse{Promotion: promotion})
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+const (
+	// affinityLookback is how far back the order history goes when products are related.
+	affinityLookback = time.Hour * 24 * 365
+	// affinityMinOrders is how many orders must have had two products for them to be related, so that a single order doesn't make a recommendation.
+	affinityMinOrders = 2
+	// affinitiesPerProduct is how many related products are kept for each product.
+	affinitiesPerProduct = 20
+	// cartRecommendationsLimit is how many recommendations the cart summary has.
+	cartRecommendationsLimit = 5
+)
+
+type GetRecommendationsRequest struct {
+	ProductID int `param:"id" validate:"required"`
+	Limit     int `query:"limit" validate:"omitempty,min=1,max=20"`
+}
+
+type GetRecommendationsResponse struct {
+	Recommendations []repo.Recommendation `json:"recommendations"`
+}
+
+// @Summary Get recommendations
+// @Description Get the products that are frequently bought together with the product, best first. Products that are out of stock are left out.
+// @Router /products/{id}/recommendations [get]
+// @Param id path int true "Product ID"
+// @Param limit query int false "Number of recommendations, 10 by default"
+// @Success 200 {object} GetRecommendationsResponse
+// @Failure 404 {string} string "product not found"
+func (h *Handler) GetRecommendations(c echo.Context) error {
+	var req GetRecommendationsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	ctx := c.Request().Context()
+	if _, err := h.Repo.GetProduct(ctx, req.ProductID); err != nil {
+		if err == repo.ErrProductNotFound {
+			return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+		}
+		return err
+	}
+	recommendations, err := h.Repo.GetRecommendations(ctx, req.ProductID, req.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetRecommendationsResponse{Recommendations: recommendations})
+}
+
+// refreshProductAffinities relates the products that are bought together from the recent order history.
+func (h *Handler) refreshProductAffinities(ctx context.Context) error {
+	count, err := h.Repo.RefreshProductAffinities(ctx, &repo.RefreshProductAffinitiesParams{
+		Since:         time.Now().Add(-affinityLookback),
+		MinOrders:     affinityMinOrders,
+		MaxPerProduct: affinitiesPerProduct,
+	})
+	if err != nil {
+		return err
+	}
+	h.Logger.Info().Int64("count", count).Msg("Refreshed product affinities")
+	return nil
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+type CreateRefundItem struct {
+	OrderItemID int `json:"orderItemId" validate:"required"`
+	Quantity    int `json:"quantity" validate:"required,min=1"`
+}
+
+type CreateRefundRequest struct {
+	Reason string `json:"reason" validate:"max=512"`
+	// Items to be refunded. Leave empty to refund the whole order.
+	Items   []CreateRefundItem `json:"items" validate:"dive"`
+	OrderID int                `param:"id" validate:"required"`
+	// Restock puts the refunded quantities back into stock.
+	Restock bool `json:"restock"`
+	// ToStoreCredit refunds the whole amount as store credit instead of to the payment method.
+	ToStoreCredit bool `json:"toStoreCredit"`
+}
+
+type CreateRefundResponse struct {
+	Refund *repo.Refund `json:"refund"`
+}
+
+// @Summary Create refund
+// @Description Refund an order fully, or partially by order item. Coupon discounts are spread proportionally across the refunded items. Gift cards bought with the order are deactivated, and only what is left on them is refunded. The share of the order paid with gift cards and store credit is always refunded as store credit.
+// @Router /_/orders/{id}/refunds [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Order ID"
+// @Param body body CreateRefundRequest true "Refund"
+// @Success 201 {object} CreateRefundResponse
+// @Failure 400 {string} string "invalid refund"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "order not found"
+func (h *Handler) CreateRefund(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req CreateRefundRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	lines := make([]repo.RefundLine, 0, len(req.Items))
+	for _, item := range req.Items {
+		lines = append(lines, repo.RefundLine{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+
+	refund, err := h.Repo.CreateRefund(c.Request().Context(), &repo.CreateRefundParams{
+		OrderID:       req.OrderID,
+		Lines:         lines,
+		Restock:       req.Restock,
+		Reason:        req.Reason,
+		CreatedBy:     user.ID,
+		ToStoreCredit: req.ToStoreCredit,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrOrderNotFound):
+			return c.JSON(http.StatusNotFound, response{Message: "Order not found"})
+		case errors.Is(err, repo.ErrOrderNotRefundable):
+			return c.JSON(http.StatusBadRequest, response{Message: "Order is not refundable"})
+		case errors.Is(err, repo.ErrOrderItemNotFound):
+			return c.JSON(http.StatusBadRequest, response{Message: "Order item not found"})
+		case errors.Is(err, repo.ErrRefundQuantityExceeded):
+			return c.JSON(http.StatusBadRequest, response{Message: "Refund quantity exceeds purchased quantity"})
+		case errors.Is(err, repo.ErrNothingToRefund):
+			return c.JSON(http.StatusBadRequest, response{Message: "Order is already refunded"})
+		}
+		return err
+	}
+	h.notifyIfBackInStock(refund.Movements...)
+
+	if err := h.sendRefundNotice(c.Request().Context(), refund); err != nil {
+		h.Logger.Err(err).Int("refundId", refund.ID).Msg("Failed to send refund notice")
+	}
+
+	return c.JSON(http.StatusCreated, CreateRefundResponse{Refund: refund})
+}
+
+func (h *Handler) sendRefundNotice(ctx context.Context, refund *repo.Refund) error {
+	order, err := h.Repo.GetOrder(ctx, refund.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	customer, err := h.Repo.GetUserById(ctx, order.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	items := make([]map[string]any, 0, len(refund.Items))
+	for _, item := range refund.Items {
+		name := fmt.Sprintf("Product #%d", item.ProductID)
+		if product, err := h.Repo.GetProduct(ctx, item.ProductID); err == nil {
+			name = product.Name
+		}
+		items = append(items, map[string]any{
+			"name":     name,
+			"quantity": item.Quantity,
+			"amount":   formatAmount(item.Amount),
+		})
+	}
+
+	h.sendEmail(&email.BaseOpts{
+		Subject:     fmt.Sprintf("Refund for your order #%d", order.ID),
+		ToAddresses: []string{customer.Email},
+	}, "refund.tmpl", map[string]any{
+		"orderId": order.ID,
+		"items":   items,
+		"amount":  formatAmount(refund.Amount),
+		"reason":  refund.Reason,
+	})
+	return nil
+}
@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"github.com/rohitxdev/go-api-starter/blobstore"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// returnPhotoLinkDuration is how long the links to upload and view return photos work.
+const returnPhotoLinkDuration = time.Minute * 15
+
+// returnPhotoExtensions are the image types that can be uploaded as return photos.
+var returnPhotoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// returnError responds to the errors that return endpoints have in common.
+func returnError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repo.ErrReturnNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Return not found"})
+	case errors.Is(err, repo.ErrOrderNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Order not found"})
+	case errors.Is(err, repo.ErrOrderItemNotFound):
+		return c.JSON(http.StatusBadRequest, response{Message: "Order item not found"})
+	case errors.Is(err, repo.ErrOrderNotReturnable):
+		return c.JSON(http.StatusBadRequest, response{Message: "Order can't be returned"})
+	case errors.Is(err, repo.ErrReturnQuantityExceeded):
+		return c.JSON(http.StatusBadRequest, response{Message: "Return quantity exceeds what can be returned"})
+	case errors.Is(err, repo.ErrReturnStatusInvalid):
+		return c.JSON(http.StatusConflict, response{Message: "Return can't move to this status"})
+	case errors.Is(err, repo.ErrReturnPhotoLimitReached):
+		return c.JSON(http.StatusBadRequest, response{Message: fmt.Sprintf("A return can have up to %d photos", repo.MaxReturnPhotos)})
+	}
+	return err
+}
+
+// getReturn returns the return if the user may see it, i.e. if it is theirs or they are an admin, with links to its photos.
+func (h *Handler) getReturn(ctx context.Context, user *repo.User, id int) (*repo.Return, error) {
+	rt, err := h.Repo.GetReturn(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rt.UserID != user.ID && user.Role != string(RoleAdmin) {
+		return nil, repo.ErrReturnNotFound
+	}
+	for i := range rt.Photos {
+		rt.Photos[i].URL, err = h.BlobStore.Get(ctx, &blobstore.GetParams{
+			BucketName: h.Config.S3BucketName,
+			FileName:   rt.Photos[i].FileName,
+			ExpiresIn:  returnPhotoLinkDuration,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rt, nil
+}
+
+type CreateReturnRequest struct {
+	Reason      string `json:"reason" validate:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+	Comment     string `json:"comment" validate:"max=2000"`
+	OrderID     int    `param:"id" validate:"required"`
+	OrderItemID int    `json:"orderItemId" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,min=1"`
+}
+
+type ReturnResponse struct {
+	Return *repo.Return `json:"return"`
+}
+
+// @Summary Request return
+// @Description Request the return of an item of a completed order. Photos can be added to the request until an admin approves or rejects it.
+// @Router /orders/{id}/returns [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Order ID"
+// @Param body body CreateReturnRequest true "Return"
+// @Success 201 {object} ReturnResponse
+// @Failure 400 {string} string "invalid return"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "order not found"
+func (h *Handler) CreateReturn(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req CreateReturnRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	rt, err := h.Repo.CreateReturn(c.Request().Context(), &repo.CreateReturnParams{
+		Reason:      req.Reason,
+		Comment:     req.Comment,
+		UserID:      user.ID,
+		OrderID:     req.OrderID,
+		OrderItemID: req.OrderItemID,
+		Quantity:    req.Quantity,
+	})
+	if err != nil {
+		return returnError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, ReturnResponse{Return: rt})
+}
+
+type GetReturnsResponse struct {
+	Returns []repo.Return `json:"returns"`
+}
+
+// @Summary Get returns
+// @Description Get the returns of the user, latest first.
+// @Router /returns [get]
+// @Security ApiKeyAuth
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetReturnsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetReturns(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	returns, err := h.Repo.GetReturnsForUser(c.Request().Context(), user.ID, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetReturnsResponse{Returns: returns})
+}
+
+type ReturnIDRequest struct {
+	ID int `param:"id" validate:"required"`
+}
+
+// @Summary Get return
+// @Description Get a return with short-lived links to its photos. Admins can get any return.
+// @Router /returns/{id} [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} ReturnResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "return not found"
+func (h *Handler) GetReturn(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req ReturnIDRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	rt, err := h.getReturn(c.Request().Context(), user, req.ID)
+	if err != nil {
+		return returnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ReturnResponse{Return: rt})
+}
+
+type AddReturnPhotoRequest struct {
+	ContentType string `json:"contentType" validate:"required,oneof=image/jpeg image/png image/webp"`
+	ID          int    `param:"id" validate:"required"`
+}
+
+type AddReturnPhotoResponse struct {
+	Photo *repo.ReturnPhoto `json:"photo"`
+	// UploadURL is where the photo is to be uploaded, with a PUT request with the same content type.
+	UploadURL string `json:"uploadUrl"`
+}
+
+// @Summary Add return photo
+// @Description Get a short-lived link to upload a photo of the returned item to. Photos can be added until the return is approved or rejected.
+// @Router /returns/{id}/photos [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Return ID"
+// @Param body body AddReturnPhotoRequest true "Photo"
+// @Success 201 {object} AddReturnPhotoResponse
+// @Failure 400 {string} string "too many photos"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "return not found"
+// @Failure 409 {string} string "return is no longer open"
+func (h *Handler) AddReturnPhoto(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req AddReturnPhotoRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	rt, err := h.Repo.GetReturn(ctx, req.ID)
+	if err != nil {
+		return returnError(c, err)
+	}
+	if rt.UserID != user.ID {
+		return returnError(c, repo.ErrReturnNotFound)
+	}
+
+	fileName := fmt.Sprintf("returns/%d/%s%s", rt.ID, ulid.Make().String(), returnPhotoExtensions[req.ContentType])
+	photo, err := h.Repo.AddReturnPhoto(ctx, rt.ID, fileName)
+	if err != nil {
+		return returnError(c, err)
+	}
+	url, err := h.BlobStore.Put(ctx, &blobstore.PutParams{
+		BucketName:  h.Config.S3BucketName,
+		FileName:    fileName,
+		ContentType: req.ContentType,
+		ExpiresIn:   returnPhotoLinkDuration,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, AddReturnPhotoResponse{Photo: photo, UploadURL: url})
+}
+
+type GetAllReturnsRequest struct {
+	// Status defaults to requested, i.e. the returns waiting for approval.
+	Status string `query:"status" validate:"omitempty,oneof=requested approved rejected received inspected completed"`
+}
+
+// @Summary Get all returns
+// @Description Get the returns with a status, oldest first. By default, these are the returns waiting for approval.
+// @Router /_/returns [get]
+// @Security ApiKeyAuth
+// @Param status query string false "Status, requested by default"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetReturnsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetAllReturns(c echo.Context) error {
+	var req GetAllReturnsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Status == "" {
+		req.Status = repo.ReturnRequested
+	}
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	returns, err := h.Repo.GetReturnsByStatus(c.Request().Context(), req.Status, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetReturnsResponse{Returns: returns})
+}
+
+type UpdateReturnStatusRequest struct {
+	// Status moves requested returns to approved or rejected, approved ones to received, received ones to inspected and inspected ones to completed.
+	Status string `json:"status" validate:"required,oneof=approved rejected received inspected completed"`
+	Note   string `json:"note" validate:"max=512"`
+	// IsResellable is required for inspected. Resellable items are put back into stock when the return is completed.
+	IsResellable *bool `json:"isResellable"`
+	ID           int   `param:"id" validate:"required"`
+}
+
+type UpdateReturnStatusResponse struct {
+	Return *repo.Return `json:"return"`
+	// Refund is set when the return is completed.
+	Refund *repo.Refund `json:"refund,omitempty"`
+}
+
+// @Summary Update return status
+// @Description Move a return to its next status. Completing a return refunds the returned quantity and restocks it if it was found resellable on inspection.
+// @Router /_/returns/{id}/status [put]
+// @Security ApiKeyAuth
+// @Param id path int true "Return ID"
+// @Param body body UpdateReturnStatusRequest true "Status"
+// @Success 200 {object} UpdateReturnStatusResponse
+// @Failure 400 {string} string "invalid status"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "return not found"
+// @Failure 409 {string} string "return can't move to this status"
+func (h *Handler) UpdateReturnStatus(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req UpdateReturnStatusRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Status == repo.ReturnInspected && req.IsResellable == nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "Whether the item is resellable is required"})
+	}
+
+	rt, refund, err := h.Repo.UpdateReturnStatus(c.Request().Context(), &repo.UpdateReturnStatusParams{
+		Status:       req.Status,
+		Note:         req.Note,
+		IsResellable: req.IsResellable,
+		ID:           req.ID,
+		ActorID:      user.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrOrderNotRefundable), errors.Is(err, repo.ErrRefundQuantityExceeded):
+			return c.JSON(http.StatusConflict, response{Message: "The returned items were already refunded"})
+		}
+		return returnError(c, err)
+	}
+
+	if refund != nil {
+		h.notifyIfBackInStock(refund.Movements...)
+		if err := h.sendRefundNotice(c.Request().Context(), refund); err != nil {
+			h.Logger.Err(err).Int("refundId", refund.ID).Msg("Failed to send refund notice")
+		}
+	}
+
+	return c.JSON(http.StatusOK, UpdateReturnStatusResponse{Return: rt, Refund: refund})
+}
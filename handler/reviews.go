@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// reviewError responds to the errors that review endpoints have in common.
+func reviewError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repo.ErrReviewNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Review not found"})
+	case errors.Is(err, repo.ErrReviewAlreadyExists):
+		return c.JSON(http.StatusConflict, response{Message: "You have already reviewed this product"})
+	case errors.Is(err, repo.ErrReviewNotPurchased):
+		return c.JSON(http.StatusForbidden, response{Message: "Only customers who bought the product can review it"})
+	case errors.Is(err, repo.ErrProductNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+	}
+	return err
+}
+
+type ProductReviewsRequest struct {
+	ProductID int `param:"id" validate:"required"`
+}
+
+type GetProductReviewsResponse struct {
+	Reviews []repo.Review `json:"reviews"`
+}
+
+// @Summary Get product reviews
+// @Description Get the approved reviews of the product, latest first. The average rating and the number of ratings are part of the product.
+// @Router /products/{id}/reviews [get]
+// @Param id path int true "Product ID"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetProductReviewsResponse
+// @Failure 404 {string} string "product not found"
+func (h *Handler) GetProductReviews(c echo.Context) error {
+	var req ProductReviewsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	if _, err = h.Repo.GetProduct(ctx, req.ProductID); err != nil {
+		return reviewError(c, err)
+	}
+	reviews, err := h.Repo.GetProductReviews(ctx, req.ProductID, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetProductReviewsResponse{Reviews: reviews})
+}
+
+type ReviewRequest struct {
+	Body      string `json:"body" validate:"max=5000"`
+	ProductID int    `param:"id" validate:"required"`
+	Rating    int    `json:"rating" validate:"required,min=1,max=5"`
+}
+
+type ReviewResponse struct {
+	Review *repo.Review `json:"review"`
+}
+
+// @Summary Review product
+// @Description Rate a product from 1 to 5 stars, with an optional text. Only customers who bought the product and didn't get a full refund for it can review it, once. The review is shown once an admin approves it.
+// @Router /products/{id}/reviews [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Param body body ReviewRequest true "Review"
+// @Success 201 {object} ReviewResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 403 {string} string "product was not purchased"
+// @Failure 404 {string} string "product not found"
+// @Failure 409 {string} string "review already exists"
+func (h *Handler) CreateReview(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req ReviewRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	review, err := h.Repo.CreateReview(c.Request().Context(), user.ID, req.ProductID, req.Rating, req.Body)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, ReviewResponse{Review: review})
+}
+
+// @Summary Update review
+// @Description Change the rating and text of the user's review of the product. The review is hidden again until an admin approves it.
+// @Router /products/{id}/reviews [put]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Param body body ReviewRequest true "Review"
+// @Success 200 {object} ReviewResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "review not found"
+func (h *Handler) UpdateReview(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req ReviewRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	review, err := h.Repo.UpdateReview(c.Request().Context(), user.ID, req.ProductID, req.Rating, req.Body)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ReviewResponse{Review: review})
+}
+
+// @Summary Delete review
+// @Description Delete the user's review of the product.
+// @Router /products/{id}/reviews [delete]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "review not found"
+func (h *Handler) DeleteReview(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req ProductReviewsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.DeleteReview(c.Request().Context(), user.ID, req.ProductID); err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Review deleted"})
+}
+
+type GetReviewsRequest struct {
+	// Status defaults to pending, i.e. the reviews waiting for moderation.
+	Status string `query:"status" validate:"omitempty,oneof=pending approved rejected"`
+}
+
+type GetReviewsResponse struct {
+	Reviews []repo.Review `json:"reviews"`
+}
+
+// @Summary Get reviews
+// @Description Get the reviews with a status, oldest first. By default, these are the reviews waiting for moderation.
+// @Router /_/reviews [get]
+// @Security ApiKeyAuth
+// @Param status query string false "pending (default), approved or rejected"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetReviewsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetReviews(c echo.Context) error {
+	var req GetReviewsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Status == "" {
+		req.Status = repo.ReviewPending
+	}
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	reviews, err := h.Repo.GetReviewsByStatus(c.Request().Context(), req.Status, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetReviewsResponse{Reviews: reviews})
+}
+
+type SetReviewStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=approved rejected"`
+	ID     int    `param:"id" validate:"required"`
+}
+
+// @Summary Moderate review
+// @Description Approve or reject a review. Only approved reviews are shown and count towards the rating of the product.
+// @Router /_/reviews/{id}/status [put]
+// @Security ApiKeyAuth
+// @Param id path int true "Review ID"
+// @Param body body SetReviewStatusRequest true "Status"
+// @Success 200 {object} ReviewResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "review not found"
+func (h *Handler) SetReviewStatus(c echo.Context) error {
+	var req SetReviewStatusRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	review, err := h.Repo.SetReviewStatus(c.Request().Context(), req.ID, req.Status)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ReviewResponse{Review: review})
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+type RewardRuleRequest struct {
+	// OrderInterval is required for every_nth_order rules.
+	OrderInterval *int `json:"orderInterval" validate:"omitempty,min=1"`
+	// SpendThreshold is required for spend_threshold rules.
+	SpendThreshold *int `json:"spendThreshold" validate:"omitempty,min=1"`
+	MaxDiscount    *int `json:"maxDiscount" validate:"omitempty,min=1"`
+	// CouponValidDays is how long the issued coupon can be redeemed for. Leave empty for coupons that don't expire.
+	CouponValidDays *int   `json:"couponValidDays" validate:"omitempty,min=1"`
+	IsActive        *bool  `json:"isActive"`
+	Name            string `json:"name" validate:"required,max=128"`
+	Kind            string `json:"kind" validate:"required,oneof=every_nth_order spend_threshold first_order"`
+	DiscountType    string `json:"discountType" validate:"required,oneof=percent fixed"`
+	DiscountValue   int    `json:"discountValue" validate:"required,min=1"`
+}
+
+// params checks the fields that depend on the kind of rule, and returns the request as repo params.
+func (req *RewardRuleRequest) params() (*repo.RewardRuleParams, error) {
+	switch {
+	case req.Kind == repo.RewardEveryNthOrder && req.OrderInterval == nil:
+		return nil, errors.New("Order interval is required for every nth order rules")
+	case req.Kind == repo.RewardSpendThreshold && req.SpendThreshold == nil:
+		return nil, errors.New("Spend threshold is required for spend threshold rules")
+	case req.DiscountType == repo.CouponPercent && req.DiscountValue > 100:
+		return nil, errors.New("Discount can't be more than 100%")
+	}
+
+	p := &repo.RewardRuleParams{
+		Name:            req.Name,
+		Kind:            req.Kind,
+		DiscountType:    req.DiscountType,
+		DiscountValue:   req.DiscountValue,
+		MaxDiscount:     req.MaxDiscount,
+		CouponValidDays: req.CouponValidDays,
+		IsActive:        req.IsActive == nil || *req.IsActive,
+	}
+	// Only keep the field that the kind of rule uses
+	switch req.Kind {
+	case repo.RewardEveryNthOrder:
+		p.OrderInterval = req.OrderInterval
+	case repo.RewardSpendThreshold:
+		p.SpendThreshold = req.SpendThreshold
+	}
+	return p, nil
+}
+
+type GetRewardRulesResponse struct {
+	RewardRules []repo.RewardRule `json:"rewardRules"`
+}
+
+// @Summary Get reward rules
+// @Description Get all reward rules with how many coupons each has issued.
+// @Router /_/reward-rules [get]
+// @Security ApiKeyAuth
+// @Success 200 {object} GetRewardRulesResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetRewardRules(c echo.Context) error {
+	rules, err := h.Repo.GetRewardRules(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetRewardRulesResponse{RewardRules: rules})
+}
+
+type RewardRuleResponse struct {
+	RewardRule *repo.RewardRule `json:"rewardRule"`
+}
+
+// @Summary Create reward rule
+// @Description Create a rule that issues a personal coupon for every nth order of a user, for the order that takes their total spend over a threshold, or for their first order. Rules are evaluated when an order is placed, and fire at most once per order.
+// @Router /_/reward-rules [post]
+// @Security ApiKeyAuth
+// @Param body body RewardRuleRequest true "Reward rule"
+// @Success 201 {object} RewardRuleResponse
+// @Failure 400 {string} string "invalid request"
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) CreateRewardRule(c echo.Context) error {
+	var req RewardRuleRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	p, err := req.params()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	rule, err := h.Repo.CreateRewardRule(c.Request().Context(), p)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, RewardRuleResponse{RewardRule: rule})
+}
+
+type UpdateRewardRuleRequest struct {
+	RewardRuleRequest
+	ID int `param:"id" validate:"required"`
+}
+
+// @Summary Update reward rule
+// @Description Replace a reward rule. Set isActive to false to stop it from issuing coupons. Coupons that were already issued are not affected.
+// @Router /_/reward-rules/{id} [put]
+// @Security ApiKeyAuth
+// @Param id path int true "Reward rule ID"
+// @Param body body RewardRuleRequest true "Reward rule"
+// @Success 200 {object} RewardRuleResponse
+// @Failure 400 {string} string "invalid request"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "reward rule not found"
+func (h *Handler) UpdateRewardRule(c echo.Context) error {
+	var req UpdateRewardRuleRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	p, err := req.params()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	rule, err := h.Repo.UpdateRewardRule(c.Request().Context(), req.ID, p)
+	if err != nil {
+		if errors.Is(err, repo.ErrRewardRuleNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Reward rule not found"})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RewardRuleResponse{RewardRule: rule})
+}
@@ -9,10 +9,15 @@ import (
 func setUpRoutes(e *echo.Echo, svc *Services) {
 	h := &Handler{svc}
 
+	e.Use(h.idempotent)
+
 	e.GET("/metrics", echoprometheus.NewHandler())
 	e.GET("/swagger/*", echoSwagger.EchoWrapHandler())
 	e.GET("/config", h.GetConfig)
 	e.GET("/me", h.GetMe, h.require(RoleUser))
+	e.GET("/me/points", h.GetPoints, h.require(RoleUser))
+	e.GET("/me/store-credit", h.GetStoreCredit, h.require(RoleUser))
+	e.GET("/gift-cards/:code", h.GetGiftCardBalance, h.require(RoleUser))
 	e.GET("/", h.GetHome)
 
 	auth := e.Group("/auth")
@@ -25,19 +30,68 @@ func setUpRoutes(e *echo.Echo, svc *Services) {
 	products := e.Group("/products")
 	{
 		products.GET("", h.GetProducts)
+		products.POST("/:id/notify-me", h.SubscribeToStockNotification, h.require(RoleUser))
+		products.DELETE("/:id/notify-me", h.UnsubscribeFromStockNotification, h.require(RoleUser))
+		products.GET("/:id/notify-me/unsubscribe", h.UnsubscribeFromStockNotificationByToken)
+		products.GET("/:id/reviews", h.GetProductReviews)
+		products.POST("/:id/reviews", h.CreateReview, h.require(RoleUser))
+		products.PUT("/:id/reviews", h.UpdateReview, h.require(RoleUser))
+		products.DELETE("/:id/reviews", h.DeleteReview, h.require(RoleUser))
+		products.GET("/:id/recommendations", h.GetRecommendations)
 	}
 
 	cart := e.Group("/carts")
 	{
-		cart.GET("", h.GetCart, h.require(RoleUser))
-		cart.POST("/:productId", h.AddToCart, h.require(RoleUser))
-		cart.PUT("/:productId/:quantity", h.UpdateCartItemQuantity, h.require(RoleUser))
-		cart.DELETE("/:productId", h.DeleteCartItem, h.require(RoleUser))
+		cart.GET("", h.GetCart, h.allowGuest)
+		cart.GET("/summary", h.GetCartSummary, h.require(RoleUser))
+		cart.PUT("/reminders", h.SetCartReminders, h.require(RoleUser))
+		cart.GET("/reminders/unsubscribe", h.UnsubscribeFromCartReminders)
+		cart.PUT("", h.ReplaceCart, h.allowGuest)
+		cart.POST("/:productId", h.AddToCart, h.allowGuest)
+		cart.PUT("/:productId/:quantity", h.UpdateCartItemQuantity, h.allowGuest)
+		cart.DELETE("/:productId", h.DeleteCartItem, h.allowGuest)
+		cart.POST("/:productId/save-for-later", h.SaveForLater, h.require(RoleUser))
+	}
+
+	wishlists := e.Group("/wishlists")
+	{
+		wishlists.GET("", h.GetWishlists, h.require(RoleUser))
+		wishlists.POST("", h.CreateWishlist, h.require(RoleUser))
+		wishlists.GET("/shared/:token", h.GetSharedWishlist)
+		wishlists.GET("/:id", h.GetWishlist, h.require(RoleUser))
+		wishlists.DELETE("/:id", h.DeleteWishlist, h.require(RoleUser))
+		wishlists.POST("/:id/share", h.ShareWishlist, h.require(RoleUser))
+		wishlists.DELETE("/:id/share", h.UnshareWishlist, h.require(RoleUser))
+		wishlists.POST("/:id/items/:productId", h.AddToWishlist, h.require(RoleUser))
+		wishlists.DELETE("/:id/items/:productId", h.RemoveFromWishlist, h.require(RoleUser))
+		wishlists.POST("/:id/items/:productId/move-to-cart", h.MoveWishlistItemToCart, h.require(RoleUser))
 	}
 
 	orders := e.Group("/orders")
 	{
 		orders.POST("", h.CreateOrder, h.require(RoleUser))
+		orders.GET("/:id/invoice", h.GetInvoice, h.require(RoleUser))
+		orders.POST("/:id/returns", h.CreateReturn, h.require(RoleUser))
+		orders.POST("/checkout", h.StartCheckout, h.require(RoleUser))
+		orders.DELETE("/checkout", h.CancelCheckout, h.require(RoleUser))
+	}
+
+	subscriptions := e.Group("/subscriptions")
+	{
+		subscriptions.GET("", h.GetSubscriptions, h.require(RoleUser))
+		subscriptions.POST("", h.CreateSubscription, h.require(RoleUser))
+		subscriptions.GET("/:id", h.GetSubscription, h.require(RoleUser))
+		subscriptions.DELETE("/:id", h.CancelSubscription, h.require(RoleUser))
+		subscriptions.POST("/:id/pause", h.PauseSubscription, h.require(RoleUser))
+		subscriptions.POST("/:id/resume", h.ResumeSubscription, h.require(RoleUser))
+		subscriptions.POST("/:id/skip", h.SkipSubscriptionOrder, h.require(RoleUser))
+	}
+
+	returns := e.Group("/returns")
+	{
+		returns.GET("", h.GetReturns, h.require(RoleUser))
+		returns.GET("/:id", h.GetReturn, h.require(RoleUser))
+		returns.POST("/:id/photos", h.AddReturnPhoto, h.require(RoleUser))
 	}
 
 	coupons := e.Group("/coupons")
@@ -49,7 +103,41 @@ func setUpRoutes(e *echo.Echo, svc *Services) {
 	admin := e.Group("/_", h.require(RoleAdmin))
 	{
 		admin.GET("", h.GetAdmin)
+		admin.GET("/stats", h.GetSalesStats)
 		admin.GET("/orders", h.GetAllOrders)
+		admin.GET("/orders/export", h.ExportOrders)
+		admin.POST("/orders/:id/refunds", h.CreateRefund)
+		admin.GET("/orders/:id/allocations", h.GetOrderAllocations)
+		admin.GET("/returns", h.GetAllReturns)
+		admin.PUT("/returns/:id/status", h.UpdateReturnStatus)
 		admin.GET("/coupons", h.GetAllCoupons)
+		admin.GET("/coupons/export", h.ExportCoupons)
+		admin.GET("/cart-reminders/stats", h.GetCartReminderStats)
+		admin.GET("/gift-cards", h.GetGiftCards)
+		admin.POST("/gift-cards", h.IssueGiftCard)
+		admin.GET("/gift-cards/:id", h.GetGiftCard)
+		admin.DELETE("/gift-cards/:id", h.DeactivateGiftCard)
+		admin.GET("/users/:id/store-credit", h.GetUserStoreCredit)
+		admin.POST("/users/:id/store-credit", h.IssueStoreCredit)
+		admin.GET("/reward-rules", h.GetRewardRules)
+		admin.POST("/reward-rules", h.CreateRewardRule)
+		admin.PUT("/reward-rules/:id", h.UpdateRewardRule)
+		admin.GET("/promotions", h.GetPromotions)
+		admin.POST("/promotions", h.CreatePromotion)
+		admin.PUT("/promotions/:id", h.UpdatePromotion)
+		admin.GET("/reviews", h.GetReviews)
+		admin.PUT("/reviews/:id/status", h.SetReviewStatus)
+		admin.POST("/products/:id/stock", h.AdjustStock)
+		admin.GET("/products/:id/stock-history", h.GetStockHistory)
+		admin.PUT("/products/:id/low-stock-threshold", h.SetLowStockThreshold)
+		admin.GET("/products/:id/warehouses", h.GetProductStock)
+		admin.GET("/inventory/reconciliation", h.ReconcileStock)
+		admin.GET("/inventory/low-stock", h.GetLowStockProducts)
+		admin.GET("/inventory/transfers", h.GetStockTransfers)
+		admin.POST("/inventory/transfers", h.TransferStock)
+		admin.GET("/warehouses", h.GetWarehouses)
+		admin.POST("/warehouses", h.CreateWarehouse)
+		admin.PUT("/warehouses/:id", h.UpdateWarehouse)
+		admin.GET("/warehouses/:id/stock", h.GetWarehouseStock)
 	}
 }
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+type GetSalesStatsRequest struct {
+	// From and To are dates (2006-01-02) or times (RFC 3339). A date in To includes the whole day. Without either, the last 30 days are returned.
+	From     string `query:"from"`
+	To       string `query:"to"`
+	Interval string `query:"interval" validate:"omitempty,oneof=day week"`
+}
+
+// @Summary Get sales stats
+// @Description Get the number of orders, items purchased, revenue, discounts given, average order value and coupons issued and redeemed in a date range, in total and per day or week. Amounts are in the smallest unit of the currency.
+// @Router /_/stats [get]
+// @Security ApiKeyAuth
+// @Param from query string false "Start date (2006-01-02) or time (RFC 3339)"
+// @Param to query string false "End date (inclusive) or time (exclusive)"
+// @Param interval query string false "day (default) or week"
+// @Success 200 {object} repo.SalesStats
+// @Failure 400 {string} string "invalid date range"
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetSalesStats(c echo.Context) error {
+	var req GetSalesStatsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Interval == "" {
+		req.Interval = repo.StatsIntervalDay
+	}
+	from, err := parseTimeParam(req.From, false)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid from"})
+	}
+	to, err := parseTimeParam(req.To, true)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid to"})
+	}
+	if from == nil && to == nil {
+		thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
+		from = &thirtyDaysAgo
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return c.JSON(http.StatusBadRequest, response{Message: "From must be before to"})
+	}
+
+	stats, err := h.Repo.GetSalesStats(c.Request().Context(), &repo.SalesStatsParams{From: from, To: to, Interval: req.Interval})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+type GetStoreCreditResponse struct {
+	Transactions []repo.StoreCreditTransaction `json:"transactions"`
+	Balance      int                           `json:"balance"`
+}
+
+// @Summary Get store credit
+// @Description Get the store credit of the user and every change to it, latest first.
+// @Router /me/store-credit [get]
+// @Security ApiKeyAuth
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetStoreCreditResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetStoreCredit(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	return h.storeCredit(c, user.ID)
+}
+
+type UserStoreCreditRequest struct {
+	UserID int `param:"id" validate:"required"`
+}
+
+// @Summary Get store credit of user
+// @Description Get the store credit of a user and every change to it, latest first.
+// @Router /_/users/{id}/store-credit [get]
+// @Security ApiKeyAuth
+// @Param id path int true "User ID"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetStoreCreditResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetUserStoreCredit(c echo.Context) error {
+	var req UserStoreCreditRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	return h.storeCredit(c, req.UserID)
+}
+
+func (h *Handler) storeCredit(c echo.Context, userID int) error {
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	balance, err := h.Repo.GetStoreCreditBalance(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+	transactions, err := h.Repo.GetStoreCreditTransactions(c.Request().Context(), userID, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetStoreCreditResponse{Balance: balance, Transactions: transactions})
+}
+
+type IssueStoreCreditRequest struct {
+	Note   string `json:"note" validate:"max=512"`
+	UserID int    `param:"id" validate:"required"`
+	Amount int    `json:"amount" validate:"required,min=1"`
+}
+
+type IssueStoreCreditResponse struct {
+	Transaction *repo.StoreCreditTransaction `json:"transaction"`
+}
+
+// @Summary Issue store credit
+// @Description Credit the store credit account of a user.
+// @Router /_/users/{id}/store-credit [post]
+// @Security ApiKeyAuth
+// @Param id path int true "User ID"
+// @Param body body IssueStoreCreditRequest true "Store credit"
+// @Success 201 {object} IssueStoreCreditResponse
+// @Failure 400 {string} string "invalid request"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "user not found"
+func (h *Handler) IssueStoreCredit(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req IssueStoreCreditRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if _, err := h.Repo.GetUserById(c.Request().Context(), req.UserID); err != nil {
+		if errors.Is(err, repo.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "User not found"})
+		}
+		return err
+	}
+
+	transaction, err := h.Repo.IssueStoreCredit(c.Request().Context(), req.UserID, req.Amount, user.ID, req.Note)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, IssueStoreCreditResponse{Transaction: transaction})
+}
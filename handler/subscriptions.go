@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// subscriptionBatchSize is how many subscription orders are placed per run of the job. The rest are placed on the next runs.
+const subscriptionBatchSize = 100
+
+// subscriptionError responds to the errors that subscription endpoints have in common.
+func subscriptionError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repo.ErrSubscriptionNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Subscription not found"})
+	case errors.Is(err, repo.ErrSubscriptionStatusInvalid):
+		return c.JSON(http.StatusConflict, response{Message: "Subscription can't be changed in its status"})
+	case errors.Is(err, repo.ErrCartEmpty):
+		return c.JSON(http.StatusBadRequest, response{Message: "Cart is empty"})
+	}
+	return err
+}
+
+type SubscriptionResponse struct {
+	Subscription *repo.Subscription `json:"subscription"`
+}
+
+type GetSubscriptionsResponse struct {
+	Subscriptions []repo.Subscription `json:"subscriptions"`
+}
+
+// @Summary Get subscriptions
+// @Description Get the subscriptions of the user that are not cancelled, with their items.
+// @Router /subscriptions [get]
+// @Security ApiKeyAuth
+// @Success 200 {object} GetSubscriptionsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetSubscriptions(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	subscriptions, err := h.Repo.GetSubscriptions(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetSubscriptionsResponse{Subscriptions: subscriptions})
+}
+
+type CreateSubscriptionRequest struct {
+	// FirstOrderAt is when the first order is placed. It defaults to one interval from now.
+	FirstOrderAt *time.Time `json:"firstOrderAt"`
+	// Unit is either day or week.
+	Unit  string `json:"unit" validate:"required,oneof=day week"`
+	Every int    `json:"every" validate:"required,min=1,max=365"`
+}
+
+// @Summary Create subscription
+// @Description Reorder the items in the cart, as they are now, every number of days or weeks. Orders are placed like any other order, and skipped, with an email to the user, when some items are out of stock.
+// @Router /subscriptions [post]
+// @Security ApiKeyAuth
+// @Param body body CreateSubscriptionRequest true "Schedule"
+// @Success 201 {object} SubscriptionResponse
+// @Failure 400 {string} string "empty cart or invalid schedule"
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) CreateSubscription(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req CreateSubscriptionRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	intervalDays := req.Every
+	if req.Unit == "week" {
+		intervalDays *= 7
+	}
+	if intervalDays > 365 {
+		return c.JSON(http.StatusBadRequest, response{Message: "Subscriptions can't be longer than a year apart"})
+	}
+	firstOrderAt := time.Now().AddDate(0, 0, intervalDays)
+	if req.FirstOrderAt != nil {
+		if req.FirstOrderAt.Before(time.Now()) {
+			return c.JSON(http.StatusBadRequest, response{Message: "First order must be in the future"})
+		}
+		firstOrderAt = *req.FirstOrderAt
+	}
+
+	subscription, err := h.Repo.CreateSubscription(c.Request().Context(), &repo.CreateSubscriptionParams{
+		FirstOrderAt: firstOrderAt,
+		UserID:       user.ID,
+		IntervalDays: intervalDays,
+	})
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, SubscriptionResponse{Subscription: subscription})
+}
+
+type SubscriptionIDRequest struct {
+	ID int `param:"id" validate:"required"`
+}
+
+// @Summary Get subscription
+// @Description Get a subscription of the user with its items.
+// @Router /subscriptions/{id} [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} SubscriptionResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "subscription not found"
+func (h *Handler) GetSubscription(c echo.Context) error {
+	return h.changeSubscription(c, h.Repo.GetSubscription)
+}
+
+// @Summary Pause subscription
+// @Description Stop placing orders for the subscription until it is resumed.
+// @Router /subscriptions/{id}/pause [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} SubscriptionResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "subscription not found"
+// @Failure 409 {string} string "subscription is not active"
+func (h *Handler) PauseSubscription(c echo.Context) error {
+	return h.changeSubscription(c, h.Repo.PauseSubscription)
+}
+
+// @Summary Resume subscription
+// @Description Resume a paused subscription. If its next order was due while it was paused, it is placed one interval from now.
+// @Router /subscriptions/{id}/resume [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} SubscriptionResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "subscription not found"
+// @Failure 409 {string} string "subscription is not paused"
+func (h *Handler) ResumeSubscription(c echo.Context) error {
+	return h.changeSubscription(c, h.Repo.ResumeSubscription)
+}
+
+// @Summary Skip subscription order
+// @Description Skip the next order of the subscription.
+// @Router /subscriptions/{id}/skip [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} SubscriptionResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "subscription not found"
+// @Failure 409 {string} string "subscription is cancelled"
+func (h *Handler) SkipSubscriptionOrder(c echo.Context) error {
+	return h.changeSubscription(c, h.Repo.SkipSubscriptionOrder)
+}
+
+// @Summary Cancel subscription
+// @Description Cancel the subscription for good.
+// @Router /subscriptions/{id} [delete]
+// @Security ApiKeyAuth
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} SubscriptionResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "subscription not found"
+// @Failure 409 {string} string "subscription is already cancelled"
+func (h *Handler) CancelSubscription(c echo.Context) error {
+	return h.changeSubscription(c, h.Repo.CancelSubscription)
+}
+
+// changeSubscription responds with the subscription of the user in the request, after applying the change to it.
+func (h *Handler) changeSubscription(c echo.Context, change func(ctx context.Context, userID int, id int) (*repo.Subscription, error)) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req SubscriptionIDRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	subscription, err := change(c.Request().Context(), user.ID, req.ID)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, SubscriptionResponse{Subscription: subscription})
+}
+
+// placeSubscriptionOrders places the orders of the subscriptions that are due.
+func (h *Handler) placeSubscriptionOrders(ctx context.Context) error {
+	subscriptions, err := h.Repo.GetDueSubscriptions(ctx, subscriptionBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range subscriptions {
+		if err = h.placeSubscriptionOrder(ctx, &subscriptions[i]); err != nil {
+			h.Logger.Err(err).Int("subscriptionId", subscriptions[i].ID).Msg("Failed to place subscription order")
+		}
+	}
+	return nil
+}
+
+// placeSubscriptionOrder places the next order of the subscription through the same path as the orders customers place. The order is skipped if some items are out of stock, and the customer is told.
+func (h *Handler) placeSubscriptionOrder(ctx context.Context, subscription *repo.Subscription) error {
+	// The next order is claimed first, so that a failing order is skipped rather than retried on every run
+	claimed, err := h.Repo.ClaimSubscriptionOrder(ctx, subscription.ID)
+	if err != nil || !claimed {
+		return err
+	}
+
+	customer, err := h.Repo.GetUserById(ctx, subscription.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+	cart := make([]repo.CartItem, 0, len(subscription.Items))
+	for _, item := range subscription.Items {
+		cart = append(cart, repo.CartItem{UserID: subscription.UserID, ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+
+	order, err := h.Repo.CreateOrder(ctx, &repo.CreateOrderParams{
+		Cart:             cart,
+		UserID:           subscription.UserID,
+		Pricing:          h.pricingOptions(),
+		Points:           h.pointsProgram(),
+		Allocation:       h.allocationStrategy(),
+		FromSubscription: true,
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrInsufficientStock) {
+			h.sendEmail(&email.BaseOpts{
+				Subject:     "Your subscription order was skipped",
+				ToAddresses: []string{customer.Email},
+			}, "subscription-skipped.tmpl", map[string]any{
+				"subscriptionId": subscription.ID,
+				"items":          subscription.Items,
+			})
+			return nil
+		}
+		return err
+	}
+	if err = h.Repo.SetSubscriptionLastOrder(ctx, subscription.ID, order.ID); err != nil {
+		return err
+	}
+
+	if len(order.GiftCards) > 0 {
+		h.sendGiftCards(customer.Email, fmt.Sprintf("Your gift cards from order #%d", order.ID), "", order.GiftCards)
+	}
+	return h.sendReceipt(ctx, order, customer)
+}
@@ -1,13 +1,20 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/cryptoutil"
+	"github.com/rohitxdev/go-api-starter/email"
 	"github.com/rohitxdev/go-api-starter/repo"
 )
 
@@ -39,6 +46,22 @@ func sanitizeEmail(email string) string {
 	return username + "@" + domain
 }
 
+// getPagination reads the 'page' and 'pageSize' query params. Pages start at 0 and hold 20 items by default.
+func getPagination(c echo.Context) (page int, pageSize int, err error) {
+	pageSize = 20
+	if value := c.QueryParam("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 0 {
+			return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid page")
+		}
+	}
+	if value := c.QueryParam("pageSize"); value != "" {
+		if pageSize, err = strconv.Atoi(value); err != nil || pageSize <= 0 || pageSize > 100 {
+			return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid page size")
+		}
+	}
+	return page, pageSize, nil
+}
+
 type response struct {
 	Message string `json:"message,omitempty"`
 }
@@ -72,3 +95,96 @@ func getUser(c echo.Context) *repo.User {
 	}
 	return user
 }
+
+// parseTimeParam parses a date (2006-01-02) or a time (RFC 3339). Dates are returned as the start of the day, or of the next day with endOfDay set, so that a range of dates includes its last day.
+func parseTimeParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatAmount formats an amount in the smallest unit of the currency for display, e.g. 12345 -> "123.45".
+func formatAmount(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// sendEmail sends an HTML email in the background, so that a slow or failing SMTP server never fails the request.
+func (h *Handler) sendEmail(opts *email.BaseOpts, templateName string, data map[string]any, attachments ...email.Attachment) {
+	h.setSender(opts)
+	go func() {
+		if err := h.Email.SendHTML(opts, templateName, data, attachments...); err != nil {
+			h.Logger.Err(err).Str("template", templateName).Strs("to", opts.ToAddresses).Msg("Failed to send email")
+		}
+	}()
+}
+
+// emailRetryDelays are how long to wait before each retry of an email that failed to send.
+var emailRetryDelays = []time.Duration{time.Second * 10, time.Minute, time.Minute * 5}
+
+// sendEmailWithRetries sends an HTML email with a plain text alternative in the background, like sendEmail, but retries it with backoff when sending fails. It is meant for emails that customers rely on, like receipts.
+func (h *Handler) sendEmailWithRetries(opts *email.BaseOpts, htmlTemplateName string, textTemplateName string, data map[string]any, attachments ...email.Attachment) {
+	h.setSender(opts)
+	go func() {
+		var err error
+		for attempt := 0; ; attempt++ {
+			if err = h.Email.SendHTMLWithText(opts, htmlTemplateName, textTemplateName, data, attachments...); err == nil {
+				return
+			}
+			if attempt == len(emailRetryDelays) {
+				break
+			}
+			h.Logger.Warn().Err(err).Str("template", htmlTemplateName).Int("attempt", attempt+1).Msg("Failed to send email, retrying")
+			time.Sleep(emailRetryDelays[attempt])
+		}
+		h.Logger.Err(err).Str("template", htmlTemplateName).Strs("to", opts.ToAddresses).Msg("Failed to send email")
+	}()
+}
+
+func (h *Handler) setSender(opts *email.BaseOpts) {
+	if opts.FromAddress == "" {
+		opts.FromAddress = h.Config.SenderEmail
+	}
+	if opts.FromName == "" {
+		opts.FromName = h.Config.AppName
+	}
+}
+
+// createToken encrypts the payload into a URL-safe token that can only be read and verified by this server, e.g. for unsubscribe links in emails.
+func (h *Handler) createToken(payload string) (string, error) {
+	key := sha256.Sum256([]byte(h.Config.JWTSecret))
+	data, err := cryptoutil.EncryptAES([]byte(payload), key[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// readToken returns the payload of a token created by createToken.
+func (h *Handler) readToken(token string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", errors.New("invalid token")
+	}
+	key := sha256.Sum256([]byte(h.Config.JWTSecret))
+	payload, err := cryptoutil.DecryptAES(data, key[:])
+	if err != nil {
+		return "", errors.New("invalid token")
+	}
+	return string(payload), nil
+}
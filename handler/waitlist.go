@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/email"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// stockNotificationBatchSize is the number of waitlisted users emailed at a time when a product is back in stock.
+const stockNotificationBatchSize = 50
+
+type StockNotificationRequest struct {
+	ProductID int `param:"id" validate:"required"`
+}
+
+// @Summary Subscribe to back-in-stock notification
+// @Description Get an email when an out of stock product is back in stock.
+// @Router /products/{id}/notify-me [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Success 201 {object} response
+// @Failure 400 {string} string "product is in stock"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "product not found"
+func (h *Handler) SubscribeToStockNotification(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req StockNotificationRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	product, err := h.Repo.GetProduct(c.Request().Context(), req.ProductID)
+	if err != nil {
+		if errors.Is(err, repo.ErrProductNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+		}
+		return err
+	}
+	// Stock held by the checkouts of other customers can't be bought either
+	if product.QuantityAvailable > 0 {
+		return c.JSON(http.StatusBadRequest, response{Message: "Product is in stock"})
+	}
+
+	if err = h.Repo.SubscribeToStockNotification(c.Request().Context(), user.ID, req.ProductID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, response{Message: "You will be notified when the product is back in stock."})
+}
+
+// @Summary Unsubscribe from back-in-stock notification
+// @Description Stop waiting for a product to be back in stock.
+// @Router /products/{id}/notify-me [delete]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) UnsubscribeFromStockNotification(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req StockNotificationRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.UnsubscribeFromStockNotification(c.Request().Context(), user.ID, req.ProductID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Unsubscribed from back-in-stock notification."})
+}
+
+type UnsubscribeFromStockNotificationByTokenRequest struct {
+	Token     string `query:"token" validate:"required"`
+	ProductID int    `param:"id" validate:"required"`
+}
+
+// @Summary Unsubscribe from back-in-stock notification by link
+// @Description Unsubscribe link sent in back-in-stock emails.
+// @Router /products/{id}/notify-me/unsubscribe [get]
+// @Param id path int true "Product ID"
+// @Param token query string true "Unsubscribe token"
+// @Success 200 {html} string "unsubscribed page"
+// @Failure 400 {string} string "invalid token"
+func (h *Handler) UnsubscribeFromStockNotificationByToken(c echo.Context) error {
+	var req UnsubscribeFromStockNotificationByTokenRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	payload, err := h.readToken(req.Token)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid token"})
+	}
+	var userID, productID int
+	if n, _ := fmt.Sscanf(payload, "notify-me:%d:%d", &userID, &productID); n != 2 || productID != req.ProductID {
+		return c.JSON(http.StatusBadRequest, response{Message: "Invalid token"})
+	}
+
+	if err = h.Repo.UnsubscribeFromStockNotification(c.Request().Context(), userID, productID); err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "unsubscribed.tmpl", nil)
+}
+
+// notifyBackInStock emails everyone on the waitlist of a product, in batches, if the product can be bought. Users are taken off the waitlist once they have been emailed.
+func (h *Handler) notifyBackInStock(ctx context.Context, productID int) error {
+	product, err := h.Repo.GetProduct(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("failed to get product: %w", err)
+	}
+	if product.QuantityAvailable <= 0 {
+		return nil
+	}
+
+	afterID := 0
+	for {
+		notifications, err := h.Repo.GetStockNotifications(ctx, productID, afterID, stockNotificationBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get stock notifications: %w", err)
+		}
+		if len(notifications) == 0 {
+			return nil
+		}
+
+		notified := make([]int, 0, len(notifications))
+		for _, n := range notifications {
+			afterID = n.ID
+
+			token, err := h.createToken(fmt.Sprintf("notify-me:%d:%d", n.UserID, n.ProductID))
+			if err != nil {
+				return err
+			}
+			unsubscribeURL := fmt.Sprintf("%s/products/%d/notify-me/unsubscribe?token=%s", h.Config.BaseURL, n.ProductID, url.QueryEscape(token))
+
+			err = h.Email.SendHTML(&email.BaseOpts{
+				Subject:         product.Name + " is back in stock",
+				FromAddress:     h.Config.SenderEmail,
+				FromName:        h.Config.AppName,
+				ToAddresses:     []string{n.UserEmail},
+				UnsubscribeLink: "<" + unsubscribeURL + ">",
+				NoStack:         true,
+			}, "back-in-stock.tmpl", map[string]any{
+				"productName":    product.Name,
+				"productURL":     fmt.Sprintf("%s/products/%d", h.Config.BaseURL, n.ProductID),
+				"unsubscribeURL": unsubscribeURL,
+			})
+			if err != nil {
+				// Leave the user on the waitlist, they will be emailed on the next restock.
+				h.Logger.Err(err).Int("userId", n.UserID).Int("productId", n.ProductID).Msg("Failed to send back-in-stock email")
+				continue
+			}
+			notified = append(notified, n.ID)
+		}
+
+		if err = h.Repo.DeleteStockNotifications(ctx, notified); err != nil {
+			return fmt.Errorf("failed to delete stock notifications: %w", err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// warehouseError responds to the errors that warehouse endpoints have in common.
+func warehouseError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repo.ErrWarehouseNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Warehouse not found"})
+	case errors.Is(err, repo.ErrWarehouseAlreadyExists):
+		return c.JSON(http.StatusConflict, response{Message: "Warehouse with this name already exists"})
+	case errors.Is(err, repo.ErrProductNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+	case errors.Is(err, repo.ErrInsufficientStock):
+		return c.JSON(http.StatusBadRequest, response{Message: "Not enough stock in the warehouse"})
+	}
+	return err
+}
+
+type WarehouseResponse struct {
+	Warehouse *repo.Warehouse `json:"warehouse"`
+}
+
+type GetWarehousesResponse struct {
+	Warehouses []repo.Warehouse `json:"warehouses"`
+}
+
+// @Summary Get warehouses
+// @Description Get every warehouse with the stock in it, the default one first.
+// @Router /_/warehouses [get]
+// @Security ApiKeyAuth
+// @Success 200 {object} GetWarehousesResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetWarehouses(c echo.Context) error {
+	warehouses, err := h.Repo.GetWarehouses(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetWarehousesResponse{Warehouses: warehouses})
+}
+
+type CreateWarehouseRequest struct {
+	Name      string   `json:"name" validate:"required,max=128"`
+	Latitude  *float64 `json:"latitude" validate:"required,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" validate:"required,min=-180,max=180"`
+}
+
+// @Summary Create warehouse
+// @Description Add a warehouse without any stock. Stock is added to it by restocking or transferring products to it.
+// @Router /_/warehouses [post]
+// @Security ApiKeyAuth
+// @Param body body CreateWarehouseRequest true "Warehouse"
+// @Success 201 {object} WarehouseResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 409 {string} string "warehouse with this name already exists"
+func (h *Handler) CreateWarehouse(c echo.Context) error {
+	var req CreateWarehouseRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	warehouse, err := h.Repo.CreateWarehouse(c.Request().Context(), &repo.CreateWarehouseParams{
+		Name:      req.Name,
+		Latitude:  *req.Latitude,
+		Longitude: *req.Longitude,
+	})
+	if err != nil {
+		return warehouseError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, WarehouseResponse{Warehouse: warehouse})
+}
+
+type UpdateWarehouseRequest struct {
+	CreateWarehouseRequest
+	ID int `param:"id" validate:"required"`
+	// MakeDefault makes the warehouse the one that takes the stock products are created with, in place of the current one.
+	MakeDefault bool `json:"makeDefault"`
+}
+
+// @Summary Update warehouse
+// @Description Rename or move a warehouse, or make it the default one.
+// @Router /_/warehouses/{id} [put]
+// @Security ApiKeyAuth
+// @Param id path int true "Warehouse ID"
+// @Param body body UpdateWarehouseRequest true "Warehouse"
+// @Success 200 {object} WarehouseResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "warehouse not found"
+// @Failure 409 {string} string "warehouse with this name already exists"
+func (h *Handler) UpdateWarehouse(c echo.Context) error {
+	var req UpdateWarehouseRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	warehouse, err := h.Repo.UpdateWarehouse(c.Request().Context(), &repo.UpdateWarehouseParams{
+		ID:          req.ID,
+		Name:        req.Name,
+		Latitude:    *req.Latitude,
+		Longitude:   *req.Longitude,
+		MakeDefault: req.MakeDefault,
+	})
+	if err != nil {
+		return warehouseError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, WarehouseResponse{Warehouse: warehouse})
+}
+
+type GetWarehouseStockRequest struct {
+	WarehouseID int `param:"id" validate:"required"`
+}
+
+type GetWarehouseStockResponse struct {
+	Stock []repo.WarehouseStock `json:"stock"`
+}
+
+// @Summary Get warehouse stock
+// @Description Get the products in stock in a warehouse, most stock first.
+// @Router /_/warehouses/{id}/stock [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Warehouse ID"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetWarehouseStockResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "warehouse not found"
+func (h *Handler) GetWarehouseStock(c echo.Context) error {
+	var req GetWarehouseStockRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	stock, err := h.Repo.GetWarehouseStock(c.Request().Context(), req.WarehouseID, page, pageSize)
+	if err != nil {
+		return warehouseError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, GetWarehouseStockResponse{Stock: stock})
+}
+
+type GetProductStockRequest struct {
+	ProductID int `param:"id" validate:"required"`
+}
+
+// @Summary Get product stock
+// @Description Get the stock of a product in each warehouse, the default warehouse first. The stock of the product is their sum.
+// @Router /_/products/{id}/warehouses [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} GetWarehouseStockResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "product not found"
+func (h *Handler) GetProductStock(c echo.Context) error {
+	var req GetProductStockRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	stock, err := h.Repo.GetProductStock(c.Request().Context(), req.ProductID)
+	if err != nil {
+		return warehouseError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, GetWarehouseStockResponse{Stock: stock})
+}
+
+type TransferStockRequest struct {
+	Reason          string `json:"reason" validate:"max=512"`
+	ProductID       int    `json:"productId" validate:"required"`
+	FromWarehouseID int    `json:"fromWarehouseId" validate:"required"`
+	ToWarehouseID   int    `json:"toWarehouseId" validate:"required,nefield=FromWarehouseID"`
+	Quantity        int    `json:"quantity" validate:"required,min=1"`
+}
+
+type TransferStockResponse struct {
+	Transfer *repo.StockTransfer `json:"transfer"`
+}
+
+// @Summary Transfer stock
+// @Description Move stock of a product from one warehouse to another. The stock of the product stays the same, and both sides of the transfer are recorded in the inventory ledger.
+// @Router /_/inventory/transfers [post]
+// @Security ApiKeyAuth
+// @Param body body TransferStockRequest true "Transfer"
+// @Success 201 {object} TransferStockResponse
+// @Failure 400 {string} string "not enough stock in the warehouse"
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "product or warehouse not found"
+func (h *Handler) TransferStock(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req TransferStockRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	transfer, err := h.Repo.TransferStock(c.Request().Context(), &repo.TransferStockParams{
+		ProductID:       req.ProductID,
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+		Quantity:        req.Quantity,
+		Reason:          req.Reason,
+		ActorID:         user.ID,
+	})
+	if err != nil {
+		return warehouseError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, TransferStockResponse{Transfer: transfer})
+}
+
+type GetStockTransfersRequest struct {
+	ProductID *int `query:"productId"`
+}
+
+type GetStockTransfersResponse struct {
+	Transfers []repo.StockTransfer `json:"transfers"`
+}
+
+// @Summary Get stock transfers
+// @Description Get the stock transfers between warehouses, latest first.
+// @Router /_/inventory/transfers [get]
+// @Security ApiKeyAuth
+// @Param productId query int false "Only the transfers of this product"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} GetStockTransfersResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetStockTransfers(c echo.Context) error {
+	var req GetStockTransfersRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	page, pageSize, err := getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	transfers, err := h.Repo.GetStockTransfers(c.Request().Context(), req.ProductID, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetStockTransfersResponse{Transfers: transfers})
+}
+
+type GetOrderAllocationsRequest struct {
+	OrderID int `param:"id" validate:"required"`
+}
+
+type GetOrderAllocationsResponse struct {
+	Allocations []repo.OrderAllocation `json:"allocations"`
+}
+
+// @Summary Get order allocations
+// @Description Get the warehouses that the items of an order ship from.
+// @Router /_/orders/{id}/allocations [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} GetOrderAllocationsResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "order not found"
+func (h *Handler) GetOrderAllocations(c echo.Context) error {
+	var req GetOrderAllocationsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	if _, err := h.Repo.GetOrder(ctx, req.OrderID); err != nil {
+		if errors.Is(err, repo.ErrOrderNotFound) {
+			return c.JSON(http.StatusNotFound, response{Message: "Order not found"})
+		}
+		return err
+	}
+	allocations, err := h.Repo.GetOrderAllocations(ctx, req.OrderID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetOrderAllocationsResponse{Allocations: allocations})
+}
@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api-starter/cryptoutil"
+	"github.com/rohitxdev/go-api-starter/repo"
+)
+
+// wishlistError responds to the errors that wishlist endpoints have in common.
+func wishlistError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repo.ErrWishlistNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Wishlist not found"})
+	case errors.Is(err, repo.ErrWishlistItemNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Product is not in the wishlist"})
+	case errors.Is(err, repo.ErrProductNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Product not found"})
+	case errors.Is(err, repo.ErrCartItemNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: "Product is not in the cart"})
+	case errors.Is(err, repo.ErrInsufficientStock):
+		return c.JSON(http.StatusConflict, response{Message: "Product is out of stock"})
+	}
+	return err
+}
+
+type GetWishlistsResponse struct {
+	Wishlists []repo.Wishlist `json:"wishlists"`
+}
+
+// @Summary Get wishlists
+// @Description Get the wishlists of the user, without their items.
+// @Router /wishlists [get]
+// @Security ApiKeyAuth
+// @Success 200 {object} GetWishlistsResponse
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) GetWishlists(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	wishlists, err := h.Repo.GetWishlists(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, GetWishlistsResponse{Wishlists: wishlists})
+}
+
+type CreateWishlistRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+type WishlistResponse struct {
+	Wishlist *repo.Wishlist `json:"wishlist"`
+}
+
+// @Summary Create wishlist
+// @Description Create a named wishlist.
+// @Router /wishlists [post]
+// @Security ApiKeyAuth
+// @Param body body CreateWishlistRequest true "Wishlist"
+// @Success 201 {object} WishlistResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 409 {string} string "wishlist already exists"
+func (h *Handler) CreateWishlist(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req CreateWishlistRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	wishlist, err := h.Repo.CreateWishlist(c.Request().Context(), user.ID, req.Name)
+	if err != nil {
+		if errors.Is(err, repo.ErrWishlistAlreadyExists) {
+			return c.JSON(http.StatusConflict, response{Message: "A wishlist with this name already exists"})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, WishlistResponse{Wishlist: wishlist})
+}
+
+type WishlistRequest struct {
+	ID int `param:"id" validate:"required"`
+}
+
+// @Summary Get wishlist
+// @Description Get a wishlist with its items. Each item tells if it is in stock and if its price dropped since it was added.
+// @Router /wishlists/{id} [get]
+// @Security ApiKeyAuth
+// @Param id path int true "Wishlist ID"
+// @Success 200 {object} WishlistResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "wishlist not found"
+func (h *Handler) GetWishlist(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req WishlistRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	wishlist, err := h.Repo.GetWishlist(c.Request().Context(), user.ID, req.ID)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, WishlistResponse{Wishlist: wishlist})
+}
+
+// @Summary Delete wishlist
+// @Description Delete a wishlist and its items.
+// @Router /wishlists/{id} [delete]
+// @Security ApiKeyAuth
+// @Param id path int true "Wishlist ID"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "wishlist not found"
+func (h *Handler) DeleteWishlist(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req WishlistRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.DeleteWishlist(c.Request().Context(), user.ID, req.ID); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Wishlist deleted."})
+}
+
+type ShareWishlistResponse struct {
+	ShareToken string `json:"shareToken"`
+	URL        string `json:"url"`
+}
+
+// @Summary Share wishlist
+// @Description Get a link that lets anyone view the wishlist. Sharing again replaces the previous link.
+// @Router /wishlists/{id}/share [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Wishlist ID"
+// @Success 200 {object} ShareWishlistResponse
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "wishlist not found"
+func (h *Handler) ShareWishlist(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req WishlistRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	shareToken := cryptoutil.RandomString()
+	if err := h.Repo.SetWishlistShareToken(c.Request().Context(), user.ID, req.ID, &shareToken); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ShareWishlistResponse{
+		ShareToken: shareToken,
+		URL:        h.Config.BaseURL + "/wishlists/shared/" + shareToken,
+	})
+}
+
+// @Summary Stop sharing wishlist
+// @Description Disable the link of a shared wishlist.
+// @Router /wishlists/{id}/share [delete]
+// @Security ApiKeyAuth
+// @Param id path int true "Wishlist ID"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "wishlist not found"
+func (h *Handler) UnshareWishlist(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req WishlistRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.SetWishlistShareToken(c.Request().Context(), user.ID, req.ID, nil); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Wishlist is no longer shared."})
+}
+
+type GetSharedWishlistRequest struct {
+	ShareToken string `param:"token" validate:"required"`
+}
+
+// @Summary Get shared wishlist
+// @Description Get a wishlist that has been shared, with its items.
+// @Router /wishlists/shared/{token} [get]
+// @Param token path string true "Share token"
+// @Success 200 {object} WishlistResponse
+// @Failure 404 {string} string "wishlist not found"
+func (h *Handler) GetSharedWishlist(c echo.Context) error {
+	var req GetSharedWishlistRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	wishlist, err := h.Repo.GetSharedWishlist(c.Request().Context(), req.ShareToken)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	// The token is the owner's to hand out.
+	wishlist.ShareToken = nil
+
+	return c.JSON(http.StatusOK, WishlistResponse{Wishlist: wishlist})
+}
+
+type WishlistItemRequest struct {
+	WishlistID int `param:"id" validate:"required"`
+	ProductID  int `param:"productId" validate:"required"`
+}
+
+// @Summary Add to wishlist
+// @Description Add a product to a wishlist. Adding a product that is already in the wishlist is a no-op.
+// @Router /wishlists/{id}/items/{productId} [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Wishlist ID"
+// @Param productId path int true "Product ID"
+// @Success 201 {object} response
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "wishlist or product not found"
+func (h *Handler) AddToWishlist(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req WishlistItemRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.AddToWishlist(c.Request().Context(), user.ID, req.WishlistID, req.ProductID); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, response{Message: "Product added to wishlist."})
+}
+
+// @Summary Remove from wishlist
+// @Description Remove a product from a wishlist.
+// @Router /wishlists/{id}/items/{productId} [delete]
+// @Security ApiKeyAuth
+// @Param id path int true "Wishlist ID"
+// @Param productId path int true "Product ID"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+func (h *Handler) RemoveFromWishlist(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req WishlistItemRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.RemoveFromWishlist(c.Request().Context(), user.ID, req.WishlistID, req.ProductID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Product removed from wishlist."})
+}
+
+// @Summary Move wishlist item to cart
+// @Description Take a product off the wishlist and put it in the cart.
+// @Router /wishlists/{id}/items/{productId}/move-to-cart [post]
+// @Security ApiKeyAuth
+// @Param id path int true "Wishlist ID"
+// @Param productId path int true "Product ID"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "wishlist or item not found"
+// @Failure 409 {string} string "out of stock"
+func (h *Handler) MoveWishlistItemToCart(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req WishlistItemRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.MoveWishlistItemToCart(c.Request().Context(), user.ID, req.WishlistID, req.ProductID); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Product moved to cart."})
+}
+
+type SaveForLaterRequest struct {
+	ProductID  int `param:"productId" validate:"required"`
+	WishlistID int `json:"wishlistId" validate:"required"`
+}
+
+// @Summary Save for later
+// @Description Move a product from the cart to a wishlist.
+// @Router /carts/{productId}/save-for-later [post]
+// @Security ApiKeyAuth
+// @Param productId path int true "Product ID"
+// @Param body body SaveForLaterRequest true "Wishlist"
+// @Success 200 {object} response
+// @Failure 401 {string} string "invalid session"
+// @Failure 404 {string} string "wishlist or cart item not found"
+func (h *Handler) SaveForLater(c echo.Context) error {
+	user := getUser(c)
+	if user == nil {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req SaveForLaterRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.MoveCartItemToWishlist(c.Request().Context(), user.ID, req.ProductID, req.WishlistID); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Message: "Product saved for later."})
+}
@@ -0,0 +1,127 @@
+// Package invoice renders invoices as PDF documents.
+package invoice
+
+import "strconv"
+
+// Line is an item on the invoice. Amounts are formatted by the caller.
+type Line struct {
+	Description string
+	UnitPrice   string
+	Amount      string
+	Quantity    int
+}
+
+// Total is a row below the items, e.g. the subtotal, a discount or the tax.
+type Total struct {
+	Label  string
+	Amount string
+}
+
+type Invoice struct {
+	Number string
+	// Date is when the invoice was issued.
+	Date   string
+	Seller string
+	// BillTo is the address block of the customer, one line each.
+	BillTo []string
+	// Reference is printed under the invoice number, e.g. the order number.
+	Reference string
+	Lines     []Line
+	// Totals are printed below the lines, and Total below them.
+	Totals []Total
+	Total  string
+	// Payments are printed below the total, e.g. what was paid with a gift card.
+	Payments []Total
+}
+
+const (
+	margin = 50
+	// rowHeight is the space taken by a line of text.
+	rowHeight = 18
+	// Right edges of the columns of the items.
+	columnQuantity  = 380
+	columnUnitPrice = 470
+	columnAmount    = pageWidth - margin
+)
+
+// Render returns the invoice as a PDF. Items that don't fit on the first page continue on the next ones.
+func Render(inv *Invoice) []byte {
+	var d document
+	d.addPage()
+
+	y := float64(pageHeight - margin)
+	d.text(fontBold, 20, margin, y, "Invoice")
+	d.textRight(fontBold, 12, columnAmount, y, inv.Seller)
+	y -= rowHeight * 2
+	d.text(fontRegular, 10, margin, y, "Invoice number: "+inv.Number)
+	y -= rowHeight
+	d.text(fontRegular, 10, margin, y, "Date: "+inv.Date)
+	if inv.Reference != "" {
+		y -= rowHeight
+		d.text(fontRegular, 10, margin, y, inv.Reference)
+	}
+	y -= rowHeight * 2
+	d.text(fontBold, 10, margin, y, "Bill to")
+	for _, line := range inv.BillTo {
+		y -= rowHeight
+		d.text(fontRegular, 10, margin, y, line)
+	}
+
+	header := func() {
+		y -= rowHeight * 2
+		d.text(fontBold, 10, margin, y, "Item")
+		d.textRight(fontBold, 10, columnQuantity, y, "Quantity")
+		d.textRight(fontBold, 10, columnUnitPrice, y, "Unit price")
+		d.textRight(fontBold, 10, columnAmount, y, "Amount")
+		d.line(margin, y-6, columnAmount, y-6)
+		y -= 6
+	}
+	// nextRow moves down a row, onto a new page if this one is full.
+	nextRow := func(withHeader bool) {
+		y -= rowHeight
+		if y < margin {
+			d.addPage()
+			y = pageHeight - margin
+			if withHeader {
+				header()
+				y -= rowHeight
+			}
+		}
+	}
+
+	header()
+	for _, line := range inv.Lines {
+		nextRow(true)
+		d.text(fontRegular, 10, margin, y, truncate(line.Description, 48))
+		d.textRight(fontRegular, 10, columnQuantity, y, strconv.Itoa(line.Quantity))
+		d.textRight(fontRegular, 10, columnUnitPrice, y, line.UnitPrice)
+		d.textRight(fontRegular, 10, columnAmount, y, line.Amount)
+	}
+	d.line(margin, y-6, columnAmount, y-6)
+	y -= 6
+
+	for _, total := range inv.Totals {
+		nextRow(false)
+		d.textRight(fontRegular, 10, columnUnitPrice, y, total.Label)
+		d.textRight(fontRegular, 10, columnAmount, y, total.Amount)
+	}
+	nextRow(false)
+	d.textRight(fontBold, 11, columnUnitPrice, y, "Total")
+	d.textRight(fontBold, 11, columnAmount, y, inv.Total)
+	for _, payment := range inv.Payments {
+		nextRow(false)
+		d.textRight(fontRegular, 10, columnUnitPrice, y, payment.Label)
+		d.textRight(fontRegular, 10, columnAmount, y, payment.Amount)
+	}
+
+	return d.bytes()
+}
+
+// truncate shortens s to at most n characters, so that it doesn't run into the next column.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
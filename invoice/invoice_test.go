@@ -0,0 +1,58 @@
+package invoice_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/rohitxdev/go-api-starter/invoice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	inv := &invoice.Invoice{
+		Number:    "INV-2026-000001",
+		Date:      "2026-10-18",
+		Seller:    "Uniblox",
+		BillTo:    []string{"Jane (Accounts)", "jane@example.com"},
+		Reference: "Order #1",
+		Lines:     []invoice.Line{{Description: "Shoes", Quantity: 2, UnitPrice: "10.00", Amount: "20.00"}},
+		Totals:    []invoice.Total{{Label: "Subtotal", Amount: "20.00"}, {Label: "Tax", Amount: "3.60"}},
+		Total:     "23.60",
+	}
+
+	t.Run("Valid PDF", func(t *testing.T) {
+		pdf := invoice.Render(inv)
+		assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+		assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+		assert.Contains(t, string(pdf), "(Invoice number: INV-2026-000001)")
+		// Parentheses are escaped
+		assert.Contains(t, string(pdf), `(Jane \(Accounts\))`)
+
+		// Every object is where the cross-reference table says it is
+		startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+		assert.NotNil(t, startxref)
+		xref, err := strconv.Atoi(string(startxref[1]))
+		assert.Nil(t, err)
+		assert.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")))
+		offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+		assert.Len(t, offsets, 6)
+		for i, offset := range offsets {
+			n, _ := strconv.Atoi(string(offset[1]))
+			assert.True(t, bytes.HasPrefix(pdf[n:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))))
+		}
+	})
+
+	t.Run("Many lines", func(t *testing.T) {
+		long := *inv
+		long.Lines = nil
+		for i := range 100 {
+			long.Lines = append(long.Lines, invoice.Line{Description: fmt.Sprintf("Item %d", i), Quantity: 1, UnitPrice: "1.00", Amount: "1.00"})
+		}
+		pdf := invoice.Render(&long)
+		assert.Contains(t, string(pdf), "/Count 3")
+		assert.Contains(t, string(pdf), "(Item 99)")
+	})
+}
@@ -0,0 +1,61 @@
+// Package jobs runs recurring background tasks.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Func is a unit of background work. The context is cancelled when the scheduler is closed.
+type Func func(ctx context.Context) error
+
+type Scheduler struct {
+	logger *zerolog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(logger *zerolog.Logger) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Every runs fn once every interval until the scheduler is closed. Runs of the same job never overlap.
+func (s *Scheduler) Every(name string, interval time.Duration, fn Func) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				start := time.Now()
+				if err := fn(s.ctx); err != nil {
+					s.logger.Err(err).Str("job", name).Msg("Background job failed")
+					continue
+				}
+				s.logger.Debug().Str("job", name).Int64("durationMs", time.Since(start).Milliseconds()).Msg("Background job ran")
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops all jobs and waits for the running ones to return.
+func (s *Scheduler) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,41 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rohitxdev/go-api-starter/jobs"
+	"github.com/rohitxdev/go-api-starter/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	s := jobs.New(logger.New(io.Discard, false))
+
+	var runs, failures atomic.Int32
+	s.Every("count", time.Millisecond*10, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	s.Every("fail", time.Millisecond*10, func(ctx context.Context) error {
+		failures.Add(1)
+		return errors.New("failed")
+	})
+
+	t.Run("Run jobs", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			return runs.Load() >= 3 && failures.Load() >= 3
+		}, time.Second, time.Millisecond*10)
+	})
+
+	t.Run("Stop jobs on close", func(t *testing.T) {
+		assert.Nil(t, s.Close())
+		count := runs.Load()
+		time.Sleep(time.Millisecond * 50)
+		assert.Equal(t, count, runs.Load())
+	})
+}
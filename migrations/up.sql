@@ -15,6 +15,7 @@ CREATE TABLE users (
     ) DEFAULT 'active',
     image_url TEXT,
     is_verified BOOL DEFAULT FALSE,
+    wants_cart_reminders BOOL NOT NULL DEFAULT TRUE,
     created_at TIMESTAMPTZ DEFAULT current_timestamp,
     updated_at TIMESTAMPTZ DEFAULT current_timestamp
 );
@@ -37,6 +38,12 @@ CREATE TABLE products (
     name TEXT NOT NULL CHECK (LENGTH(name) <= 128),
     price BIGINT NOT NULL CHECK (price > 0),
     quantity_left BIGINT NOT NULL CHECK (quantity_left >= 0),
+    low_stock_threshold BIGINT NOT NULL CHECK (low_stock_threshold >= 0) DEFAULT 0,
+    is_gift_card BOOLEAN NOT NULL DEFAULT FALSE,
+    category TEXT NOT NULL DEFAULT '' CHECK (LENGTH(category) <= 64),
+    -- rating_count and rating_sum add up the approved reviews, so that listings don't have to
+    rating_count BIGINT NOT NULL DEFAULT 0 CHECK (rating_count >= 0),
+    rating_sum BIGINT NOT NULL DEFAULT 0 CHECK (rating_sum >= 0),
     created_at TIMESTAMPTZ DEFAULT current_timestamp,
     updated_at TIMESTAMPTZ DEFAULT current_timestamp
 );
@@ -49,15 +56,33 @@ CREATE TABLE orders (
     id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
     user_id BIGINT NOT NULL REFERENCES users (id),
     status TEXT NOT NULL CHECK (
-        status IN ('pending', 'processing', 'completed', 'cancelled')
+        status IN (
+            'pending',
+            'processing',
+            'completed',
+            'cancelled',
+            'partially_refunded',
+            'refunded'
+        )
     ),
-    total_amount BIGINT NOT NULL CHECK (total_amount > 0),
+    total_amount BIGINT NOT NULL CHECK (total_amount >= 0),
+    promotion_discount BIGINT NOT NULL DEFAULT 0 CHECK (promotion_discount >= 0),
     discounted_amount BIGINT NOT NULL DEFAULT 0 CHECK (discounted_amount >= 0),
+    tax_amount BIGINT NOT NULL DEFAULT 0 CHECK (tax_amount >= 0),
+    shipping_amount BIGINT NOT NULL DEFAULT 0 CHECK (shipping_amount >= 0),
+    points_redeemed BIGINT NOT NULL DEFAULT 0 CHECK (points_redeemed >= 0),
+    points_discount BIGINT NOT NULL DEFAULT 0 CHECK (points_discount >= 0),
+    gift_card_amount BIGINT NOT NULL DEFAULT 0 CHECK (gift_card_amount >= 0),
+    store_credit_amount BIGINT NOT NULL DEFAULT 0 CHECK (store_credit_amount >= 0),
+    refunded_amount BIGINT NOT NULL DEFAULT 0 CHECK (refunded_amount >= 0),
     coupon_id BIGINT,
     created_at TIMESTAMPTZ DEFAULT current_timestamp,
     updated_at TIMESTAMPTZ DEFAULT current_timestamp
 );
 
+-- Stats and exports look up orders by when they were placed
+CREATE INDEX orders_created_at_idx ON orders (created_at);
+
 CREATE TRIGGER set_orders_updated_at BEFORE
 UPDATE ON orders FOR EACH ROW
 EXECUTE FUNCTION set_updated_at_column ();
@@ -67,6 +92,14 @@ CREATE TABLE order_items (
     order_id BIGINT NOT NULL REFERENCES orders (id),
     product_id BIGINT NOT NULL REFERENCES products (id),
     quantity BIGINT NOT NULL CHECK (quantity > 0),
+    -- price is the unit price of the product at the time of purchase
+    price BIGINT NOT NULL CHECK (price > 0),
+    -- discount is what promotions took off the item
+    discount BIGINT NOT NULL DEFAULT 0 CHECK (discount >= 0),
+    refunded_quantity BIGINT NOT NULL DEFAULT 0 CHECK (
+        refunded_quantity >= 0
+        AND refunded_quantity <= quantity
+    ),
     created_at TIMESTAMPTZ DEFAULT current_timestamp,
     updated_at TIMESTAMPTZ DEFAULT current_timestamp,
     UNIQUE (order_id, product_id)
@@ -91,18 +124,727 @@ UPDATE ON cart_items FOR EACH ROW
 EXECUTE FUNCTION set_updated_at_column ();
 
 CREATE TABLE coupons (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    code CITEXT NOT NULL UNIQUE CHECK (LENGTH(code) <= 128),
+    -- Coupons without a user can be redeemed by anyone.
+    user_id BIGINT REFERENCES users (id),
+    discount_type TEXT NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    -- A percentage for percent coupons, an amount in the smallest unit of the currency for fixed ones.
+    discount_value BIGINT NOT NULL CHECK (discount_value > 0),
+    max_discount BIGINT CHECK (max_discount > 0),
+    min_order_amount BIGINT NOT NULL DEFAULT 0 CHECK (min_order_amount >= 0),
+    starts_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    max_redemptions BIGINT CHECK (max_redemptions > 0),
+    max_redemptions_per_user BIGINT NOT NULL DEFAULT 1 CHECK (max_redemptions_per_user > 0),
+    redemption_count BIGINT NOT NULL DEFAULT 0 CHECK (redemption_count >= 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    CHECK (
+        discount_type <> 'percent'
+        OR discount_value <= 100
+    ),
+    CHECK (
+        starts_at IS NULL
+        OR expires_at IS NULL
+        OR starts_at < expires_at
+    )
+);
+
+CREATE TRIGGER set_coupons_updated_at BEFORE
+UPDATE ON coupons FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE refunds (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id),
+    amount BIGINT NOT NULL CHECK (amount >= 0),
+    reason TEXT NOT NULL DEFAULT '' CHECK (LENGTH(reason) <= 512),
+    is_restocked BOOL NOT NULL DEFAULT FALSE,
+    store_credit_amount BIGINT NOT NULL DEFAULT 0 CHECK (store_credit_amount >= 0),
+    created_by BIGINT NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE TRIGGER set_refunds_updated_at BEFORE
+UPDATE ON refunds FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE refund_items (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    refund_id BIGINT NOT NULL REFERENCES refunds (id),
+    order_item_id BIGINT NOT NULL REFERENCES order_items (id),
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    amount BIGINT NOT NULL CHECK (amount >= 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE TRIGGER set_refund_items_updated_at BEFORE
+UPDATE ON refund_items FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE stock_reservations (
     id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
     user_id BIGINT NOT NULL REFERENCES users (id),
-    code TEXT NOT NULL CHECK (LENGTH(code) <= 128),
-    discount_percent INT NOT NULL CHECK (
-        discount_percent >= 0
-        AND discount_percent <= 100
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (user_id, product_id)
+);
+
+CREATE INDEX stock_reservations_product_id_expires_at_idx ON stock_reservations (product_id, expires_at);
+
+CREATE TRIGGER set_stock_reservations_updated_at BEFORE
+UPDATE ON stock_reservations FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE warehouses (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE CHECK (LENGTH(name) <= 128),
+    latitude DOUBLE PRECISION NOT NULL CHECK (latitude BETWEEN -90 AND 90),
+    longitude DOUBLE PRECISION NOT NULL CHECK (longitude BETWEEN -180 AND 180),
+    -- The default warehouse takes the stock that isn't put in a warehouse of its own, like the stock products are created with
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE UNIQUE INDEX warehouses_is_default_idx ON warehouses (is_default) WHERE is_default;
+
+CREATE TRIGGER set_warehouses_updated_at BEFORE
+UPDATE ON warehouses FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- warehouse_stock splits the stock of each product across warehouses. products.quantity_left is always their sum.
+CREATE TABLE warehouse_stock (
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses (id),
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    quantity BIGINT NOT NULL CHECK (quantity >= 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    PRIMARY KEY (warehouse_id, product_id)
+);
+
+CREATE INDEX warehouse_stock_product_id_idx ON warehouse_stock (product_id);
+
+CREATE TRIGGER set_warehouse_stock_updated_at BEFORE
+UPDATE ON warehouse_stock FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- inventory_movements is an append-only ledger of every change to the stock of a product
+CREATE TABLE inventory_movements (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    kind TEXT NOT NULL CHECK (
+        kind IN (
+            'initial',
+            'sale',
+            'restock',
+            'adjustment',
+            'refund',
+            'reservation',
+            'release',
+            'transfer'
+        )
     ),
-    is_used BOOL NOT NULL DEFAULT FALSE,
+    -- quantity is the signed change in stock
+    quantity BIGINT NOT NULL CHECK (quantity <> 0),
+    -- quantity_after is the stock left after the movement, NULL for reservations as they don't change the stock
+    quantity_after BIGINT,
+    -- warehouse_id is the warehouse whose stock changed, NULL for reservations as they hold stock of the product as a whole
+    warehouse_id BIGINT REFERENCES warehouses (id),
+    actor_id BIGINT REFERENCES users (id),
+    order_id BIGINT REFERENCES orders (id),
+    reason TEXT NOT NULL DEFAULT '' CHECK (LENGTH(reason) <= 512),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX inventory_movements_product_id_idx ON inventory_movements (product_id, id);
+
+CREATE
+OR REPLACE FUNCTION prevent_inventory_movement_changes () RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'inventory_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventory_movements_append_only BEFORE
+UPDATE
+OR DELETE ON inventory_movements FOR EACH ROW
+EXECUTE FUNCTION prevent_inventory_movement_changes ();
+
+-- Put the stock a product is created with in the default warehouse, and record it, so that the ledger always adds up to the stock
+CREATE
+OR REPLACE FUNCTION record_initial_stock () RETURNS TRIGGER AS $$
+DECLARE
+    default_warehouse_id BIGINT;
+BEGIN
+    IF NEW.quantity_left <> 0 THEN
+        SELECT id INTO default_warehouse_id FROM warehouses WHERE is_default;
+        IF default_warehouse_id IS NULL THEN
+            RAISE EXCEPTION 'there is no default warehouse to stock product %', NEW.id;
+        END IF;
+        INSERT INTO warehouse_stock(warehouse_id, product_id, quantity)
+        VALUES (default_warehouse_id, NEW.id, NEW.quantity_left);
+        INSERT INTO inventory_movements(product_id, kind, quantity, quantity_after, warehouse_id)
+        VALUES (NEW.id, 'initial', NEW.quantity_left, NEW.quantity_left, default_warehouse_id);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER record_products_initial_stock
+AFTER INSERT ON products FOR EACH ROW
+EXECUTE FUNCTION record_initial_stock ();
+
+CREATE TABLE stock_notifications (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (user_id, product_id)
+);
+
+CREATE INDEX stock_notifications_product_id_idx ON stock_notifications (product_id, id);
+
+CREATE TRIGGER set_stock_notifications_updated_at BEFORE
+UPDATE ON stock_notifications FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE wishlists (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL CHECK (LENGTH(name) <= 64),
+    share_token TEXT UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (user_id, name)
+);
+
+CREATE TRIGGER set_wishlists_updated_at BEFORE
+UPDATE ON wishlists FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE wishlist_items (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    wishlist_id BIGINT NOT NULL REFERENCES wishlists (id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    price_when_added BIGINT NOT NULL CHECK (price_when_added > 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (wishlist_id, product_id)
+);
+
+CREATE TRIGGER set_wishlist_items_updated_at BEFORE
+UPDATE ON wishlist_items FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE cart_reminders (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    item_count BIGINT NOT NULL CHECK (item_count > 0),
+    converted_order_id BIGINT REFERENCES orders (id),
+    converted_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ DEFAULT current_timestamp,
     updated_at TIMESTAMPTZ DEFAULT current_timestamp
 );
 
-CREATE TRIGGER set_coupons_updated_at BEFORE
-UPDATE ON coupons FOR EACH ROW
-EXECUTE FUNCTION set_updated_at_column ();
\ No newline at end of file
+CREATE INDEX cart_reminders_user_id_idx ON cart_reminders (user_id, created_at);
+
+CREATE TRIGGER set_cart_reminders_updated_at BEFORE
+UPDATE ON cart_reminders FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE coupon_redemptions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    coupon_id BIGINT NOT NULL REFERENCES coupons (id),
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    order_id BIGINT NOT NULL UNIQUE REFERENCES orders (id),
+    discount_amount BIGINT NOT NULL CHECK (discount_amount >= 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX coupon_redemptions_coupon_id_idx ON coupon_redemptions (coupon_id, user_id);
+
+CREATE TRIGGER set_coupon_redemptions_updated_at BEFORE
+UPDATE ON coupon_redemptions FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE reward_rules (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (
+        kind IN (
+            'every_nth_order',
+            'spend_threshold',
+            'first_order'
+        )
+    ),
+    order_interval BIGINT CHECK (order_interval > 0),
+    spend_threshold BIGINT CHECK (spend_threshold > 0),
+    discount_type TEXT NOT NULL CHECK (
+        discount_type IN ('percent', 'fixed')
+    ),
+    discount_value BIGINT NOT NULL CHECK (discount_value > 0),
+    max_discount BIGINT CHECK (max_discount > 0),
+    coupon_valid_days BIGINT CHECK (coupon_valid_days > 0),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    CHECK (
+        kind <> 'every_nth_order'
+        OR order_interval IS NOT NULL
+    ),
+    CHECK (
+        kind <> 'spend_threshold'
+        OR spend_threshold IS NOT NULL
+    ),
+    CHECK (
+        discount_type <> 'percent'
+        OR discount_value <= 100
+    )
+);
+
+CREATE TRIGGER set_reward_rules_updated_at BEFORE
+UPDATE ON reward_rules FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE reward_issuances (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    rule_id BIGINT NOT NULL REFERENCES reward_rules (id),
+    order_id BIGINT NOT NULL REFERENCES orders (id),
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    coupon_id BIGINT REFERENCES coupons (id),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (rule_id, order_id)
+);
+
+CREATE INDEX reward_issuances_user_id_idx ON reward_issuances (user_id);
+
+CREATE TRIGGER set_reward_issuances_updated_at BEFORE
+UPDATE ON reward_issuances FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE points_ledger (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    order_id BIGINT REFERENCES orders (id),
+    kind TEXT NOT NULL CHECK (
+        kind IN (
+            'earn',
+            'redeem',
+            'expire',
+            'reverse'
+        )
+    ),
+    points BIGINT NOT NULL CHECK (points <> 0),
+    -- Points of an earn entry that have not been redeemed, reversed or expired yet.
+    remaining BIGINT NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX points_ledger_user_id_idx ON points_ledger (user_id, expires_at);
+
+CREATE INDEX points_ledger_order_id_idx ON points_ledger (order_id);
+
+CREATE TRIGGER set_points_ledger_updated_at BEFORE
+UPDATE ON points_ledger FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE gift_cards (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    code CITEXT NOT NULL UNIQUE,
+    initial_balance BIGINT NOT NULL CHECK (initial_balance > 0),
+    balance BIGINT NOT NULL CHECK (balance >= 0),
+    purchased_by BIGINT REFERENCES users (id),
+    order_id BIGINT REFERENCES orders (id),
+    -- order_item_id is the order item the gift card was bought as, so that refunding the item deactivates the card
+    order_item_id BIGINT REFERENCES order_items (id),
+    issued_by BIGINT REFERENCES users (id),
+    expires_at TIMESTAMPTZ,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX gift_cards_order_item_id_idx ON gift_cards (order_item_id);
+
+CREATE TRIGGER set_gift_cards_updated_at BEFORE
+UPDATE ON gift_cards FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE gift_card_transactions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    gift_card_id BIGINT NOT NULL REFERENCES gift_cards (id),
+    kind TEXT NOT NULL CHECK (
+        kind IN ('issue', 'redeem', 'deactivate')
+    ),
+    amount BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL CHECK (balance_after >= 0),
+    order_id BIGINT REFERENCES orders (id),
+    created_by BIGINT REFERENCES users (id),
+    note TEXT NOT NULL DEFAULT '' CHECK (LENGTH(note) <= 512),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX gift_card_transactions_gift_card_id_idx ON gift_card_transactions (gift_card_id);
+
+CREATE TRIGGER set_gift_card_transactions_updated_at BEFORE
+UPDATE ON gift_card_transactions FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE store_credit_accounts (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id),
+    balance BIGINT NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE TRIGGER set_store_credit_accounts_updated_at BEFORE
+UPDATE ON store_credit_accounts FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE store_credit_transactions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES store_credit_accounts (user_id),
+    kind TEXT NOT NULL CHECK (
+        kind IN ('issue', 'redeem', 'refund')
+    ),
+    amount BIGINT NOT NULL CHECK (amount <> 0),
+    balance_after BIGINT NOT NULL CHECK (balance_after >= 0),
+    order_id BIGINT REFERENCES orders (id),
+    refund_id BIGINT REFERENCES refunds (id),
+    created_by BIGINT REFERENCES users (id),
+    note TEXT NOT NULL DEFAULT '' CHECK (LENGTH(note) <= 512),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX store_credit_transactions_user_id_idx ON store_credit_transactions (user_id);
+
+CREATE TRIGGER set_store_credit_transactions_updated_at BEFORE
+UPDATE ON store_credit_transactions FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE promotions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL CHECK (LENGTH(name) <= 128),
+    kind TEXT NOT NULL CHECK (
+        kind IN (
+            'buy_x_get_y',
+            'category_percent',
+            'quantity_tiers'
+        )
+    ),
+    product_ids JSONB NOT NULL DEFAULT '[]',
+    category TEXT NOT NULL DEFAULT '',
+    buy_quantity BIGINT NOT NULL DEFAULT 0 CHECK (buy_quantity >= 0),
+    get_quantity BIGINT NOT NULL DEFAULT 0 CHECK (get_quantity >= 0),
+    get_percent BIGINT NOT NULL DEFAULT 0 CHECK (get_percent BETWEEN 0 AND 100),
+    percent BIGINT NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100),
+    tiers JSONB NOT NULL DEFAULT '[]',
+    priority BIGINT NOT NULL DEFAULT 0,
+    is_stackable BOOLEAN NOT NULL DEFAULT TRUE,
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE TRIGGER set_promotions_updated_at BEFORE
+UPDATE ON promotions FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE order_promotions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id),
+    promotion_id BIGINT NOT NULL REFERENCES promotions (id),
+    -- Name of the promotion when the order was placed.
+    name TEXT NOT NULL,
+    product_ids JSONB NOT NULL DEFAULT '[]',
+    discount BIGINT NOT NULL CHECK (discount > 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX order_promotions_order_id_idx ON order_promotions (order_id);
+
+CREATE TRIGGER set_order_promotions_updated_at BEFORE
+UPDATE ON order_promotions FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE idempotency_keys (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    -- scope is who sent the request, so that clients can't replay each other's responses
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL CHECK (LENGTH(key) <= 255),
+    -- fingerprint is a hash of the method, URI and body of the request
+    fingerprint TEXT NOT NULL,
+    -- status_code is NULL while the first request with the key is being handled
+    status_code BIGINT,
+    content_type TEXT NOT NULL DEFAULT '',
+    response_body BYTEA,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (scope, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+CREATE TRIGGER set_idempotency_keys_updated_at BEFORE
+UPDATE ON idempotency_keys FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- Invoice numbers run from 1 every year without gaps. The last number of each year is taken in the same transaction as the invoice, so a failed invoice doesn't use one up.
+CREATE TABLE invoice_sequences (
+    year BIGINT PRIMARY KEY,
+    last_number BIGINT NOT NULL CHECK (last_number > 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE TRIGGER set_invoice_sequences_updated_at BEFORE
+UPDATE ON invoice_sequences FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE invoices (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id BIGINT NOT NULL UNIQUE REFERENCES orders (id),
+    year BIGINT NOT NULL,
+    number BIGINT NOT NULL CHECK (number > 0),
+    -- file_name is the key of the PDF in the blob store, NULL until it is stored
+    file_name TEXT,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (year, number)
+);
+
+CREATE TRIGGER set_invoices_updated_at BEFORE
+UPDATE ON invoices FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- Reviews can only be written for products the user bought, and are shown once approved by an admin.
+CREATE TABLE reviews (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    -- order_id is the order in which the user bought the product
+    order_id BIGINT NOT NULL REFERENCES orders (id),
+    rating BIGINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body TEXT NOT NULL DEFAULT '' CHECK (LENGTH(body) <= 5000),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (
+        status IN ('pending', 'approved', 'rejected')
+    ),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (product_id, user_id)
+);
+
+CREATE INDEX reviews_status_idx ON reviews (status, product_id, id);
+
+CREATE TRIGGER set_reviews_updated_at BEFORE
+UPDATE ON reviews FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- product_affinities is rebuilt from the order history by a job. score is the share of the orders with product_id that also had related_product_id.
+CREATE TABLE product_affinities (
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    related_product_id BIGINT NOT NULL REFERENCES products (id),
+    -- orders is how many orders had both products
+    orders BIGINT NOT NULL CHECK (orders > 0),
+    score DOUBLE PRECISION NOT NULL CHECK (score > 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    PRIMARY KEY (product_id, related_product_id)
+);
+
+CREATE TRIGGER set_product_affinities_updated_at BEFORE
+UPDATE ON product_affinities FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- A return goes from requested to approved or rejected, then received, inspected and completed, when the item is refunded and, if it can be sold again, restocked.
+CREATE TABLE returns (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id),
+    order_item_id BIGINT NOT NULL REFERENCES order_items (id),
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    reason TEXT NOT NULL CHECK (
+        reason IN (
+            'damaged',
+            'defective',
+            'wrong_item',
+            'not_as_described',
+            'no_longer_needed',
+            'other'
+        )
+    ),
+    comment TEXT NOT NULL DEFAULT '' CHECK (LENGTH(comment) <= 2000),
+    status TEXT NOT NULL DEFAULT 'requested' CHECK (
+        status IN (
+            'requested',
+            'approved',
+            'rejected',
+            'received',
+            'inspected',
+            'completed'
+        )
+    ),
+    -- admin_note is what the admin said when the return last changed status
+    admin_note TEXT NOT NULL DEFAULT '' CHECK (LENGTH(admin_note) <= 512),
+    -- is_resellable is set when the item is inspected, and decides whether it is restocked
+    is_resellable BOOLEAN,
+    refund_id BIGINT REFERENCES refunds (id),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX returns_user_id_idx ON returns (user_id, id);
+
+CREATE INDEX returns_order_item_id_idx ON returns (order_item_id);
+
+CREATE INDEX returns_status_idx ON returns (status, id);
+
+CREATE TRIGGER set_returns_updated_at BEFORE
+UPDATE ON returns FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- Photos are uploaded by the customer straight to the blob store, with a presigned link.
+CREATE TABLE return_photos (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    return_id BIGINT NOT NULL REFERENCES returns (id),
+    file_name TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX return_photos_return_id_idx ON return_photos (return_id);
+
+CREATE TRIGGER set_return_photos_updated_at BEFORE
+UPDATE ON return_photos FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- Subscriptions reorder a snapshot of a cart every interval_days days. next_order_at is when the next order is due.
+CREATE TABLE subscriptions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    interval_days BIGINT NOT NULL CHECK (interval_days BETWEEN 1 AND 365),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (
+        status IN ('active', 'paused', 'cancelled')
+    ),
+    next_order_at TIMESTAMPTZ NOT NULL,
+    last_order_id BIGINT REFERENCES orders (id),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp
+);
+
+CREATE INDEX subscriptions_user_id_idx ON subscriptions (user_id);
+
+CREATE INDEX subscriptions_next_order_at_idx ON subscriptions (status, next_order_at);
+
+CREATE TRIGGER set_subscriptions_updated_at BEFORE
+UPDATE ON subscriptions FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE subscription_items (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES subscriptions (id),
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (subscription_id, product_id)
+);
+
+CREATE TRIGGER set_subscription_items_updated_at BEFORE
+UPDATE ON subscription_items FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- order_allocations are the warehouses that each order item ships from
+CREATE TABLE order_allocations (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_item_id BIGINT NOT NULL REFERENCES order_items (id),
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses (id),
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    UNIQUE (order_item_id, warehouse_id)
+);
+
+CREATE TRIGGER set_order_allocations_updated_at BEFORE
+UPDATE ON order_allocations FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+CREATE TABLE stock_transfers (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    from_warehouse_id BIGINT NOT NULL REFERENCES warehouses (id),
+    to_warehouse_id BIGINT NOT NULL REFERENCES warehouses (id),
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    reason TEXT NOT NULL DEFAULT '' CHECK (LENGTH(reason) <= 512),
+    created_by BIGINT NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    CHECK (from_warehouse_id <> to_warehouse_id)
+);
+
+CREATE INDEX stock_transfers_product_id_idx ON stock_transfers (product_id, id);
+
+CREATE TRIGGER set_stock_transfers_updated_at BEFORE
+UPDATE ON stock_transfers FOR EACH ROW
+EXECUTE FUNCTION set_updated_at_column ();
+
+-- Welcome coupon for new customers, once per user.
+INSERT INTO
+    coupons (code, discount_type, discount_value)
+VALUES ('UNIBLOX10', 'percent', 10);
+
+-- Loyalty reward for every 5th order.
+INSERT INTO
+    reward_rules (
+        name,
+        kind,
+        order_interval,
+        discount_type,
+        discount_value
+    )
+VALUES (
+        'Every 5th order',
+        'every_nth_order',
+        5,
+        'percent',
+        10
+    );
+
+-- Warehouse that stock goes to until others are added.
+INSERT INTO
+    warehouses (
+        name,
+        latitude,
+        longitude,
+        is_default
+    )
+VALUES ('Main', 0, 0, TRUE);
+
+-- Gift card that customers can buy like any other product. Every unit bought is issued as a gift card worth its price.
+-- Its stock is tracked on purpose, so that its sales go through checkout holds, allocation and the inventory ledger like those of any other product and stay reconcilable. The stock is large enough not to run out, and can be restocked like any other; the low stock digest and stock metrics leave gift cards out.
+INSERT INTO
+    products (
+        name,
+        price,
+        quantity_left,
+        is_gift_card
+    )
+VALUES ('Gift card', 100000, 1000000, TRUE);
@@ -0,0 +1,101 @@
+// Package pricing computes the totals of a cart. Both cart previews and order creation go through it, so the two never disagree.
+package pricing
+
+// Line is a product in a cart. Prices are in the smallest unit of the currency.
+type Line struct {
+	// Category of the product, for promotions on a category.
+	Category  string
+	ProductID int
+	UnitPrice int
+	Quantity  int
+	// NoPromotions keeps promotions, discounts, points and tax off the line, e.g. for gift cards, which are worth what is paid for them.
+	NoPromotions bool
+}
+
+type Options struct {
+	// Promotions are applied to the lines before anything else.
+	Promotions []Promotion
+	// DiscountPercent is taken off the subtotal after promotions, e.g. by a coupon.
+	DiscountPercent int
+	// DiscountAmount is a fixed amount taken off the subtotal after promotions.
+	DiscountAmount int
+	// MaxDiscount caps the discount. 0 means no cap.
+	MaxDiscount int
+	// PointsAmount is the value of the loyalty points redeemed. It is taken off after the discount, never exceeds what is left, and doesn't count towards the cap.
+	PointsAmount int
+	// TaxRate is charged on the discounted subtotal, in basis points, e.g. 1800 for 18%.
+	TaxRate int
+	// ShippingFee is charged on every order that is not shipped for free.
+	ShippingFee int
+	// FreeShippingThreshold is the discounted subtotal, before points, from which shipping is free. 0 means shipping is never free.
+	FreeShippingThreshold int
+}
+
+type LineSummary struct {
+	ProductID int `json:"productId"`
+	UnitPrice int `json:"unitPrice"`
+	Quantity  int `json:"quantity"`
+	Subtotal  int `json:"subtotal"`
+	// Discount is what promotions took off the line.
+	Discount int `json:"discount"`
+}
+
+type Summary struct {
+	Lines []LineSummary `json:"lines"`
+	// Promotions explain the promotions that were applied.
+	Promotions []AppliedPromotion `json:"promotions"`
+	Subtotal   int                `json:"subtotal"`
+	// ExemptSubtotal is the part of the subtotal from lines with NoPromotions. It is paid in full, without tax.
+	ExemptSubtotal    int `json:"exemptSubtotal"`
+	PromotionDiscount int `json:"promotionDiscount"`
+	Discount          int `json:"discount"`
+	// PointsDiscount is the value of the loyalty points redeemed.
+	PointsDiscount int `json:"pointsDiscount"`
+	Tax            int `json:"tax"`
+	Shipping       int `json:"shipping"`
+	// Total is what the customer pays: subtotal - promotion discount - discount - points discount + tax + shipping.
+	Total int `json:"total"`
+}
+
+// Calculate prices the given lines. Discounts are rounded down and never exceed the subtotal; tax is rounded half up.
+func Calculate(lines []Line, opts Options) Summary {
+	summary := Summary{Lines: make([]LineSummary, 0, len(lines)), Promotions: make([]AppliedPromotion, 0)}
+	for _, line := range lines {
+		subtotal := line.UnitPrice * line.Quantity
+		summary.Lines = append(summary.Lines, LineSummary{
+			ProductID: line.ProductID,
+			UnitPrice: line.UnitPrice,
+			Quantity:  line.Quantity,
+			Subtotal:  subtotal,
+		})
+		summary.Subtotal += subtotal
+		if line.NoPromotions {
+			summary.ExemptSubtotal += subtotal
+		}
+	}
+	if summary.Subtotal == 0 {
+		return summary
+	}
+
+	summary.Promotions = applyPromotions(&summary, lines, opts.Promotions)
+	for _, promotion := range summary.Promotions {
+		summary.PromotionDiscount += promotion.Discount
+	}
+	// Exempt lines are left out of everything that follows, so that e.g. a coupon can't buy a gift card for less than it is worth
+	promoted := summary.Subtotal - summary.PromotionDiscount - summary.ExemptSubtotal
+
+	summary.Discount = promoted*min(max(opts.DiscountPercent, 0), 100)/100 + max(opts.DiscountAmount, 0)
+	if opts.MaxDiscount > 0 {
+		summary.Discount = min(summary.Discount, opts.MaxDiscount)
+	}
+	summary.Discount = min(summary.Discount, promoted)
+	discounted := promoted - summary.Discount
+	summary.PointsDiscount = min(max(opts.PointsAmount, 0), discounted)
+	taxable := discounted - summary.PointsDiscount
+	summary.Tax = (taxable*opts.TaxRate + 5000) / 10000
+	if opts.FreeShippingThreshold == 0 || discounted+summary.ExemptSubtotal < opts.FreeShippingThreshold {
+		summary.Shipping = opts.ShippingFee
+	}
+	summary.Total = taxable + summary.ExemptSubtotal + summary.Tax + summary.Shipping
+	return summary
+}
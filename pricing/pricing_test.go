@@ -0,0 +1,92 @@
+package pricing_test
+
+import (
+	"testing"
+
+	"github.com/rohitxdev/go-api-starter/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculate(t *testing.T) {
+	lines := []pricing.Line{
+		{ProductID: 1, UnitPrice: 1000, Quantity: 2},
+		{ProductID: 2, UnitPrice: 499, Quantity: 1},
+	}
+
+	t.Run("No options", func(t *testing.T) {
+		summary := pricing.Calculate(lines, pricing.Options{})
+		assert.Equal(t, 2499, summary.Subtotal)
+		assert.Equal(t, 2499, summary.Total)
+		assert.Equal(t, 2000, summary.Lines[0].Subtotal)
+		assert.Equal(t, 499, summary.Lines[1].Subtotal)
+	})
+
+	t.Run("Discount, tax and shipping", func(t *testing.T) {
+		summary := pricing.Calculate(lines, pricing.Options{DiscountPercent: 10, TaxRate: 1800, ShippingFee: 500})
+		assert.Equal(t, 249, summary.Discount)
+		// 18% of 2250 is 405
+		assert.Equal(t, 405, summary.Tax)
+		assert.Equal(t, 500, summary.Shipping)
+		assert.Equal(t, 2499-249+405+500, summary.Total)
+	})
+
+	t.Run("Fixed discount", func(t *testing.T) {
+		summary := pricing.Calculate(lines, pricing.Options{DiscountAmount: 500})
+		assert.Equal(t, 500, summary.Discount)
+		assert.Equal(t, 1999, summary.Total)
+
+		// A discount never exceeds the subtotal
+		summary = pricing.Calculate(lines, pricing.Options{DiscountAmount: 5000})
+		assert.Equal(t, 2499, summary.Discount)
+		assert.Equal(t, 0, summary.Total)
+	})
+
+	t.Run("Discount cap", func(t *testing.T) {
+		summary := pricing.Calculate(lines, pricing.Options{DiscountPercent: 50, MaxDiscount: 1000})
+		assert.Equal(t, 1000, summary.Discount)
+	})
+
+	t.Run("Points", func(t *testing.T) {
+		summary := pricing.Calculate(lines, pricing.Options{DiscountPercent: 50, MaxDiscount: 1000, PointsAmount: 300, TaxRate: 1000})
+		assert.Equal(t, 1000, summary.Discount)
+		assert.Equal(t, 300, summary.PointsDiscount)
+		// Tax is charged on what is left after points
+		assert.Equal(t, 120, summary.Tax)
+		assert.Equal(t, 2499-1000-300+120, summary.Total)
+
+		// Points never exceed what is left after the discount
+		summary = pricing.Calculate(lines, pricing.Options{DiscountAmount: 499, PointsAmount: 5000})
+		assert.Equal(t, 2000, summary.PointsDiscount)
+		assert.Equal(t, 0, summary.Total)
+	})
+
+	t.Run("Exempt lines", func(t *testing.T) {
+		giftCard := pricing.Line{ProductID: 3, UnitPrice: 10000, Quantity: 1, NoPromotions: true}
+		summary := pricing.Calculate(append(lines, giftCard), pricing.Options{DiscountPercent: 10, PointsAmount: 5000, TaxRate: 1000})
+		assert.Equal(t, 10000, summary.ExemptSubtotal)
+		// Neither the discount, the points nor tax touch the gift card
+		assert.Equal(t, 249, summary.Discount)
+		assert.Equal(t, 2250, summary.PointsDiscount)
+		assert.Equal(t, 0, summary.Tax)
+		assert.Equal(t, 10000, summary.Total)
+	})
+
+	t.Run("Tax is rounded half up", func(t *testing.T) {
+		summary := pricing.Calculate([]pricing.Line{{ProductID: 1, UnitPrice: 25, Quantity: 1}}, pricing.Options{TaxRate: 1000})
+		assert.Equal(t, 3, summary.Tax)
+	})
+
+	t.Run("Free shipping", func(t *testing.T) {
+		summary := pricing.Calculate(lines, pricing.Options{ShippingFee: 500, FreeShippingThreshold: 2000})
+		assert.Equal(t, 0, summary.Shipping)
+
+		// The threshold applies after discount
+		summary = pricing.Calculate(lines, pricing.Options{DiscountPercent: 50, ShippingFee: 500, FreeShippingThreshold: 2000})
+		assert.Equal(t, 500, summary.Shipping)
+	})
+
+	t.Run("Empty cart", func(t *testing.T) {
+		summary := pricing.Calculate(nil, pricing.Options{DiscountPercent: 10, TaxRate: 1800, ShippingFee: 500})
+		assert.Equal(t, pricing.Summary{Lines: []pricing.LineSummary{}, Promotions: []pricing.AppliedPromotion{}}, summary)
+	})
+}
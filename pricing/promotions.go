@@ -0,0 +1,110 @@
+package pricing
+
+import "slices"
+
+// Kinds of promotions.
+const (
+	// PromotionBuyXGetY discounts GetQuantity units by GetPercent for every BuyQuantity units bought, e.g. buy 2 get 1 free.
+	PromotionBuyXGetY = "buy_x_get_y"
+	// PromotionCategoryPercent takes Percent off every product in Category.
+	PromotionCategoryPercent = "category_percent"
+	// PromotionQuantityTiers takes the Percent of the highest tier reached by the quantity of a product off that product.
+	PromotionQuantityTiers = "quantity_tiers"
+)
+
+type Tier struct {
+	MinQuantity int `json:"minQuantity"`
+	Percent     int `json:"percent"`
+}
+
+// Promotion is a discount applied to the cart without a code.
+type Promotion struct {
+	Name     string
+	Kind     string
+	Category string
+	// ProductIDs limits buy x get y and quantity tier promotions to these products. Empty means every product.
+	ProductIDs  []int
+	Tiers       []Tier
+	ID          int
+	BuyQuantity int
+	GetQuantity int
+	GetPercent  int
+	Percent     int
+	// Promotions with a higher priority are applied first.
+	Priority int
+	// Stackable promotions combine with the other stackable promotions on a product. A product discounted by a promotion that isn't stackable gets no other promotion, and the other way around.
+	Stackable bool
+}
+
+// AppliedPromotion explains a promotion that discounted the cart.
+type AppliedPromotion struct {
+	Name       string `json:"name"`
+	ProductIDs []int  `json:"productIds"`
+	ID         int    `json:"id"`
+	Discount   int    `json:"discount"`
+}
+
+func (p *Promotion) appliesTo(line Line) bool {
+	if line.NoPromotions {
+		return false
+	}
+	if p.Kind == PromotionCategoryPercent {
+		return p.Category != "" && line.Category == p.Category
+	}
+	return len(p.ProductIDs) == 0 || slices.Contains(p.ProductIDs, line.ProductID)
+}
+
+// discount returns how much the promotion takes off the line, of which amount is left after the promotions before it.
+func (p *Promotion) discount(line Line, amount int) int {
+	var discount int
+	switch p.Kind {
+	case PromotionBuyXGetY:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 {
+			return 0
+		}
+		units := line.Quantity / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+		discount = units * line.UnitPrice * min(max(p.GetPercent, 0), 100) / 100
+	case PromotionCategoryPercent:
+		discount = amount * min(max(p.Percent, 0), 100) / 100
+	case PromotionQuantityTiers:
+		var percent int
+		for _, tier := range p.Tiers {
+			if line.Quantity >= tier.MinQuantity && tier.Percent > percent {
+				percent = tier.Percent
+			}
+		}
+		discount = amount * min(percent, 100) / 100
+	}
+	return min(discount, amount)
+}
+
+// applyPromotions discounts the lines of the summary by the promotions, highest priority first, and returns what each promotion took off.
+func applyPromotions(summary *Summary, lines []Line, promotions []Promotion) []AppliedPromotion {
+	promotions = slices.Clone(promotions)
+	slices.SortStableFunc(promotions, func(a, b Promotion) int { return b.Priority - a.Priority })
+
+	// exclusive marks the lines that a promotion that isn't stackable has taken
+	exclusive := make([]bool, len(lines))
+	applied := make([]AppliedPromotion, 0)
+	for _, promotion := range promotions {
+		result := AppliedPromotion{ID: promotion.ID, Name: promotion.Name, ProductIDs: make([]int, 0)}
+		for i, line := range lines {
+			lineSummary := &summary.Lines[i]
+			if exclusive[i] || (!promotion.Stackable && lineSummary.Discount > 0) || !promotion.appliesTo(line) {
+				continue
+			}
+			discount := promotion.discount(line, lineSummary.Subtotal-lineSummary.Discount)
+			if discount <= 0 {
+				continue
+			}
+			lineSummary.Discount += discount
+			exclusive[i] = !promotion.Stackable
+			result.Discount += discount
+			result.ProductIDs = append(result.ProductIDs, line.ProductID)
+		}
+		if result.Discount > 0 {
+			applied = append(applied, result)
+		}
+	}
+	return applied
+}
@@ -0,0 +1,79 @@
+package pricing_test
+
+import (
+	"testing"
+
+	"github.com/rohitxdev/go-api-starter/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromotions(t *testing.T) {
+	lines := []pricing.Line{
+		{ProductID: 1, UnitPrice: 1000, Quantity: 3, Category: "shoes"},
+		{ProductID: 2, UnitPrice: 500, Quantity: 10, Category: "socks"},
+	}
+
+	t.Run("Buy x get y", func(t *testing.T) {
+		promotion := pricing.Promotion{ID: 1, Name: "Buy 2 get 1 free", Kind: pricing.PromotionBuyXGetY, ProductIDs: []int{1}, BuyQuantity: 2, GetQuantity: 1, GetPercent: 100}
+		summary := pricing.Calculate(lines, pricing.Options{Promotions: []pricing.Promotion{promotion}})
+		assert.Equal(t, 1000, summary.PromotionDiscount)
+		assert.Equal(t, 1000, summary.Lines[0].Discount)
+		assert.Equal(t, 0, summary.Lines[1].Discount)
+		assert.Equal(t, []pricing.AppliedPromotion{{ID: 1, Name: "Buy 2 get 1 free", ProductIDs: []int{1}, Discount: 1000}}, summary.Promotions)
+		assert.Equal(t, 8000-1000, summary.Total)
+	})
+
+	t.Run("Excluded lines", func(t *testing.T) {
+		giftCard := pricing.Line{ProductID: 3, UnitPrice: 5000, Quantity: 3, NoPromotions: true}
+		promotion := pricing.Promotion{ID: 1, Kind: pricing.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, GetPercent: 100}
+		summary := pricing.Calculate(append(lines, giftCard), pricing.Options{Promotions: []pricing.Promotion{promotion}})
+		assert.Equal(t, 0, summary.Lines[2].Discount)
+		assert.Equal(t, []int{1, 2}, summary.Promotions[0].ProductIDs)
+	})
+
+	t.Run("Category percent", func(t *testing.T) {
+		promotion := pricing.Promotion{ID: 1, Kind: pricing.PromotionCategoryPercent, Category: "socks", Percent: 20}
+		summary := pricing.Calculate(lines, pricing.Options{Promotions: []pricing.Promotion{promotion}})
+		assert.Equal(t, 1000, summary.PromotionDiscount)
+		assert.Equal(t, 1000, summary.Lines[1].Discount)
+	})
+
+	t.Run("Quantity tiers", func(t *testing.T) {
+		promotion := pricing.Promotion{ID: 1, Kind: pricing.PromotionQuantityTiers, Tiers: []pricing.Tier{{MinQuantity: 5, Percent: 5}, {MinQuantity: 10, Percent: 10}, {MinQuantity: 20, Percent: 20}}}
+		summary := pricing.Calculate(lines, pricing.Options{Promotions: []pricing.Promotion{promotion}})
+		// Only socks reach a tier
+		assert.Equal(t, 0, summary.Lines[0].Discount)
+		assert.Equal(t, 500, summary.Lines[1].Discount)
+	})
+
+	t.Run("Stacking and priority", func(t *testing.T) {
+		category := pricing.Promotion{ID: 1, Kind: pricing.PromotionCategoryPercent, Category: "socks", Percent: 20, Stackable: true}
+		tiers := pricing.Promotion{ID: 2, Kind: pricing.PromotionQuantityTiers, Tiers: []pricing.Tier{{MinQuantity: 10, Percent: 10}}, Stackable: true}
+
+		// Stackable promotions apply one after the other, each on what is left
+		summary := pricing.Calculate(lines, pricing.Options{Promotions: []pricing.Promotion{category, tiers}})
+		assert.Equal(t, 1000+400, summary.Lines[1].Discount)
+
+		// A promotion that isn't stackable keeps the lines it applies to, if it comes first
+		tiers.Stackable = false
+		tiers.Priority = 1
+		summary = pricing.Calculate(lines, pricing.Options{Promotions: []pricing.Promotion{category, tiers}})
+		assert.Equal(t, 500, summary.Lines[1].Discount)
+		assert.Len(t, summary.Promotions, 1)
+		assert.Equal(t, 2, summary.Promotions[0].ID)
+
+		// and is skipped on lines another promotion already discounted
+		tiers.Priority = -1
+		summary = pricing.Calculate(lines, pricing.Options{Promotions: []pricing.Promotion{category, tiers}})
+		assert.Equal(t, 1000, summary.Lines[1].Discount)
+		assert.Equal(t, 1, summary.Promotions[0].ID)
+	})
+
+	t.Run("Coupons apply after promotions", func(t *testing.T) {
+		promotion := pricing.Promotion{ID: 1, Kind: pricing.PromotionCategoryPercent, Category: "socks", Percent: 20}
+		summary := pricing.Calculate(lines, pricing.Options{Promotions: []pricing.Promotion{promotion}, DiscountPercent: 10})
+		assert.Equal(t, 1000, summary.PromotionDiscount)
+		assert.Equal(t, 700, summary.Discount)
+		assert.Equal(t, 8000-1000-700, summary.Total)
+	})
+}
@@ -5,12 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 )
 
 var (
 	ErrCartNotFound             = errors.New("cart not found")
 	ErrCartItemNotFound         = errors.New("cart item not found")
 	ErrCartItemQuantityExceeded = errors.New("cart item quantity exceeded")
+	ErrCartEmpty                = errors.New("cart is empty")
 )
 
 type CartItem struct {
@@ -23,8 +25,12 @@ type CartItem struct {
 }
 
 func (r *Repo) GetCart(ctx context.Context, userID int) ([]CartItem, error) {
+	return getCart(ctx, r.db, userID)
+}
+
+func getCart(ctx context.Context, q querier, userID int) ([]CartItem, error) {
 	cartItems := make([]CartItem, 0)
-	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, product_id, quantity, created_at, updated_at FROM cart_items WHERE user_id=$1 ORDER BY created_at DESC;`, userID)
+	rows, err := q.QueryContext(ctx, `SELECT id, user_id, product_id, quantity, created_at, updated_at FROM cart_items WHERE user_id=$1 ORDER BY created_at DESC;`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -54,8 +60,24 @@ func (r *Repo) GetCartItem(ctx context.Context, userID int, productID int) (*Car
 	return &cartItem, nil
 }
 
+// AddToCart puts one of the product in the cart. Adding a product that is already in the cart leaves it as is. Products that are out of stock for the user can't be added.
 func (r *Repo) AddToCart(ctx context.Context, userID int, productID int) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO cart_items(user_id, product_id, quantity) VALUES($1, $2, $3);`, userID, productID, 1)
+	var quantityLeft int
+	if err := r.db.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id = $1;`, productID).Scan(&quantityLeft); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to get product: %w", err)
+	}
+	held, err := heldByOthers(ctx, r.db, productID, userID)
+	if err != nil {
+		return err
+	}
+	if quantityLeft-held <= 0 {
+		return ErrInsufficientStock
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO cart_items(user_id, product_id, quantity) VALUES($1, $2, $3) ON CONFLICT (user_id, product_id) DO NOTHING;`, userID, productID, 1)
 	return err
 }
 
@@ -73,14 +95,18 @@ func (r *Repo) UpdateCartItemQuantity(ctx context.Context, userID int, productID
 	}()
 
 	var productQuantityLeft int
-	err = r.db.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id=$1 FOR UPDATE;`, productID).Scan(&productQuantityLeft)
+	err = tx.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id=$1 FOR UPDATE;`, productID).Scan(&productQuantityLeft)
 	if err != nil {
 		return err
 	}
-	if quantity > productQuantityLeft {
+	held, err := heldByOthers(ctx, tx, productID, userID)
+	if err != nil {
+		return err
+	}
+	if quantity > productQuantityLeft-held {
 		return ErrCartItemQuantityExceeded
 	}
-	_, err = r.db.ExecContext(ctx, `UPDATE cart_items SET quantity=$1 WHERE user_id=$2 AND product_id=$3;`, quantity, userID, productID)
+	_, err = tx.ExecContext(ctx, `UPDATE cart_items SET quantity=$1 WHERE user_id=$2 AND product_id=$3;`, quantity, userID, productID)
 	return err
 }
 
@@ -105,7 +131,9 @@ func (r *Repo) GetCartWithProducts(ctx context.Context, userID int) ([]CartItem,
 	query := `
 		SELECT 
 			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.created_at, ci.updated_at,
-			p.id, p.name, p.price, p.quantity_left, p.created_at, p.updated_at
+			p.id, p.name, p.price, p.quantity_left,
+			p.quantity_left - COALESCE((SELECT SUM(sr.quantity) FROM stock_reservations sr WHERE sr.product_id = p.id AND sr.user_id <> ci.user_id AND sr.expires_at > current_timestamp), 0),
+			p.is_gift_card, p.category, p.created_at, p.updated_at
 		FROM cart_items ci
 		LEFT JOIN products p ON p.id = ci.product_id
 		WHERE ci.user_id = $1;`
@@ -133,6 +161,9 @@ func (r *Repo) GetCartWithProducts(ctx context.Context, userID int) ([]CartItem,
 			&product.Name,
 			&product.Price,
 			&product.QuantityLeft,
+			&product.QuantityAvailable,
+			&product.IsGiftCard,
+			&product.Category,
 			&product.CreatedAt,
 			&product.UpdatedAt,
 		)
@@ -150,3 +181,173 @@ func (r *Repo) DeleteCartItem(ctx context.Context, userID int, productID int) er
 	_, err := r.db.ExecContext(ctx, `DELETE FROM cart_items WHERE user_id=$1 AND product_id=$2;`, userID, productID)
 	return err
 }
+
+// MergeCart adds the items of a guest cart to the user's cart. Quantities of products already in the cart are added up and capped at the stock available to the user. Products that are out of stock or no longer exist are skipped.
+func (r *Repo) MergeCart(ctx context.Context, userID int, items []CartItem) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Lock products in a stable order so that concurrent merges and checkouts can't deadlock.
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b CartItem) int { return a.ProductID - b.ProductID })
+
+	for _, item := range items {
+		var quantityLeft int
+		err = tx.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id=$1 FOR UPDATE;`, item.ProductID).Scan(&quantityLeft)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				err = nil
+				continue
+			}
+			return fmt.Errorf("failed to check product quantity: %w", err)
+		}
+		var held int
+		if held, err = heldByOthers(ctx, tx, item.ProductID, userID); err != nil {
+			return err
+		}
+		available := quantityLeft - held
+		if available <= 0 {
+			continue
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO cart_items(user_id, product_id, quantity) VALUES($1, $2, LEAST($3::BIGINT, $4::BIGINT))
+			 ON CONFLICT (user_id, product_id) DO UPDATE SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, $4::BIGINT);`,
+			userID, item.ProductID, item.Quantity, available,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to merge cart item: %w", err)
+		}
+	}
+	return nil
+}
+
+// Reasons why a cart line was clamped or rejected.
+const (
+	CartLineProductNotFound = "product not found"
+	CartLineOutOfStock      = "out of stock"
+	CartLineNotEnoughStock  = "not enough stock"
+)
+
+// CartLine is the quantity of a product wanted in a cart.
+type CartLine struct {
+	ProductID int `json:"productId"`
+	Quantity  int `json:"quantity"`
+}
+
+// CartLineIssue describes a cart line that couldn't be taken as it was asked for.
+type CartLineIssue struct {
+	Reason            string `json:"reason"`
+	ProductID         int    `json:"productId"`
+	RequestedQuantity int    `json:"requestedQuantity"`
+	// Quantity is what was put in the cart instead. It is 0 for rejected lines.
+	Quantity int `json:"quantity"`
+}
+
+type ReplaceCartResult struct {
+	Cart []CartItem `json:"cart"`
+	// Clamped lines were put in the cart with less quantity than asked for.
+	Clamped []CartLineIssue `json:"clamped"`
+	// Rejected lines were left out of the cart.
+	Rejected []CartLineIssue `json:"rejected"`
+}
+
+func NewReplaceCartResult() *ReplaceCartResult {
+	return &ReplaceCartResult{
+		Cart:     make([]CartItem, 0),
+		Clamped:  make([]CartLineIssue, 0),
+		Rejected: make([]CartLineIssue, 0),
+	}
+}
+
+// Resolve checks a cart line against the stock available and returns the quantity that can go in the cart, noting the line as clamped or rejected if it can't be taken as is. A quantity of 0 means the line is rejected.
+func (res *ReplaceCartResult) Resolve(line CartLine, available int, exists bool) int {
+	switch {
+	case !exists:
+		res.Rejected = append(res.Rejected, CartLineIssue{ProductID: line.ProductID, RequestedQuantity: line.Quantity, Reason: CartLineProductNotFound})
+		return 0
+	case available <= 0:
+		res.Rejected = append(res.Rejected, CartLineIssue{ProductID: line.ProductID, RequestedQuantity: line.Quantity, Reason: CartLineOutOfStock})
+		return 0
+	case line.Quantity > available:
+		res.Clamped = append(res.Clamped, CartLineIssue{ProductID: line.ProductID, RequestedQuantity: line.Quantity, Quantity: available, Reason: CartLineNotEnoughStock})
+		return available
+	}
+	return line.Quantity
+}
+
+// NormalizeCartLines adds up the quantities of lines for the same product and sorts the lines by product.
+func NormalizeCartLines(lines []CartLine) []CartLine {
+	quantities := make(map[int]int, len(lines))
+	for _, line := range lines {
+		quantities[line.ProductID] += line.Quantity
+	}
+	normalized := make([]CartLine, 0, len(quantities))
+	for productID, quantity := range quantities {
+		normalized = append(normalized, CartLine{ProductID: productID, Quantity: quantity})
+	}
+	slices.SortFunc(normalized, func(a, b CartLine) int { return a.ProductID - b.ProductID })
+	return normalized
+}
+
+// ReplaceCart replaces the whole cart of the user with the given lines. Every line is checked against the stock available to the user; lines asking for more than there is are clamped, and lines for products that are out of stock or don't exist are rejected.
+func (r *Repo) ReplaceCart(ctx context.Context, userID int, lines []CartLine) (result *ReplaceCartResult, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	result = NewReplaceCartResult()
+	kept := make([]int, 0, len(lines))
+	// Lines are sorted by product, so products are locked in a stable order.
+	for _, line := range NormalizeCartLines(lines) {
+		exists := true
+		var quantityLeft, held int
+		err = tx.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id=$1 FOR UPDATE;`, line.ProductID).Scan(&quantityLeft)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				return nil, fmt.Errorf("failed to check product quantity: %w", err)
+			}
+			exists = false
+		} else if held, err = heldByOthers(ctx, tx, line.ProductID, userID); err != nil {
+			return nil, err
+		}
+
+		quantity := result.Resolve(line, quantityLeft-held, exists)
+		if quantity == 0 {
+			continue
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO cart_items(user_id, product_id, quantity) VALUES($1, $2, $3)
+			 ON CONFLICT (user_id, product_id) DO UPDATE SET quantity = EXCLUDED.quantity;`,
+			userID, line.ProductID, quantity,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set cart item: %w", err)
+		}
+		kept = append(kept, line.ProductID)
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM cart_items WHERE user_id=$1 AND NOT (product_id = ANY($2));`, userID, kept); err != nil {
+		return nil, fmt.Errorf("failed to delete cart items: %w", err)
+	}
+	if result.Cart, err = getCart(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// cartReminderAttributionWindow is how long after a reminder an order still counts as converted by it.
+const cartReminderAttributionWindow = `INTERVAL '7 days'`
+
+// AbandonedCart is a cart whose owner hasn't touched it in a while.
+type AbandonedCart struct {
+	Email string `json:"email"`
+	// LastUpdatedAt is when the cart was last changed.
+	LastUpdatedAt string `json:"lastUpdatedAt"`
+	UserID        int    `json:"userId"`
+}
+
+type CartReminderStats struct {
+	Sent      int `json:"sent"`
+	Converted int `json:"converted"`
+	// Revenue is the total amount of the orders placed after a reminder.
+	Revenue int `json:"revenue"`
+}
+
+// GetAbandonedCarts returns up to 'limit' carts that haven't been touched for 'idleFor', whose owners want reminders and haven't been reminded of the cart yet, nor of any cart within 'cooldown'.
+func (r *Repo) GetAbandonedCarts(ctx context.Context, idleFor time.Duration, cooldown time.Duration, limit int) ([]AbandonedCart, error) {
+	carts := make([]AbandonedCart, 0)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, u.email, MAX(ci.updated_at)
+		FROM cart_items ci
+		JOIN users u ON u.id = ci.user_id
+		WHERE u.wants_cart_reminders AND u.account_status = 'active'
+		GROUP BY u.id, u.email
+		HAVING MAX(ci.updated_at) < current_timestamp - $1::BIGINT * INTERVAL '1 second'
+		AND NOT EXISTS (
+			SELECT 1 FROM cart_reminders cr
+			WHERE cr.user_id = u.id
+			AND (cr.created_at > MAX(ci.updated_at) OR cr.created_at > current_timestamp - $2::BIGINT * INTERVAL '1 second')
+		)
+		ORDER BY u.id
+		LIMIT $3;`, int(idleFor.Seconds()), int(cooldown.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cart AbandonedCart
+		if err = rows.Scan(&cart.UserID, &cart.Email, &cart.LastUpdatedAt); err != nil {
+			return nil, err
+		}
+		carts = append(carts, cart)
+	}
+	return carts, nil
+}
+
+func (r *Repo) CreateCartReminder(ctx context.Context, userID int, itemCount int) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO cart_reminders(user_id, item_count) VALUES($1, $2);`, userID, itemCount)
+	return err
+}
+
+func (r *Repo) SetWantsCartReminders(ctx context.Context, userID int, wantsCartReminders bool) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET wants_cart_reminders=$1 WHERE id=$2;`, wantsCartReminders, userID)
+	return err
+}
+
+// convertCartReminder credits an order to the latest reminder the user got, if it was sent recently and hasn't led to an order yet.
+func convertCartReminder(ctx context.Context, q querier, userID int, orderID int) error {
+	_, err := q.ExecContext(ctx, `
+		UPDATE cart_reminders SET converted_order_id = $1, converted_at = current_timestamp
+		WHERE id = (
+			SELECT id FROM cart_reminders
+			WHERE user_id = $2 AND created_at > current_timestamp - `+cartReminderAttributionWindow+`
+			ORDER BY id DESC
+			LIMIT 1
+		) AND converted_order_id IS NULL;`, orderID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to convert cart reminder: %w", err)
+	}
+	return nil
+}
+
+// GetCartReminderStats returns how many reminders were sent since the given time and how many of them led to an order.
+func (r *Repo) GetCartReminderStats(ctx context.Context, since time.Time) (*CartReminderStats, error) {
+	var stats CartReminderStats
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(cr.converted_order_id), COALESCE(SUM(o.total_amount), 0)
+		FROM cart_reminders cr
+		LEFT JOIN orders o ON o.id = cr.converted_order_id
+		WHERE cr.created_at >= $1;`, since).Scan(&stats.Sent, &stats.Converted, &stats.Revenue)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -2,38 +2,113 @@ package repo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rohitxdev/go-api-starter/pricing"
 )
 
 var (
-	ErrCouponNotFound = errors.New("coupon not found")
+	ErrCouponNotFound          = errors.New("coupon not found")
+	ErrCouponAlreadyExists     = errors.New("coupon already exists")
+	ErrCouponNotActive         = errors.New("coupon is not active yet")
+	ErrCouponExpired           = errors.New("coupon expired")
+	ErrCouponUsageLimitReached = errors.New("coupon usage limit reached")
+	ErrCouponMinimumNotMet     = errors.New("order amount is below the coupon minimum")
+)
+
+// Kinds of coupon discounts.
+const (
+	CouponPercent = "percent"
+	CouponFixed   = "fixed"
 )
 
 type Coupon struct {
-	ID              int    `json:"id"`
-	UserID          int    `json:"userId"`
-	Code            string `json:"code"`
-	DiscountPercent int    `json:"discountPercent"`
-	IsUsed          bool   `json:"isUsed"`
-	CreatedAt       string `json:"createdAt"`
-	UpdatedAt       string `json:"updatedAt"`
+	Code string `json:"code"`
+	// DiscountType is either CouponPercent or CouponFixed.
+	DiscountType string  `json:"discountType"`
+	StartsAt     *string `json:"startsAt"`
+	ExpiresAt    *string `json:"expiresAt"`
+	// UserID is the only user who can redeem the coupon. It is nil for coupons anyone can redeem.
+	UserID *int `json:"userId"`
+	// MaxDiscount caps the discount of percent coupons.
+	MaxDiscount *int `json:"maxDiscount"`
+	// MaxRedemptions is how many times the coupon can be redeemed in total. nil means no limit.
+	MaxRedemptions *int   `json:"maxRedemptions"`
+	CreatedAt      string `json:"createdAt"`
+	UpdatedAt      string `json:"updatedAt"`
+	ID             int    `json:"id"`
+	// DiscountValue is a percentage for percent coupons, and an amount in the smallest unit of the currency for fixed ones.
+	DiscountValue         int `json:"discountValue"`
+	MinOrderAmount        int `json:"minOrderAmount"`
+	MaxRedemptionsPerUser int `json:"maxRedemptionsPerUser"`
+	RedemptionCount       int `json:"redemptionCount"`
+}
+
+// Apply sets the discount of the coupon on the pricing options.
+func (c *Coupon) Apply(opts *pricing.Options) {
+	switch c.DiscountType {
+	case CouponPercent:
+		opts.DiscountPercent = c.DiscountValue
+	case CouponFixed:
+		opts.DiscountAmount = c.DiscountValue
+	}
+	if c.MaxDiscount != nil {
+		opts.MaxDiscount = *c.MaxDiscount
+	}
+}
+
+const couponColumns = `c.id, c.code, c.user_id, c.discount_type, c.discount_value, c.max_discount, c.min_order_amount, c.starts_at, c.expires_at, c.max_redemptions, c.max_redemptions_per_user, c.redemption_count, c.created_at, c.updated_at`
+
+func scanCoupon(row interface{ Scan(...any) error }, c *Coupon) error {
+	return row.Scan(&c.ID, &c.Code, &c.UserID, &c.DiscountType, &c.DiscountValue, &c.MaxDiscount, &c.MinOrderAmount, &c.StartsAt, &c.ExpiresAt, &c.MaxRedemptions, &c.MaxRedemptionsPerUser, &c.RedemptionCount, &c.CreatedAt, &c.UpdatedAt)
+}
+
+type CreateCouponParams struct {
+	StartsAt  *time.Time
+	ExpiresAt *time.Time
+	// UserID restricts the coupon to a single user. Leave nil for a coupon anyone can redeem.
+	UserID         *int
+	MaxDiscount    *int
+	MaxRedemptions *int
+	Code           string
+	DiscountType   string
+	DiscountValue  int
+	MinOrderAmount int
+	// MaxRedemptionsPerUser defaults to 1.
+	MaxRedemptionsPerUser int
+}
+
+func (r *Repo) CreateCoupon(ctx context.Context, p *CreateCouponParams) (*Coupon, error) {
+	return createCoupon(ctx, r.db, p)
 }
 
-func (r *Repo) CreateCoupon(ctx context.Context, userID int, code string, discountPercent int) (*Coupon, error) {
+func createCoupon(ctx context.Context, q querier, p *CreateCouponParams) (*Coupon, error) {
+	if p.MaxRedemptionsPerUser == 0 {
+		p.MaxRedemptionsPerUser = 1
+	}
 	var coupon Coupon
-	err := r.db.QueryRowContext(ctx, `INSERT INTO coupons(user_id, code, discount_percent) 
-        VALUES($1, $2, $3) 
-        RETURNING id, user_id, code, discount_percent, created_at, updated_at;`,
-		userID, code, discountPercent).Scan(&coupon.ID, &coupon.UserID, &coupon.Code, &coupon.DiscountPercent, &coupon.CreatedAt, &coupon.UpdatedAt)
+	err := scanCoupon(q.QueryRowContext(ctx, `
+		INSERT INTO coupons AS c(code, user_id, discount_type, discount_value, max_discount, min_order_amount, starts_at, expires_at, max_redemptions, max_redemptions_per_user)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING `+couponColumns+`;`,
+		p.Code, p.UserID, p.DiscountType, p.DiscountValue, p.MaxDiscount, p.MinOrderAmount, p.StartsAt, p.ExpiresAt, p.MaxRedemptions, p.MaxRedemptionsPerUser,
+	), &coupon)
 	if err != nil {
-		return nil, err
+		if err == sql.ErrNoRows {
+			return nil, ErrCouponAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create coupon: %w", err)
 	}
 	return &coupon, nil
 }
 
 func (r *Repo) GetAllCoupons(ctx context.Context, page int, pageSize int) ([]Coupon, error) {
 	coupons := make([]Coupon, 0)
-	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, code, discount_percent, is_used, created_at, updated_at FROM coupons ORDER BY created_at DESC LIMIT $1 OFFSET $2;`, pageSize, page*pageSize)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+couponColumns+` FROM coupons c ORDER BY c.created_at DESC LIMIT $1 OFFSET $2;`, pageSize, page*pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -41,8 +116,7 @@ func (r *Repo) GetAllCoupons(ctx context.Context, page int, pageSize int) ([]Cou
 
 	for rows.Next() {
 		var coupon Coupon
-		err = rows.Scan(&coupon.ID, &coupon.UserID, &coupon.Code, &coupon.DiscountPercent, &coupon.IsUsed, &coupon.CreatedAt, &coupon.UpdatedAt)
-		if err != nil {
+		if err = scanCoupon(rows, &coupon); err != nil {
 			return nil, err
 		}
 		coupons = append(coupons, coupon)
@@ -50,9 +124,18 @@ func (r *Repo) GetAllCoupons(ctx context.Context, page int, pageSize int) ([]Cou
 	return coupons, nil
 }
 
+// GetAvailableCoupons returns the coupons the user can redeem right now, leaving minimum order amounts aside.
 func (r *Repo) GetAvailableCoupons(ctx context.Context, userID int) ([]Coupon, error) {
 	coupons := make([]Coupon, 0)
-	rows, err := r.db.QueryContext(ctx, `SELECT id, code, discount_percent, is_used, created_at, updated_at FROM coupons WHERE user_id=$1 AND is_used=FALSE;`, userID)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+couponColumns+`
+		FROM coupons c
+		WHERE (c.user_id IS NULL OR c.user_id = $1)
+		AND (c.starts_at IS NULL OR c.starts_at <= current_timestamp)
+		AND (c.expires_at IS NULL OR c.expires_at > current_timestamp)
+		AND (c.max_redemptions IS NULL OR c.redemption_count < c.max_redemptions)
+		AND (SELECT COUNT(*) FROM coupon_redemptions cr WHERE cr.coupon_id = c.id AND cr.user_id = $1) < c.max_redemptions_per_user
+		ORDER BY c.created_at DESC;`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -60,12 +143,73 @@ func (r *Repo) GetAvailableCoupons(ctx context.Context, userID int) ([]Coupon, e
 
 	for rows.Next() {
 		var coupon Coupon
-		err = rows.Scan(&coupon.ID, &coupon.Code, &coupon.DiscountPercent, &coupon.IsUsed, &coupon.CreatedAt, &coupon.UpdatedAt)
-		if err != nil {
+		if err = scanCoupon(rows, &coupon); err != nil {
 			return nil, err
 		}
-		coupon.UserID = userID
 		coupons = append(coupons, coupon)
 	}
 	return coupons, nil
 }
+
+// GetRedeemableCoupon returns the coupon with the given code if the user can redeem it on an order with the given subtotal.
+func (r *Repo) GetRedeemableCoupon(ctx context.Context, code string, userID int, subtotal int) (*Coupon, error) {
+	return getRedeemableCoupon(ctx, r.db, code, userID, subtotal, false)
+}
+
+// getRedeemableCoupon checks every rule of the coupon for the user. With lock set, the coupon is locked so that concurrent orders can't go over its limits.
+func getRedeemableCoupon(ctx context.Context, q querier, code string, userID int, subtotal int, lock bool) (*Coupon, error) {
+	query := `SELECT ` + couponColumns + `,
+			c.starts_at IS NULL OR c.starts_at <= current_timestamp,
+			c.expires_at IS NULL OR c.expires_at > current_timestamp,
+			(SELECT COUNT(*) FROM coupon_redemptions cr WHERE cr.coupon_id = c.id AND cr.user_id = $2)
+		FROM coupons c
+		WHERE c.code = $1`
+	if lock {
+		query += ` FOR UPDATE OF c`
+	}
+
+	var coupon Coupon
+	var isStarted, isUnexpired bool
+	var userRedemptions int
+	err := q.QueryRowContext(ctx, query, code, userID).Scan(
+		&coupon.ID, &coupon.Code, &coupon.UserID, &coupon.DiscountType, &coupon.DiscountValue, &coupon.MaxDiscount, &coupon.MinOrderAmount, &coupon.StartsAt, &coupon.ExpiresAt, &coupon.MaxRedemptions, &coupon.MaxRedemptionsPerUser, &coupon.RedemptionCount, &coupon.CreatedAt, &coupon.UpdatedAt,
+		&isStarted, &isUnexpired, &userRedemptions,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCouponNotFound
+		}
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	switch {
+	// Someone else's coupon is as good as a missing one.
+	case coupon.UserID != nil && *coupon.UserID != userID:
+		return nil, ErrCouponNotFound
+	case !isStarted:
+		return nil, ErrCouponNotActive
+	case !isUnexpired:
+		return nil, ErrCouponExpired
+	case coupon.MaxRedemptions != nil && coupon.RedemptionCount >= *coupon.MaxRedemptions,
+		userRedemptions >= coupon.MaxRedemptionsPerUser:
+		return nil, ErrCouponUsageLimitReached
+	case subtotal < coupon.MinOrderAmount:
+		return nil, ErrCouponMinimumNotMet
+	}
+	return &coupon, nil
+}
+
+// redeemCoupon records that the coupon was used on an order.
+func redeemCoupon(ctx context.Context, q querier, couponID int, userID int, orderID int, discountAmount int) error {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO coupon_redemptions(coupon_id, user_id, order_id, discount_amount) VALUES($1, $2, $3, $4);`,
+		couponID, userID, orderID, discountAmount,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to redeem coupon: %w", err)
+	}
+	if _, err = q.ExecContext(ctx, `UPDATE coupons SET redemption_count = redemption_count + 1 WHERE id = $1;`, couponID); err != nil {
+		return fmt.Errorf("failed to update coupon: %w", err)
+	}
+	return nil
+}
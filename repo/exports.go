@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ExportedOrder is an order with its items and customer, as finance needs it.
+type ExportedOrder struct {
+	Order
+	UserEmail string              `json:"userEmail"`
+	Items     []ExportedOrderItem `json:"items"`
+}
+
+type ExportedOrderItem struct {
+	OrderItem
+	ProductName string `json:"productName"`
+}
+
+// ExportOrders calls fn with every order placed from 'from' until 'to', oldest first. Either may be nil for no limit. Orders are read from the database one at a time, so exports of any size take little memory.
+func (r *Repo) ExportOrders(ctx context.Context, from *time.Time, to *time.Time, fn func(*ExportedOrder) error) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT o.id, o.user_id, u.email, o.status, o.total_amount, o.promotion_discount, o.discounted_amount, o.tax_amount, o.shipping_amount,
+			o.points_redeemed, o.points_discount, o.gift_card_amount, o.store_credit_amount, o.refunded_amount,
+			COALESCE(o.coupon_id, 0), COALESCE(c.code, ''), o.created_at, o.updated_at,
+			oi.id, oi.product_id, COALESCE(p.name, ''), oi.quantity, oi.price, oi.discount, oi.refunded_quantity, oi.created_at, oi.updated_at
+		FROM orders o
+		JOIN users u ON u.id = o.user_id
+		JOIN order_items oi ON oi.order_id = o.id
+		LEFT JOIN coupons c ON c.id = o.coupon_id
+		LEFT JOIN products p ON p.id = oi.product_id
+		WHERE ($1::timestamptz IS NULL OR o.created_at >= $1) AND ($2::timestamptz IS NULL OR o.created_at < $2)
+		ORDER BY o.id, oi.id;`,
+		from, to,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to export orders: %w", err)
+	}
+	defer rows.Close()
+
+	// Rows come one per item, so an order is complete once the next one starts
+	var order *ExportedOrder
+	for rows.Next() {
+		var o ExportedOrder
+		var item ExportedOrderItem
+		err = rows.Scan(
+			&o.ID, &o.UserID, &o.UserEmail, &o.Status, &o.TotalAmount, &o.PromotionDiscount, &o.DiscountedAmount, &o.TaxAmount, &o.ShippingAmount,
+			&o.PointsRedeemed, &o.PointsDiscount, &o.GiftCardAmount, &o.StoreCreditAmount, &o.RefundedAmount,
+			&o.CouponID, &o.CouponCode, &o.CreatedAt, &o.UpdatedAt,
+			&item.ID, &item.ProductID, &item.ProductName, &item.Quantity, &item.Price, &item.Discount, &item.RefundedQuantity, &item.CreatedAt, &item.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		item.OrderID = o.ID
+		if order == nil || order.ID != o.ID {
+			if order != nil {
+				if err = fn(order); err != nil {
+					return err
+				}
+			}
+			order = &o
+		}
+		order.Items = append(order.Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if order != nil {
+		return fn(order)
+	}
+	return nil
+}
+
+// ExportedCoupon is a coupon with the total discount it has given.
+type ExportedCoupon struct {
+	Coupon
+	TotalDiscount int `json:"totalDiscount"`
+}
+
+// ExportCoupons calls fn with every coupon created from 'from' until 'to', oldest first. Either may be nil for no limit.
+func (r *Repo) ExportCoupons(ctx context.Context, from *time.Time, to *time.Time, fn func(*ExportedCoupon) error) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+couponColumns+`, COALESCE((SELECT SUM(cr.discount_amount) FROM coupon_redemptions cr WHERE cr.coupon_id = c.id), 0)
+		FROM coupons c
+		WHERE ($1::timestamptz IS NULL OR c.created_at >= $1) AND ($2::timestamptz IS NULL OR c.created_at < $2)
+		ORDER BY c.id;`,
+		from, to,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to export coupons: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var coupon ExportedCoupon
+		if err = rows.Scan(&coupon.ID, &coupon.Code, &coupon.UserID, &coupon.DiscountType, &coupon.DiscountValue, &coupon.MaxDiscount, &coupon.MinOrderAmount, &coupon.StartsAt, &coupon.ExpiresAt, &coupon.MaxRedemptions, &coupon.MaxRedemptionsPerUser, &coupon.RedemptionCount, &coupon.CreatedAt, &coupon.UpdatedAt, &coupon.TotalDiscount); err != nil {
+			return err
+		}
+		if err = fn(&coupon); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,343 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrGiftCardNotFound      = errors.New("gift card not found")
+	ErrGiftCardAlreadyExists = errors.New("gift card already exists")
+	ErrGiftCardNotActive     = errors.New("gift card is not active")
+	ErrGiftCardExpired       = errors.New("gift card expired")
+	ErrGiftCardEmpty         = errors.New("gift card has no balance left")
+)
+
+// Kinds of gift card transactions.
+const (
+	GiftCardIssue      = "issue"
+	GiftCardRedeem     = "redeem"
+	GiftCardDeactivate = "deactivate"
+)
+
+type GiftCard struct {
+	// PurchasedBy and OrderID are set for gift cards that were bought, and IssuedBy for the ones issued by an admin.
+	PurchasedBy    *int    `json:"purchasedBy,omitempty"`
+	OrderID        *int    `json:"orderId,omitempty"`
+	IssuedBy       *int    `json:"issuedBy,omitempty"`
+	ExpiresAt      *string `json:"expiresAt"`
+	Code           string  `json:"code"`
+	CreatedAt      string  `json:"createdAt"`
+	UpdatedAt      string  `json:"updatedAt"`
+	ID             int     `json:"id"`
+	InitialBalance int     `json:"initialBalance"`
+	Balance        int     `json:"balance"`
+	IsActive       bool    `json:"isActive"`
+}
+
+// GiftCardTransaction is an entry in the audit log of a gift card. Amount is positive when the balance goes up.
+type GiftCardTransaction struct {
+	OrderID      *int   `json:"orderId"`
+	CreatedBy    *int   `json:"createdBy"`
+	Kind         string `json:"kind"`
+	Note         string `json:"note"`
+	CreatedAt    string `json:"createdAt"`
+	ID           int    `json:"id"`
+	GiftCardID   int    `json:"giftCardId"`
+	Amount       int    `json:"amount"`
+	BalanceAfter int    `json:"balanceAfter"`
+}
+
+const giftCardColumns = `id, code, initial_balance, balance, purchased_by, order_id, issued_by, expires_at, is_active, created_at, updated_at`
+
+func scanGiftCard(row interface{ Scan(...any) error }, g *GiftCard) error {
+	return row.Scan(&g.ID, &g.Code, &g.InitialBalance, &g.Balance, &g.PurchasedBy, &g.OrderID, &g.IssuedBy, &g.ExpiresAt, &g.IsActive, &g.CreatedAt, &g.UpdatedAt)
+}
+
+const giftCardCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newGiftCardCode returns a random code like ABCD-EFGH-JKLM-NPQR. Letters and digits that are easily mistaken for one another are left out.
+func newGiftCardCode() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	var code strings.Builder
+	for i, b := range buf {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(giftCardCodeAlphabet[int(b)%len(giftCardCodeAlphabet)])
+	}
+	return code.String()
+}
+
+type CreateGiftCardParams struct {
+	ExpiresAt   *time.Time
+	PurchasedBy *int
+	OrderID     *int
+	// OrderItemID is the order item the gift card was bought as.
+	OrderItemID *int
+	IssuedBy    *int
+	// Code is generated if left empty.
+	Code    string
+	Note    string
+	Balance int
+}
+
+func (r *Repo) CreateGiftCard(ctx context.Context, p *CreateGiftCardParams) (giftCard *GiftCard, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return createGiftCard(ctx, tx, p)
+}
+
+func createGiftCard(ctx context.Context, q querier, p *CreateGiftCardParams) (*GiftCard, error) {
+	code := p.Code
+	if code == "" {
+		code = newGiftCardCode()
+	}
+	var giftCard GiftCard
+	err := scanGiftCard(q.QueryRowContext(ctx, `
+		INSERT INTO gift_cards(code, initial_balance, balance, purchased_by, order_id, order_item_id, issued_by, expires_at)
+		VALUES($1, $2, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING `+giftCardColumns+`;`,
+		code, p.Balance, p.PurchasedBy, p.OrderID, p.OrderItemID, p.IssuedBy, p.ExpiresAt,
+	), &giftCard)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrGiftCardAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create gift card: %w", err)
+	}
+
+	createdBy := p.IssuedBy
+	if createdBy == nil {
+		createdBy = p.PurchasedBy
+	}
+	if err = recordGiftCardTransaction(ctx, q, &GiftCardTransaction{
+		GiftCardID:   giftCard.ID,
+		Kind:         GiftCardIssue,
+		Amount:       giftCard.Balance,
+		BalanceAfter: giftCard.Balance,
+		OrderID:      p.OrderID,
+		CreatedBy:    createdBy,
+		Note:         p.Note,
+	}); err != nil {
+		return nil, err
+	}
+	return &giftCard, nil
+}
+
+func recordGiftCardTransaction(ctx context.Context, q querier, t *GiftCardTransaction) error {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO gift_card_transactions(gift_card_id, kind, amount, balance_after, order_id, created_by, note) VALUES($1, $2, $3, $4, $5, $6, $7);`,
+		t.GiftCardID, t.Kind, t.Amount, t.BalanceAfter, t.OrderID, t.CreatedBy, t.Note,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record gift card transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) GetGiftCards(ctx context.Context, page int, pageSize int) ([]GiftCard, error) {
+	giftCards := make([]GiftCard, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+giftCardColumns+` FROM gift_cards ORDER BY id DESC LIMIT $1 OFFSET $2;`, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var giftCard GiftCard
+		if err = scanGiftCard(rows, &giftCard); err != nil {
+			return nil, err
+		}
+		giftCards = append(giftCards, giftCard)
+	}
+	return giftCards, rows.Err()
+}
+
+func (r *Repo) GetGiftCard(ctx context.Context, id int) (*GiftCard, error) {
+	return r.getGiftCard(ctx, `id=$1`, id)
+}
+
+func (r *Repo) GetGiftCardByCode(ctx context.Context, code string) (*GiftCard, error) {
+	return r.getGiftCard(ctx, `code=$1`, code)
+}
+
+func (r *Repo) getGiftCard(ctx context.Context, condition string, args ...any) (*GiftCard, error) {
+	var giftCard GiftCard
+	err := scanGiftCard(r.db.QueryRowContext(ctx, `SELECT `+giftCardColumns+` FROM gift_cards WHERE `+condition+` LIMIT 1;`, args...), &giftCard)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrGiftCardNotFound
+		}
+		return nil, err
+	}
+	return &giftCard, nil
+}
+
+// GetGiftCardTransactions returns the audit log of the gift card, oldest first.
+func (r *Repo) GetGiftCardTransactions(ctx context.Context, giftCardID int) ([]GiftCardTransaction, error) {
+	transactions := make([]GiftCardTransaction, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, gift_card_id, kind, amount, balance_after, order_id, created_by, note, created_at FROM gift_card_transactions WHERE gift_card_id=$1 ORDER BY id;`, giftCardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t GiftCardTransaction
+		if err = rows.Scan(&t.ID, &t.GiftCardID, &t.Kind, &t.Amount, &t.BalanceAfter, &t.OrderID, &t.CreatedBy, &t.Note, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// DeactivateGiftCard stops the gift card from being redeemed and writes off what is left on it.
+func (r *Repo) DeactivateGiftCard(ctx context.Context, id int, deactivatedBy int, note string) (giftCard *GiftCard, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var balance int
+	var isActive bool
+	err = tx.QueryRowContext(ctx, `SELECT balance, is_active FROM gift_cards WHERE id=$1 FOR UPDATE;`, id).Scan(&balance, &isActive)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrGiftCardNotFound
+		}
+		return nil, fmt.Errorf("failed to get gift card: %w", err)
+	}
+	if !isActive {
+		return nil, ErrGiftCardNotActive
+	}
+
+	giftCard = &GiftCard{}
+	err = scanGiftCard(tx.QueryRowContext(ctx, `UPDATE gift_cards SET is_active=FALSE, balance=0 WHERE id=$1 RETURNING `+giftCardColumns+`;`, id), giftCard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate gift card: %w", err)
+	}
+	if err = recordGiftCardTransaction(ctx, tx, &GiftCardTransaction{
+		GiftCardID: id,
+		Kind:       GiftCardDeactivate,
+		Amount:     -balance,
+		CreatedBy:  &deactivatedBy,
+		Note:       note,
+	}); err != nil {
+		return nil, err
+	}
+	return giftCard, nil
+}
+
+// refundGiftCards deactivates up to 'quantity' of the active gift cards bought as an order item, those with the most balance left first, and returns what was left on them. Only that is refunded, as what was spent can't be taken back.
+func refundGiftCards(ctx context.Context, q querier, orderItemID int, quantity int, refundedBy int, note string) (int, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, balance FROM gift_cards WHERE order_item_id=$1 AND is_active ORDER BY balance DESC, id LIMIT $2 FOR UPDATE;`,
+		orderItemID, quantity,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get gift cards: %w", err)
+	}
+	balances := make(map[int]int)
+	ids := make([]int, 0, quantity)
+	for rows.Next() {
+		var id, balance int
+		if err = rows.Scan(&id, &balance); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		balances[id] = balance
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, id := range ids {
+		if _, err = q.ExecContext(ctx, `UPDATE gift_cards SET is_active=FALSE, balance=0 WHERE id=$1;`, id); err != nil {
+			return 0, fmt.Errorf("failed to deactivate gift card: %w", err)
+		}
+		if err = recordGiftCardTransaction(ctx, q, &GiftCardTransaction{
+			GiftCardID: id,
+			Kind:       GiftCardDeactivate,
+			Amount:     -balances[id],
+			CreatedBy:  &refundedBy,
+			Note:       note,
+		}); err != nil {
+			return 0, err
+		}
+		total += balances[id]
+	}
+	return total, nil
+}
+
+// lockGiftCard returns the gift card with the given code, locked for redemption. The card must be active, unexpired and have some balance left.
+func lockGiftCard(ctx context.Context, q querier, code string) (*GiftCard, error) {
+	var giftCard GiftCard
+	var isExpired bool
+	err := q.QueryRowContext(ctx,
+		`SELECT `+giftCardColumns+`, COALESCE(expires_at <= current_timestamp, FALSE) FROM gift_cards WHERE code=$1 FOR UPDATE;`,
+		code,
+	).Scan(&giftCard.ID, &giftCard.Code, &giftCard.InitialBalance, &giftCard.Balance, &giftCard.PurchasedBy, &giftCard.OrderID, &giftCard.IssuedBy, &giftCard.ExpiresAt, &giftCard.IsActive, &giftCard.CreatedAt, &giftCard.UpdatedAt, &isExpired)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrGiftCardNotFound
+		}
+		return nil, fmt.Errorf("failed to get gift card: %w", err)
+	}
+	switch {
+	case !giftCard.IsActive:
+		return nil, ErrGiftCardNotActive
+	case isExpired:
+		return nil, ErrGiftCardExpired
+	case giftCard.Balance == 0:
+		return nil, ErrGiftCardEmpty
+	}
+	return &giftCard, nil
+}
+
+// redeemGiftCard takes the amount off a gift card locked with lockGiftCard.
+func redeemGiftCard(ctx context.Context, q querier, giftCard *GiftCard, userID int, orderID int, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	giftCard.Balance -= amount
+	if _, err := q.ExecContext(ctx, `UPDATE gift_cards SET balance=$1 WHERE id=$2;`, giftCard.Balance, giftCard.ID); err != nil {
+		return fmt.Errorf("failed to redeem gift card: %w", err)
+	}
+	return recordGiftCardTransaction(ctx, q, &GiftCardTransaction{
+		GiftCardID:   giftCard.ID,
+		Kind:         GiftCardRedeem,
+		Amount:       -amount,
+		BalanceAfter: giftCard.Balance,
+		OrderID:      &orderID,
+		CreatedBy:    &userID,
+	})
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with idempotency key is in progress")
+)
+
+// StoredResponse is the response to the first request with an idempotency key, replayed to its retries.
+type StoredResponse struct {
+	ContentType string
+	Body        []byte
+	StatusCode  int
+}
+
+// ClaimIdempotencyKey claims the key for a request, until the given time. It returns nil if the request is the first with the key, and should be handled. Otherwise it returns the stored response of the first request, ErrIdempotencyKeyInProgress if that request hasn't finished yet, or ErrIdempotencyKeyMismatch if the key was used for a request with another fingerprint.
+func (r *Repo) ClaimIdempotencyKey(ctx context.Context, scope string, key string, fingerprint string, expiresAt time.Time) (*StoredResponse, error) {
+	// An expired key is claimed again as if it was new
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys(scope, key, fingerprint, expires_at) VALUES($1, $2, $3, $4)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, content_type = '', response_body = NULL, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= current_timestamp
+		RETURNING id;`,
+		scope, key, fingerprint, expiresAt,
+	).Scan(&id)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	var storedFingerprint string
+	var statusCode *int
+	var response StoredResponse
+	err = r.db.QueryRowContext(ctx,
+		`SELECT fingerprint, status_code, content_type, response_body FROM idempotency_keys WHERE scope = $1 AND key = $2;`,
+		scope, key,
+	).Scan(&storedFingerprint, &statusCode, &response.ContentType, &response.Body)
+	if err != nil {
+		// The first request failed and released the key in the meantime
+		if err == sql.ErrNoRows {
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	switch {
+	case storedFingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyMismatch
+	case statusCode == nil:
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	response.StatusCode = *statusCode
+	return &response, nil
+}
+
+// CompleteIdempotencyKey stores the response to the request that claimed the key.
+func (r *Repo) CompleteIdempotencyKey(ctx context.Context, scope string, key string, response *StoredResponse) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5 WHERE scope = $1 AND key = $2;`,
+		scope, key, response.StatusCode, response.ContentType, response.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey gives up the claim on a key whose request failed, so that it can be retried.
+func (r *Repo) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL;`, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes the keys that can no longer be replayed, and returns how many were deleted.
+func (r *Repo) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= current_timestamp;`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
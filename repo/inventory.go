@@ -0,0 +1,268 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Kinds of inventory movements.
+const (
+	MovementInitial     = "initial"
+	MovementSale        = "sale"
+	MovementRestock     = "restock"
+	MovementAdjustment  = "adjustment"
+	MovementRefund      = "refund"
+	MovementReservation = "reservation"
+	MovementRelease     = "release"
+	MovementTransfer    = "transfer"
+)
+
+// stockMovementKinds are the kinds of movements that change the stock of a product in a warehouse. Reservations only hold stock, so they are left out. Transfers add up to nothing across warehouses.
+const stockMovementKinds = `('initial', 'sale', 'restock', 'adjustment', 'refund', 'transfer')`
+
+type InventoryMovement struct {
+	Kind      string `json:"kind"`
+	Reason    string `json:"reason"`
+	CreatedAt string `json:"createdAt"`
+	// QuantityAfter is the stock left after the movement across all warehouses. It is nil for reservations, as they don't change the stock.
+	QuantityAfter *int `json:"quantityAfter"`
+	// WarehouseID is the warehouse whose stock changed. It is nil for reservations, as they hold stock of the product as a whole.
+	WarehouseID *int `json:"warehouseId"`
+	ActorID     *int `json:"actorId"`
+	OrderID     *int `json:"orderId"`
+	ID          int  `json:"id"`
+	ProductID   int  `json:"productId"`
+	// Quantity is the signed change in stock.
+	Quantity int `json:"quantity"`
+}
+
+type StockReconciliation struct {
+	ProductName  string `json:"productName"`
+	ProductID    int    `json:"productId"`
+	QuantityLeft int    `json:"quantityLeft"`
+	// LedgerQuantity is the stock as recomputed from the inventory ledger.
+	LedgerQuantity int `json:"ledgerQuantity"`
+	// WarehouseQuantity is the stock as added up from the warehouses.
+	WarehouseQuantity int  `json:"warehouseQuantity"`
+	IsConsistent      bool `json:"isConsistent"`
+}
+
+// moveStock changes the stock of a product in a warehouse, and so its stock across warehouses, and records the change in the inventory ledger. The caller should hold a lock on the product.
+func moveStock(ctx context.Context, q querier, m *InventoryMovement) error {
+	if err := changeWarehouseStock(ctx, q, *m.WarehouseID, m.ProductID, m.Quantity); err != nil {
+		return err
+	}
+
+	var quantityAfter int
+	err := q.QueryRowContext(ctx,
+		`UPDATE products SET quantity_left = quantity_left + $1 WHERE id = $2 RETURNING quantity_left`,
+		m.Quantity, m.ProductID,
+	).Scan(&quantityAfter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to update product quantity: %w", err)
+	}
+	m.QuantityAfter = &quantityAfter
+	return recordMovement(ctx, q, m)
+}
+
+// changeWarehouseStock adds the signed quantity to the stock of a product in a warehouse, without touching the stock across warehouses.
+func changeWarehouseStock(ctx context.Context, q querier, warehouseID int, productID int, quantity int) error {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO warehouse_stock(warehouse_id, product_id, quantity) VALUES($1, $2, $3)
+		 ON CONFLICT (warehouse_id, product_id) DO UPDATE SET quantity = warehouse_stock.quantity + EXCLUDED.quantity`,
+		warehouseID, productID, quantity,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update warehouse stock: %w", err)
+	}
+	return nil
+}
+
+// recordMovement appends a movement to the inventory ledger without touching the stock.
+func recordMovement(ctx context.Context, q querier, m *InventoryMovement) error {
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO inventory_movements(product_id, kind, quantity, quantity_after, warehouse_id, actor_id, order_id, reason)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id, created_at`,
+		m.ProductID, m.Kind, m.Quantity, m.QuantityAfter, m.WarehouseID, m.ActorID, m.OrderID, m.Reason,
+	).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record inventory movement: %w", err)
+	}
+	return nil
+}
+
+// releaseReservations deletes the reservations matching the condition and records their release in the inventory ledger. It returns the product of each released reservation.
+func releaseReservations(ctx context.Context, q querier, reason string, condition string, args ...any) ([]int, error) {
+	args = append(args, reason)
+	rows, err := q.QueryContext(ctx,
+		`WITH released AS (DELETE FROM stock_reservations WHERE `+condition+` RETURNING user_id, product_id, quantity)
+		 INSERT INTO inventory_movements(product_id, kind, quantity, actor_id, reason)
+		 SELECT product_id, 'release', quantity, user_id, $`+fmt.Sprint(len(args))+` FROM released
+		 RETURNING product_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release reservations: %w", err)
+	}
+	defer rows.Close()
+
+	productIDs := make([]int, 0)
+	for rows.Next() {
+		var productID int
+		if err = rows.Scan(&productID); err != nil {
+			return nil, err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	return productIDs, rows.Err()
+}
+
+type AdjustStockParams struct {
+	// Kind is either MovementRestock or MovementAdjustment.
+	Kind   string
+	Reason string
+	// WarehouseID is the warehouse whose stock changes. The default warehouse's does if it is nil.
+	WarehouseID *int
+	ProductID   int
+	// Quantity is the signed change in stock.
+	Quantity int
+	ActorID  int
+}
+
+// AdjustStock changes the stock of a product in a warehouse by hand, e.g. when new stock arrives or after a stock take. Stock can't be taken below what checkouts hold.
+func (r *Repo) AdjustStock(ctx context.Context, p *AdjustStockParams) (movement *InventoryMovement, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	warehouseID, err := getWarehouseID(ctx, tx, p.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockProduct(ctx, tx, p.ProductID); err != nil {
+		return nil, err
+	}
+	quantity, err := getWarehouseQuantity(ctx, tx, warehouseID, p.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if quantity+p.Quantity < 0 {
+		return nil, ErrInsufficientStock
+	}
+	// Customers who hold stock while checking out must still be able to buy it
+	if p.Quantity < 0 {
+		var quantityLeft, held int
+		if err = tx.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id = $1;`, p.ProductID).Scan(&quantityLeft); err != nil {
+			return nil, fmt.Errorf("failed to get product quantity: %w", err)
+		}
+		if held, err = heldByOthers(ctx, tx, p.ProductID, 0); err != nil {
+			return nil, err
+		}
+		if quantityLeft+p.Quantity < held {
+			return nil, ErrStockHeld
+		}
+	}
+
+	movement = &InventoryMovement{
+		ProductID:   p.ProductID,
+		Kind:        p.Kind,
+		Quantity:    p.Quantity,
+		WarehouseID: &warehouseID,
+		ActorID:     &p.ActorID,
+		Reason:      p.Reason,
+	}
+	if err = moveStock(ctx, tx, movement); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (r *Repo) GetInventoryMovements(ctx context.Context, productID int, page int, pageSize int) ([]InventoryMovement, error) {
+	movements := make([]InventoryMovement, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, product_id, kind, quantity, quantity_after, warehouse_id, actor_id, order_id, reason, created_at FROM inventory_movements WHERE product_id=$1 ORDER BY id DESC LIMIT $2 OFFSET $3;`, productID, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m InventoryMovement
+		err = rows.Scan(&m.ID, &m.ProductID, &m.Kind, &m.Quantity, &m.QuantityAfter, &m.WarehouseID, &m.ActorID, &m.OrderID, &m.Reason, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, nil
+}
+
+// ReconcileStock recomputes the stock of every product from the inventory ledger and from its warehouses, and compares them with the stock on record.
+func (r *Repo) ReconcileStock(ctx context.Context) ([]StockReconciliation, error) {
+	reconciliations := make([]StockReconciliation, 0)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.quantity_left, COALESCE(SUM(m.quantity) FILTER (WHERE m.kind IN `+stockMovementKinds+`), 0),
+			COALESCE((SELECT SUM(ws.quantity) FROM warehouse_stock ws WHERE ws.product_id = p.id), 0)
+		FROM products p
+		LEFT JOIN inventory_movements m ON m.product_id = p.id
+		GROUP BY p.id
+		ORDER BY p.id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec StockReconciliation
+		err = rows.Scan(&rec.ProductID, &rec.ProductName, &rec.QuantityLeft, &rec.LedgerQuantity, &rec.WarehouseQuantity)
+		if err != nil {
+			return nil, err
+		}
+		rec.IsConsistent = rec.QuantityLeft == rec.LedgerQuantity && rec.QuantityLeft == rec.WarehouseQuantity
+		reconciliations = append(reconciliations, rec)
+	}
+	return reconciliations, nil
+}
+
+func (r *Repo) SetLowStockThreshold(ctx context.Context, productID int, threshold int) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE products SET low_stock_threshold = $1 WHERE id = $2`, threshold, productID)
+	if err != nil {
+		return fmt.Errorf("failed to set low stock threshold: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// GetLowStockProducts returns the products whose stock is at or below their low stock threshold, lowest stock first. Gift cards are left out, as their stock is not meant to run low.
+func (r *Repo) GetLowStockProducts(ctx context.Context) ([]Product, error) {
+	products := make([]Product, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT p.id, p.name, p.image_url, p.price, p.quantity_left, `+quantityAvailableColumn+`, `+warehouseCountColumn+`, p.low_stock_threshold, p.is_gift_card, p.category, p.created_at, p.updated_at FROM products p WHERE p.low_stock_threshold > 0 AND p.quantity_left <= p.low_stock_threshold AND NOT p.is_gift_card ORDER BY p.quantity_left, p.id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Product
+		err = rows.Scan(&p.ID, &p.Name, &p.ImageURL, &p.Price, &p.QuantityLeft, &p.QuantityAvailable, &p.WarehouseCount, &p.LowStockThreshold, &p.IsGiftCard, &p.Category, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
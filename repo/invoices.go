@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrOrderNotInvoiceable = errors.New("order can't be invoiced")
+)
+
+type Invoice struct {
+	// FileName is the key of the PDF of the invoice in the blob store. It is nil until the PDF is stored.
+	FileName *string `json:"-"`
+	// InvoiceNumber is the number printed on the invoice, e.g. INV-2026-000042.
+	InvoiceNumber string `json:"invoiceNumber"`
+	CreatedAt     string `json:"createdAt"`
+	UpdatedAt     string `json:"updatedAt"`
+	ID            int    `json:"id"`
+	OrderID       int    `json:"orderId"`
+	Year          int    `json:"year"`
+	Number        int    `json:"number"`
+}
+
+const invoiceColumns = `id, order_id, year, number, file_name, created_at, updated_at`
+
+func scanInvoice(row interface{ Scan(...any) error }, i *Invoice) error {
+	if err := row.Scan(&i.ID, &i.OrderID, &i.Year, &i.Number, &i.FileName, &i.CreatedAt, &i.UpdatedAt); err != nil {
+		return err
+	}
+	i.InvoiceNumber = fmt.Sprintf("INV-%d-%06d", i.Year, i.Number)
+	return nil
+}
+
+// CreateInvoice numbers the invoice of the order with the next number of the current year. If the order already has an invoice, that is returned instead. Only orders that were paid for can be invoiced.
+func (r *Repo) CreateInvoice(ctx context.Context, orderID int) (invoice *Invoice, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Locking the order makes concurrent requests for its invoice wait for the first one
+	var status string
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = $1 FOR UPDATE;`, orderID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if status != "completed" && status != "partially_refunded" && status != "refunded" {
+		return nil, ErrOrderNotInvoiceable
+	}
+
+	invoice = &Invoice{}
+	err = scanInvoice(tx.QueryRowContext(ctx, `SELECT `+invoiceColumns+` FROM invoices WHERE order_id = $1;`, orderID), invoice)
+	if err == nil {
+		return invoice, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	// The sequence stays locked until the invoice is committed, so numbers are taken one after the other
+	var year, number int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO invoice_sequences(year, last_number) VALUES(EXTRACT(YEAR FROM current_timestamp), 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING year, last_number;`,
+	).Scan(&year, &number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice number: %w", err)
+	}
+	err = scanInvoice(tx.QueryRowContext(ctx,
+		`INSERT INTO invoices(order_id, year, number) VALUES($1, $2, $3) RETURNING `+invoiceColumns+`;`,
+		orderID, year, number,
+	), invoice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+	return invoice, nil
+}
+
+// SetInvoiceFile records where the PDF of the invoice is stored.
+func (r *Repo) SetInvoiceFile(ctx context.Context, id int, fileName string) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE invoices SET file_name = $1 WHERE id = $2;`, fileName, id); err != nil {
+		return fmt.Errorf("failed to update invoice: %w", err)
+	}
+	return nil
+}
@@ -5,31 +5,59 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+
+	"github.com/rohitxdev/go-api-starter/allocation"
+	"github.com/rohitxdev/go-api-starter/pricing"
 )
 
 var (
 	ErrOrderNotFound = errors.New("order not found")
-	ErrInvalidCoupon = errors.New("invalid coupon")
 )
 
 type Order struct {
-	ID               int    `json:"id"`
-	UserID           int    `json:"userId"`
-	Status           string `json:"status"`
-	TotalAmount      int    `json:"totalAmount"`
-	DiscountedAmount int    `json:"discountedAmount"`
-	CouponID         int    `json:"couponId,omitempty"`
-	CreatedAt        string `json:"createdAt"`
-	UpdatedAt        string `json:"updatedAt"`
+	ID          int    `json:"id"`
+	UserID      int    `json:"userId"`
+	Status      string `json:"status"`
+	TotalAmount int    `json:"totalAmount"`
+	// PromotionDiscount is what promotions took off the order, and DiscountedAmount what the coupon did.
+	PromotionDiscount int `json:"promotionDiscount"`
+	DiscountedAmount  int `json:"discountedAmount"`
+	TaxAmount         int `json:"taxAmount"`
+	ShippingAmount    int `json:"shippingAmount"`
+	// PointsRedeemed are the loyalty points spent on the order, and PointsDiscount what they were worth.
+	PointsRedeemed int `json:"pointsRedeemed"`
+	PointsDiscount int `json:"pointsDiscount"`
+	// GiftCardAmount and StoreCreditAmount are the parts of the total paid with a gift card and store credit. The rest is paid by the customer.
+	GiftCardAmount    int `json:"giftCardAmount"`
+	StoreCreditAmount int `json:"storeCreditAmount"`
+	// PointsEarned are the loyalty points the order earned. It is only set when the order is placed.
+	PointsEarned   int    `json:"pointsEarned,omitempty"`
+	RefundedAmount int    `json:"refundedAmount"`
+	CouponID       int    `json:"couponId,omitempty"`
+	CouponCode     string `json:"couponCode,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+	UpdatedAt      string `json:"updatedAt"`
+	// Promotions explain the promotion discount.
+	Promotions []pricing.AppliedPromotion `json:"promotions,omitempty"`
+	// Rewards are the coupons the order earned. It is only set when the order is placed.
+	Rewards []Coupon `json:"rewards,omitempty"`
+	// GiftCards are the gift cards bought with the order. It is only set when the order is placed.
+	GiftCards []GiftCard `json:"giftCards,omitempty"`
 }
 
 type OrderItem struct {
-	ID        int    `json:"id"`
-	OrderID   int    `json:"orderId"`
-	ProductID int    `json:"productId"`
-	Quantity  int    `json:"quantity"`
-	CreatedAt string `json:"createdAt"`
-	UpdatedAt string `json:"updatedAt"`
+	ID        int `json:"id"`
+	OrderID   int `json:"orderId"`
+	ProductID int `json:"productId"`
+	Quantity  int `json:"quantity"`
+	// Price is the unit price of the product at the time of purchase.
+	Price int `json:"price"`
+	// Discount is what promotions took off the item.
+	Discount         int    `json:"discount"`
+	RefundedQuantity int    `json:"refundedQuantity"`
+	CreatedAt        string `json:"createdAt"`
+	UpdatedAt        string `json:"updatedAt"`
 }
 
 func (r *Repo) GetOrders(ctx context.Context) ([]Order, error) {
@@ -53,7 +81,8 @@ func (r *Repo) GetOrders(ctx context.Context) ([]Order, error) {
 
 func (r *Repo) GetOrder(ctx context.Context, id int) (*Order, error) {
 	var order Order
-	err := r.db.QueryRowContext(ctx, `SELECT id, user_id, status, total_amount, created_at, updated_at FROM orders WHERE id=$1 LIMIT 1;`, id).Scan(&order.ID, &order.UserID, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	var couponID *int
+	err := r.db.QueryRowContext(ctx, `SELECT id, user_id, status, total_amount, promotion_discount, discounted_amount, tax_amount, shipping_amount, points_redeemed, points_discount, gift_card_amount, store_credit_amount, refunded_amount, coupon_id, COALESCE((SELECT code FROM coupons WHERE coupons.id = orders.coupon_id), ''), created_at, updated_at FROM orders WHERE id=$1 LIMIT 1;`, id).Scan(&order.ID, &order.UserID, &order.Status, &order.TotalAmount, &order.PromotionDiscount, &order.DiscountedAmount, &order.TaxAmount, &order.ShippingAmount, &order.PointsRedeemed, &order.PointsDiscount, &order.GiftCardAmount, &order.StoreCreditAmount, &order.RefundedAmount, &couponID, &order.CouponCode, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -61,20 +90,73 @@ func (r *Repo) GetOrder(ctx context.Context, id int) (*Order, error) {
 		}
 		return nil, err
 	}
-	return nil, nil
+	if couponID != nil {
+		order.CouponID = *couponID
+	}
+	if order.PromotionDiscount > 0 {
+		if order.Promotions, err = r.GetOrderPromotions(ctx, order.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &order, nil
 }
 
-func (r *Repo) CreateOrder(ctx context.Context, cart []CartItem, userID int, preTotalAmount int, validCoupon *Coupon) (*Order, error) {
-	var order Order
+func (r *Repo) GetOrderItems(ctx context.Context, orderID int) ([]OrderItem, error) {
+	orderItems := make([]OrderItem, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, product_id, quantity, price, discount, refunded_quantity, created_at, updated_at FROM order_items WHERE order_id=$1 ORDER BY id;`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderItem OrderItem
+		err = rows.Scan(&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.Quantity, &orderItem.Price, &orderItem.Discount, &orderItem.RefundedQuantity, &orderItem.CreatedAt, &orderItem.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		orderItems = append(orderItems, orderItem)
+	}
+	return orderItems, nil
+}
+
+type CreateOrderParams struct {
+	Cart         []CartItem
+	CouponCode   string
+	GiftCardCode string
+	Pricing      pricing.Options
+	Points       PointsProgram
+	UserID       int
+	// RedeemPoints is the most loyalty points to spend on the order. Fewer are spent if the order is worth less.
+	RedeemPoints int
+	// UseStoreCredit pays what the gift card, if any, doesn't cover with the store credit of the user.
+	UseStoreCredit bool
+	// Allocation picks the warehouses that the items ship from.
+	Allocation allocation.Strategy
+	// Destination is where the order ships to, for allocating it to the nearest warehouses. It is nil if not known.
+	Destination *allocation.Location
+	// FromSubscription is set for orders placed by a subscription rather than checked out by the user, which leave the cart reminder and the stock held for the user's checkout alone.
+	FromSubscription bool
+}
+
+// CreateOrder places an order for the cart. The order is priced with the prices of the products at the time of purchase, the promotions running, the coupon, points, gift card and store credit, if any, are redeemed, and the points, rewards and gift cards the order earns are issued.
+func (r *Repo) CreateOrder(ctx context.Context, p *CreateOrderParams) (order *Order, err error) {
+	if len(p.Cart) == 0 {
+		return nil, ErrCartEmpty
+	}
+	userID := p.UserID
+	opts := p.Pricing
 	var orderItems []OrderItem
 
-	for _, cartItem := range cart {
+	for _, cartItem := range p.Cart {
 		orderItem := OrderItem{
 			ProductID: cartItem.ProductID,
 			Quantity:  cartItem.Quantity,
 		}
 		orderItems = append(orderItems, orderItem)
 	}
+	// Products are locked in the order of their IDs, like everywhere else, so that concurrent checkouts can't deadlock. Pricing and order items follow the same order.
+	slices.SortFunc(orderItems, func(a, b OrderItem) int { return a.ProductID - b.ProductID })
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -88,121 +170,215 @@ func (r *Repo) CreateOrder(ctx context.Context, cart []CartItem, userID int, pre
 		}
 	}()
 
+	// Points and rewards depend on the previous orders of the user
+	if err = lockUser(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
 	// First check if all products have enough quantity
-	for _, item := range orderItems {
+	isGiftCard := make(map[int]bool)
+	category := make(map[int]string)
+	for i, item := range orderItems {
 		var quantityLeft int
+		var giftCard bool
+		var productCategory string
 		err = tx.QueryRowContext(ctx,
-			`SELECT quantity_left FROM products WHERE id = $1 FOR UPDATE`,
+			`SELECT quantity_left, price, is_gift_card, category FROM products WHERE id = $1 FOR UPDATE`,
 			item.ProductID,
-		).Scan(&quantityLeft)
-
+		).Scan(&quantityLeft, &orderItems[i].Price, &giftCard, &productCategory)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check product quantity: %w", err)
 		}
+		isGiftCard[item.ProductID] = giftCard
+		category[item.ProductID] = productCategory
+
+		// Stock held by the user's own checkout is theirs to buy, but not for a subscription, as the checkout still needs it
+		holderID := userID
+		if p.FromSubscription {
+			holderID = 0
+		}
+		var held int
+		if held, err = heldByOthers(ctx, tx, item.ProductID, holderID); err != nil {
+			return nil, err
+		}
 
-		if quantityLeft < item.Quantity {
-			return nil, fmt.Errorf("insufficient quantity for product %d", item.ProductID)
+		if quantityLeft-held < item.Quantity {
+			return nil, fmt.Errorf("%w for product %d", ErrInsufficientStock, item.ProductID)
 		}
 	}
 
-	totalAmount := preTotalAmount
-	if validCoupon != nil {
-		// Check if coupon is already used
-		var isUsed bool
-		err = tx.QueryRowContext(ctx,
-			`SELECT is_used FROM coupons WHERE id = $1 FOR UPDATE`,
-			validCoupon.ID,
-		).Scan(&isUsed)
+	lines := make([]pricing.Line, 0, len(orderItems))
+	for _, item := range orderItems {
+		lines = append(lines, pricing.Line{ProductID: item.ProductID, UnitPrice: item.Price, Quantity: item.Quantity, Category: category[item.ProductID], NoPromotions: isGiftCard[item.ProductID]})
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to check coupon: %w", err)
-		}
+	if opts.Promotions, err = getActivePromotions(ctx, tx); err != nil {
+		return nil, err
+	}
 
-		if isUsed {
-			return nil, errors.New("coupon already used")
+	var coupon *Coupon
+	var couponID *int
+	if p.CouponCode != "" {
+		subtotal := pricing.Calculate(lines, opts).Subtotal
+		if coupon, err = getRedeemableCoupon(ctx, tx, p.CouponCode, userID, subtotal, true); err != nil {
+			return nil, err
 		}
+		coupon.Apply(&opts)
+		couponID = &coupon.ID
+	}
+	summary := pricing.Calculate(lines, opts)
 
-		totalAmount -= totalAmount * validCoupon.DiscountPercent / 100
+	var pointsRedeemed int
+	if p.RedeemPoints > 0 {
+		pointsRedeemed, opts.PointsAmount = p.Points.Redeemable(p.RedeemPoints, summary.Subtotal-summary.ExemptSubtotal-summary.PromotionDiscount-summary.Discount)
+		summary = pricing.Calculate(lines, opts)
+	}
 
-		row := tx.QueryRowContext(ctx,
-			`INSERT INTO orders(user_id, status, total_amount, discounted_amount, coupon_id) 
-			 VALUES($1, $2, $3, $4, $5) 
-			 RETURNING id, user_id, status, total_amount, discounted_amount, coupon_id, created_at, updated_at`,
-			userID, "completed", totalAmount, preTotalAmount-totalAmount, validCoupon.ID)
+	// Gift cards and store credit pay for the total like money does
+	due := summary.Total
+	var giftCard *GiftCard
+	var giftCardAmount, storeCreditAmount int
+	if p.GiftCardCode != "" {
+		if giftCard, err = lockGiftCard(ctx, tx, p.GiftCardCode); err != nil {
+			return nil, err
+		}
+		giftCardAmount = min(giftCard.Balance, due)
+		due -= giftCardAmount
+	}
+	if p.UseStoreCredit {
+		var storeCredit int
+		if storeCredit, err = lockStoreCredit(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+		storeCreditAmount = min(storeCredit, due)
+	}
 
-		err = row.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Status,
-			&order.TotalAmount,
-			&order.DiscountedAmount,
-			&order.CouponID,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-		)
+	order = &Order{}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO orders(user_id, status, total_amount, promotion_discount, discounted_amount, tax_amount, shipping_amount, points_redeemed, points_discount, gift_card_amount, store_credit_amount, coupon_id) 
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) 
+		 RETURNING id, user_id, status, total_amount, promotion_discount, discounted_amount, tax_amount, shipping_amount, points_redeemed, points_discount, gift_card_amount, store_credit_amount, created_at, updated_at`,
+		userID, "completed", summary.Total, summary.PromotionDiscount, summary.Discount, summary.Tax, summary.Shipping, pointsRedeemed, summary.PointsDiscount, giftCardAmount, storeCreditAmount, couponID,
+	).Scan(
+		&order.ID,
+		&order.UserID,
+		&order.Status,
+		&order.TotalAmount,
+		&order.PromotionDiscount,
+		&order.DiscountedAmount,
+		&order.TaxAmount,
+		&order.ShippingAmount,
+		&order.PointsRedeemed,
+		&order.PointsDiscount,
+		&order.GiftCardAmount,
+		&order.StoreCreditAmount,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	if giftCard != nil {
+		if err = redeemGiftCard(ctx, tx, giftCard, userID, order.ID, giftCardAmount); err != nil {
+			return nil, err
+		}
+	}
+	if storeCreditAmount > 0 {
+		_, err = changeStoreCredit(ctx, tx, &StoreCreditTransaction{
+			UserID:    userID,
+			Kind:      StoreCreditRedeem,
+			Amount:    -storeCreditAmount,
+			OrderID:   &order.ID,
+			CreatedBy: &userID,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to create order: %w", err)
+			return nil, err
 		}
+	}
 
-		// Mark coupon as used
-		_, err = tx.ExecContext(ctx,
-			`UPDATE coupons SET is_used = TRUE WHERE id = $1`,
-			validCoupon.ID,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update coupon: %w", err)
-		}
-	} else {
-		row := tx.QueryRowContext(ctx,
-			`INSERT INTO orders(user_id, status, total_amount, discounted_amount) 
-			 VALUES($1, $2, $3, $4) 
-			 RETURNING id, user_id, status, total_amount, discounted_amount, created_at, updated_at`,
-			userID, "completed", totalAmount, preTotalAmount-totalAmount)
-
-		err = row.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Status,
-			&order.TotalAmount,
-			&order.DiscountedAmount,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create order: %w", err)
+	if err = recordPromotions(ctx, tx, order.ID, summary.Promotions); err != nil {
+		return nil, err
+	}
+	order.Promotions = summary.Promotions
+
+	if coupon != nil {
+		order.CouponID = coupon.ID
+		order.CouponCode = coupon.Code
+		if err = redeemCoupon(ctx, tx, coupon.ID, userID, order.ID, summary.Discount); err != nil {
+			return nil, err
 		}
 	}
 
 	// Insert order items
-	for _, orderItem := range orderItems {
-		_, err = tx.ExecContext(ctx,
-			`INSERT INTO order_items(order_id, product_id, quantity) 
-			 VALUES($1, $2, $3)`,
-			order.ID, orderItem.ProductID, orderItem.Quantity)
+	for i, orderItem := range orderItems {
+		orderItems[i].OrderID = order.ID
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO order_items(order_id, product_id, quantity, price, discount) 
+			 VALUES($1, $2, $3, $4, $5)
+			 RETURNING id`,
+			order.ID, orderItem.ProductID, orderItem.Quantity, orderItem.Price, summary.Lines[i].Discount).Scan(&orderItems[i].ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create order item: %w", err)
 		}
+
+		// Every gift card bought is a card of its own, worth what was paid for it
+		if isGiftCard[orderItem.ProductID] {
+			for range orderItem.Quantity {
+				var giftCard *GiftCard
+				giftCard, err = createGiftCard(ctx, tx, &CreateGiftCardParams{
+					Balance:     orderItem.Price,
+					PurchasedBy: &userID,
+					OrderID:     &order.ID,
+					OrderItemID: &orderItems[i].ID,
+				})
+				if err != nil {
+					return nil, err
+				}
+				order.GiftCards = append(order.GiftCards, *giftCard)
+			}
+		}
 	}
 
-	// Update product quantities
-	for _, item := range orderItems {
-		_, err = tx.ExecContext(ctx,
-			`UPDATE products 
-			 SET quantity_left = quantity_left - $1 
-			 WHERE id = $2`,
-			item.Quantity, item.ProductID,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update product quantity: %w", err)
+	if !p.FromSubscription {
+		if err = convertCartReminder(ctx, tx, userID, order.ID); err != nil {
+			return nil, err
 		}
 	}
 
-	return &order, nil
+	if err = redeemPoints(ctx, tx, userID, order.ID, pointsRedeemed); err != nil {
+		return nil, err
+	}
+	// Gift cards don't earn points, as they earn them when they are spent
+	order.PointsEarned = p.Points.Earned(summary.Total - summary.Tax - summary.Shipping - summary.ExemptSubtotal)
+	if err = earnPoints(ctx, tx, p.Points, userID, order.ID, order.PointsEarned); err != nil {
+		return nil, err
+	}
+
+	if order.Rewards, err = issueRewards(ctx, tx, order); err != nil {
+		return nil, err
+	}
+
+	// The stock held for checkout is about to be sold
+	if !p.FromSubscription {
+		if _, err = releaseReservations(ctx, tx, "order placed", "user_id = $1", userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Update product quantities in the warehouses the items ship from
+	for i := range orderItems {
+		if err = allocateStock(ctx, tx, &orderItems[i], userID, p.Allocation, p.Destination); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
 }
 
 func (r *Repo) GetAllOrders(ctx context.Context, page int, pageSize int) ([]Order, error) {
 	orders := make([]Order, 0)
-	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, status, total_amount, discounted_amount, coupon_id, created_at, updated_at FROM orders ORDER BY created_at DESC LIMIT $1 OFFSET $2;`, pageSize, page*pageSize)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, status, total_amount, promotion_discount, discounted_amount, tax_amount, shipping_amount, points_redeemed, points_discount, gift_card_amount, store_credit_amount, refunded_amount, coupon_id, created_at, updated_at FROM orders ORDER BY created_at DESC LIMIT $1 OFFSET $2;`, pageSize, page*pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -213,7 +389,7 @@ func (r *Repo) GetAllOrders(ctx context.Context, page int, pageSize int) ([]Orde
 	for rows.Next() {
 		var order Order
 		var couponID *int
-		err = rows.Scan(&order.ID, &order.UserID, &order.Status, &order.TotalAmount, &order.DiscountedAmount, &couponID, &order.CreatedAt, &order.UpdatedAt)
+		err = rows.Scan(&order.ID, &order.UserID, &order.Status, &order.TotalAmount, &order.PromotionDiscount, &order.DiscountedAmount, &order.TaxAmount, &order.ShippingAmount, &order.PointsRedeemed, &order.PointsDiscount, &order.GiftCardAmount, &order.StoreCreditAmount, &order.RefundedAmount, &couponID, &order.CreatedAt, &order.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,234 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInsufficientPoints = errors.New("insufficient points")
+)
+
+// Kinds of points ledger entries.
+const (
+	PointsEarn    = "earn"
+	PointsRedeem  = "redeem"
+	PointsExpire  = "expire"
+	PointsReverse = "reverse"
+)
+
+// PointsProgram is how loyalty points are earned and what they are worth.
+type PointsProgram struct {
+	// EarnRate is in basis points of what is paid for the items of an order.
+	EarnRate int
+	// PointValue is in the smallest unit of the currency.
+	PointValue   int
+	ExpiryMonths int
+}
+
+// Earned returns the points earned by paying the given amount for items.
+func (p PointsProgram) Earned(paidForItems int) int {
+	return max(paidForItems, 0) * p.EarnRate / 10000
+}
+
+// Redeemable returns how many of the given points can be redeemed against the given amount, and what they are worth. Only whole points are redeemed.
+func (p PointsProgram) Redeemable(points int, amount int) (int, int) {
+	if p.PointValue <= 0 {
+		return 0, 0
+	}
+	points = min(max(points, 0), max(amount, 0)/p.PointValue)
+	return points, points * p.PointValue
+}
+
+type PointsEntry struct {
+	OrderID   *int    `json:"orderId"`
+	ExpiresAt *string `json:"expiresAt"`
+	Kind      string  `json:"kind"`
+	CreatedAt string  `json:"createdAt"`
+	ID        int     `json:"id"`
+	// Points are positive for earn entries and negative for the rest.
+	Points int `json:"points"`
+}
+
+type PointsBalance struct {
+	// NextExpiryAt is when ExpiringPoints expire. It is nil if no points are going to expire.
+	NextExpiryAt   *string `json:"nextExpiryAt"`
+	Balance        int     `json:"balance"`
+	ExpiringPoints int     `json:"expiringPoints"`
+}
+
+// getPointsBalance returns the points of the user that can be spent. Points past their expiry don't count, even before they are expired by the job.
+func getPointsBalance(ctx context.Context, q querier, userID int) (int, error) {
+	var balance int
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(points), 0) - COALESCE(SUM(remaining) FILTER (WHERE expires_at <= current_timestamp), 0) FROM points_ledger WHERE user_id=$1;`,
+		userID,
+	).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get points balance: %w", err)
+	}
+	return balance, nil
+}
+
+// GetPointsBalance returns the points balance of the user, and the points that expire next.
+func (r *Repo) GetPointsBalance(ctx context.Context, userID int) (*PointsBalance, error) {
+	var balance PointsBalance
+	var err error
+	if balance.Balance, err = getPointsBalance(ctx, r.db, userID); err != nil {
+		return nil, err
+	}
+	err = r.db.QueryRowContext(ctx, `
+		SELECT MIN(expires_at), COALESCE(SUM(remaining) FILTER (WHERE expires_at = (SELECT MIN(expires_at) FROM points_ledger WHERE user_id=$1 AND remaining > 0 AND expires_at > current_timestamp)), 0)
+		FROM points_ledger
+		WHERE user_id=$1 AND remaining > 0 AND expires_at > current_timestamp;`, userID,
+	).Scan(&balance.NextExpiryAt, &balance.ExpiringPoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expiring points: %w", err)
+	}
+	return &balance, nil
+}
+
+// GetPointsHistory returns the points ledger of the user, newest first.
+func (r *Repo) GetPointsHistory(ctx context.Context, userID int, page int, pageSize int) ([]PointsEntry, error) {
+	entries := make([]PointsEntry, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, kind, points, expires_at, created_at FROM points_ledger WHERE user_id=$1 ORDER BY id DESC LIMIT $2 OFFSET $3;`, userID, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry PointsEntry
+		if err = rows.Scan(&entry.ID, &entry.OrderID, &entry.Kind, &entry.Points, &entry.ExpiresAt, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// earnPoints credits the points earned by an order. They expire after the months of the program.
+func earnPoints(ctx context.Context, q querier, program PointsProgram, userID int, orderID int, points int) error {
+	if points <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().AddDate(0, program.ExpiryMonths, 0)
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO points_ledger(user_id, order_id, kind, points, remaining, expires_at) VALUES($1, $2, $3, $4, $4, $5);`,
+		userID, orderID, PointsEarn, points, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to earn points: %w", err)
+	}
+	return nil
+}
+
+// redeemPoints spends points of the user on an order. The user must be locked, so that concurrent orders can't spend the same points.
+func redeemPoints(ctx context.Context, q querier, userID int, orderID int, points int) error {
+	if points <= 0 {
+		return nil
+	}
+	balance, err := getPointsBalance(ctx, q, userID)
+	if err != nil {
+		return err
+	}
+	if balance < points {
+		return ErrInsufficientPoints
+	}
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO points_ledger(user_id, order_id, kind, points) VALUES($1, $2, $3, $4);`,
+		userID, orderID, PointsRedeem, -points,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to redeem points: %w", err)
+	}
+	return consumePoints(ctx, q, userID, points)
+}
+
+// consumePoints takes points out of the unexpired earn entries of the user that expire first.
+func consumePoints(ctx context.Context, q querier, userID int, points int) error {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, remaining FROM points_ledger WHERE user_id=$1 AND remaining > 0 AND expires_at > current_timestamp ORDER BY expires_at, id FOR UPDATE;`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get earned points: %w", err)
+	}
+	type earned struct{ id, remaining int }
+	var entries []earned
+	for rows.Next() {
+		var entry earned
+		if err = rows.Scan(&entry.id, &entry.remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if points == 0 {
+			break
+		}
+		taken := min(entry.remaining, points)
+		if _, err = q.ExecContext(ctx, `UPDATE points_ledger SET remaining = remaining - $1 WHERE id=$2;`, taken, entry.id); err != nil {
+			return fmt.Errorf("failed to consume points: %w", err)
+		}
+		points -= taken
+	}
+	return nil
+}
+
+// reversePoints takes back the share of the points earned by an order that has been refunded, refundedAmount being everything refunded so far. Points that were already spent can leave the balance negative.
+func reversePoints(ctx context.Context, q querier, orderID int, refundedAmount int, paidForItems int) error {
+	var userID, earned, reversed int
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(user_id), 0),
+			COALESCE(SUM(points) FILTER (WHERE kind = 'earn'), 0),
+			COALESCE(-SUM(points) FILTER (WHERE kind = 'reverse'), 0)
+		FROM points_ledger
+		WHERE order_id=$1 AND kind IN ('earn', 'reverse');`, orderID,
+	).Scan(&userID, &earned, &reversed)
+	if err != nil {
+		return fmt.Errorf("failed to get earned points: %w", err)
+	}
+	if earned == 0 || paidForItems <= 0 {
+		return nil
+	}
+
+	points := earned*min(refundedAmount, paidForItems)/paidForItems - reversed
+	if points <= 0 {
+		return nil
+	}
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO points_ledger(user_id, order_id, kind, points) VALUES($1, $2, $3, $4);`,
+		userID, orderID, PointsReverse, -points,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reverse points: %w", err)
+	}
+	return consumePoints(ctx, q, userID, points)
+}
+
+// ExpirePoints expires the points that were earned and not spent before their expiry. It returns how many earn entries expired.
+func (r *Repo) ExpirePoints(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		WITH expired AS (
+			SELECT id, user_id, remaining FROM points_ledger
+			WHERE remaining > 0 AND expires_at <= current_timestamp
+			FOR UPDATE
+		), cleared AS (
+			UPDATE points_ledger p SET remaining = 0 FROM expired e WHERE p.id = e.id
+		)
+		INSERT INTO points_ledger(user_id, kind, points)
+		SELECT user_id, 'expire', -remaining FROM expired;`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire points: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"math"
 )
 
 var (
@@ -15,14 +16,36 @@ type Product struct {
 	Name     string `json:"name"`
 	ImageURL string `json:"imageUrl"`
 	// Price is in the smallest unit of the currency
-	Price        int    `json:"price"`
-	QuantityLeft int    `json:"quantityLeft"`
-	CreatedAt    string `json:"createdAt"`
-	UpdatedAt    string `json:"updatedAt"`
+	Price int `json:"price"`
+	// QuantityLeft is the stock across all warehouses.
+	QuantityLeft int `json:"quantityLeft"`
+	// QuantityAvailable is the quantity left minus what is held by other customers' checkouts.
+	QuantityAvailable int `json:"quantityAvailable"`
+	// WarehouseCount is how many warehouses have the product in stock.
+	WarehouseCount int `json:"warehouseCount"`
+	// LowStockThreshold is the stock at or below which admins are alerted. 0 turns alerts off.
+	LowStockThreshold int `json:"lowStockThreshold"`
+	// IsGiftCard is set for products that are bought as gift cards worth their price.
+	IsGiftCard bool   `json:"isGiftCard"`
+	Category   string `json:"category"`
+	// AverageRating is the average of the approved reviews, rounded to one decimal. It is 0 for products without any.
+	AverageRating float64 `json:"averageRating"`
+	RatingCount   int     `json:"ratingCount"`
+	CreatedAt     string  `json:"createdAt"`
+	UpdatedAt     string  `json:"updatedAt"`
+}
+
+const warehouseCountColumn = `(SELECT COUNT(*) FROM warehouse_stock ws WHERE ws.product_id = p.id AND ws.quantity > 0)`
+
+// setAverageRating works out the average rating of the product from the sum of its ratings.
+func (p *Product) setAverageRating(ratingSum int) {
+	if p.RatingCount > 0 {
+		p.AverageRating = math.Round(float64(ratingSum)/float64(p.RatingCount)*10) / 10
+	}
 }
 
 func (r *Repo) GetProducts(ctx context.Context) ([]Product, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, name, image_url, price, quantity_left, created_at, updated_at FROM products;`)
+	rows, err := r.db.QueryContext(ctx, `SELECT p.id, p.name, p.image_url, p.price, p.quantity_left, `+quantityAvailableColumn+`, `+warehouseCountColumn+`, p.low_stock_threshold, p.is_gift_card, p.category, p.rating_count, p.rating_sum, p.created_at, p.updated_at FROM products p;`)
 	if err != nil {
 		return nil, err
 	}
@@ -31,10 +54,12 @@ func (r *Repo) GetProducts(ctx context.Context) ([]Product, error) {
 	var products []Product
 	for rows.Next() {
 		var p Product
-		err = rows.Scan(&p.ID, &p.Name, &p.ImageURL, &p.Price, &p.QuantityLeft, &p.CreatedAt, &p.UpdatedAt)
+		var ratingSum int
+		err = rows.Scan(&p.ID, &p.Name, &p.ImageURL, &p.Price, &p.QuantityLeft, &p.QuantityAvailable, &p.WarehouseCount, &p.LowStockThreshold, &p.IsGiftCard, &p.Category, &p.RatingCount, &ratingSum, &p.CreatedAt, &p.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
+		p.setAverageRating(ratingSum)
 		products = append(products, p)
 	}
 	return products, nil
@@ -42,7 +67,8 @@ func (r *Repo) GetProducts(ctx context.Context) ([]Product, error) {
 
 func (r *Repo) GetProduct(ctx context.Context, id int) (*Product, error) {
 	var p Product
-	err := r.db.QueryRowContext(ctx, `SELECT id, name, image_url, price, quantity_left, created_at, updated_at FROM products WHERE id=$1 LIMIT 1;`, id).Scan(&p.ID, &p.Name, &p.ImageURL, &p.Price, &p.QuantityLeft, &p.CreatedAt, &p.UpdatedAt)
+	var ratingSum int
+	err := r.db.QueryRowContext(ctx, `SELECT p.id, p.name, p.image_url, p.price, p.quantity_left, `+quantityAvailableColumn+`, `+warehouseCountColumn+`, p.low_stock_threshold, p.is_gift_card, p.category, p.rating_count, p.rating_sum, p.created_at, p.updated_at FROM products p WHERE p.id=$1 LIMIT 1;`, id).Scan(&p.ID, &p.Name, &p.ImageURL, &p.Price, &p.QuantityLeft, &p.QuantityAvailable, &p.WarehouseCount, &p.LowStockThreshold, &p.IsGiftCard, &p.Category, &p.RatingCount, &ratingSum, &p.CreatedAt, &p.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -50,5 +76,6 @@ func (r *Repo) GetProduct(ctx context.Context, id int) (*Product, error) {
 		}
 		return nil, err
 	}
+	p.setAverageRating(ratingSum)
 	return &p, nil
 }
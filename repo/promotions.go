@@ -0,0 +1,231 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rohitxdev/go-api-starter/pricing"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+)
+
+// Promotion is a discount applied automatically to carts that qualify for it, while it runs. See pricing.Promotion for how each kind works.
+type Promotion struct {
+	StartsAt    *string        `json:"startsAt"`
+	EndsAt      *string        `json:"endsAt"`
+	Name        string         `json:"name"`
+	Kind        string         `json:"kind"`
+	Category    string         `json:"category"`
+	CreatedAt   string         `json:"createdAt"`
+	UpdatedAt   string         `json:"updatedAt"`
+	ProductIDs  []int          `json:"productIds"`
+	Tiers       []pricing.Tier `json:"tiers"`
+	ID          int            `json:"id"`
+	BuyQuantity int            `json:"buyQuantity"`
+	GetQuantity int            `json:"getQuantity"`
+	GetPercent  int            `json:"getPercent"`
+	Percent     int            `json:"percent"`
+	Priority    int            `json:"priority"`
+	IsStackable bool           `json:"isStackable"`
+	IsActive    bool           `json:"isActive"`
+}
+
+func (p *Promotion) pricing() pricing.Promotion {
+	return pricing.Promotion{
+		ID:          p.ID,
+		Name:        p.Name,
+		Kind:        p.Kind,
+		Category:    p.Category,
+		ProductIDs:  p.ProductIDs,
+		Tiers:       p.Tiers,
+		BuyQuantity: p.BuyQuantity,
+		GetQuantity: p.GetQuantity,
+		GetPercent:  p.GetPercent,
+		Percent:     p.Percent,
+		Priority:    p.Priority,
+		Stackable:   p.IsStackable,
+	}
+}
+
+type PromotionParams struct {
+	StartsAt    *time.Time
+	EndsAt      *time.Time
+	Name        string
+	Kind        string
+	Category    string
+	ProductIDs  []int
+	Tiers       []pricing.Tier
+	BuyQuantity int
+	GetQuantity int
+	GetPercent  int
+	Percent     int
+	Priority    int
+	IsStackable bool
+	IsActive    bool
+}
+
+// args returns the columns of the promotion in the order of promotionParamColumns.
+func (p *PromotionParams) args() ([]any, error) {
+	productIDs, err := json.Marshal(nonNil(p.ProductIDs))
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := json.Marshal(nonNil(p.Tiers))
+	if err != nil {
+		return nil, err
+	}
+	return []any{p.Name, p.Kind, p.Category, string(productIDs), p.BuyQuantity, p.GetQuantity, p.GetPercent, p.Percent, string(tiers), p.Priority, p.IsStackable, p.StartsAt, p.EndsAt, p.IsActive}, nil
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return make([]T, 0)
+	}
+	return s
+}
+
+const promotionParamColumns = `name, kind, category, product_ids, buy_quantity, get_quantity, get_percent, percent, tiers, priority, is_stackable, starts_at, ends_at, is_active`
+
+const promotionColumns = `id, ` + promotionParamColumns + `, created_at, updated_at`
+
+func scanPromotion(row interface{ Scan(...any) error }, p *Promotion) error {
+	var productIDs, tiers []byte
+	err := row.Scan(&p.ID, &p.Name, &p.Kind, &p.Category, &productIDs, &p.BuyQuantity, &p.GetQuantity, &p.GetPercent, &p.Percent, &tiers, &p.Priority, &p.IsStackable, &p.StartsAt, &p.EndsAt, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(productIDs, &p.ProductIDs); err != nil {
+		return fmt.Errorf("failed to decode promotion products: %w", err)
+	}
+	if err = json.Unmarshal(tiers, &p.Tiers); err != nil {
+		return fmt.Errorf("failed to decode promotion tiers: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) CreatePromotion(ctx context.Context, p *PromotionParams) (*Promotion, error) {
+	args, err := p.args()
+	if err != nil {
+		return nil, err
+	}
+	var promotion Promotion
+	err = scanPromotion(r.db.QueryRowContext(ctx, `
+		INSERT INTO promotions(`+promotionParamColumns+`)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING `+promotionColumns+`;`,
+		args...,
+	), &promotion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return &promotion, nil
+}
+
+// UpdatePromotion replaces the promotion. Orders it was applied to keep the discount they got.
+func (r *Repo) UpdatePromotion(ctx context.Context, id int, p *PromotionParams) (*Promotion, error) {
+	args, err := p.args()
+	if err != nil {
+		return nil, err
+	}
+	var promotion Promotion
+	err = scanPromotion(r.db.QueryRowContext(ctx, `
+		UPDATE promotions
+		SET name = $2, kind = $3, category = $4, product_ids = $5, buy_quantity = $6, get_quantity = $7, get_percent = $8, percent = $9, tiers = $10, priority = $11, is_stackable = $12, starts_at = $13, ends_at = $14, is_active = $15
+		WHERE id = $1
+		RETURNING `+promotionColumns+`;`,
+		append([]any{id}, args...)...,
+	), &promotion)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, fmt.Errorf("failed to update promotion: %w", err)
+	}
+	return &promotion, nil
+}
+
+func (r *Repo) GetPromotions(ctx context.Context) ([]Promotion, error) {
+	return getPromotions(ctx, r.db, `TRUE`)
+}
+
+// GetActivePromotions returns the promotions that are running now, ready to be priced with.
+func (r *Repo) GetActivePromotions(ctx context.Context) ([]pricing.Promotion, error) {
+	return getActivePromotions(ctx, r.db)
+}
+
+func getActivePromotions(ctx context.Context, q querier) ([]pricing.Promotion, error) {
+	promotions, err := getPromotions(ctx, q, `is_active AND (starts_at IS NULL OR starts_at <= current_timestamp) AND (ends_at IS NULL OR ends_at > current_timestamp)`)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]pricing.Promotion, 0, len(promotions))
+	for _, promotion := range promotions {
+		active = append(active, promotion.pricing())
+	}
+	return active, nil
+}
+
+func getPromotions(ctx context.Context, q querier, condition string) ([]Promotion, error) {
+	promotions := make([]Promotion, 0)
+	rows, err := q.QueryContext(ctx, `SELECT `+promotionColumns+` FROM promotions WHERE `+condition+` ORDER BY priority DESC, id;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var promotion Promotion
+		if err = scanPromotion(rows, &promotion); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, promotion)
+	}
+	return promotions, rows.Err()
+}
+
+// recordPromotions records the promotions applied to the order.
+func recordPromotions(ctx context.Context, q querier, orderID int, promotions []pricing.AppliedPromotion) error {
+	for _, promotion := range promotions {
+		productIDs, err := json.Marshal(promotion.ProductIDs)
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(ctx,
+			`INSERT INTO order_promotions(order_id, promotion_id, name, product_ids, discount) VALUES($1, $2, $3, $4, $5);`,
+			orderID, promotion.ID, promotion.Name, string(productIDs), promotion.Discount,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record order promotion: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetOrderPromotions returns the promotions that were applied to the order.
+func (r *Repo) GetOrderPromotions(ctx context.Context, orderID int) ([]pricing.AppliedPromotion, error) {
+	promotions := make([]pricing.AppliedPromotion, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT promotion_id, name, product_ids, discount FROM order_promotions WHERE order_id=$1 ORDER BY id;`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var promotion pricing.AppliedPromotion
+		var productIDs []byte
+		if err = rows.Scan(&promotion.ID, &promotion.Name, &productIDs, &promotion.Discount); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(productIDs, &promotion.ProductIDs); err != nil {
+			return nil, fmt.Errorf("failed to decode promotion products: %w", err)
+		}
+		promotions = append(promotions, promotion)
+	}
+	return promotions, rows.Err()
+}
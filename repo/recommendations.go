@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Recommendation is a product that is often bought together with another.
+type Recommendation struct {
+	ProductName string `json:"productName"`
+	ProductID   int    `json:"productId"`
+	Price       int    `json:"price"`
+	// Score ranks the recommendations, higher first. For a single product, it is the share of its orders that also had the recommended one.
+	Score float64 `json:"score"`
+}
+
+type RefreshProductAffinitiesParams struct {
+	// Since limits the order history to orders placed after it.
+	Since time.Time
+	// MinOrders is how many orders must have had both products for them to be related.
+	MinOrders int
+	// MaxPerProduct is how many related products are kept for each product, the best scoring first.
+	MaxPerProduct int
+}
+
+// RefreshProductAffinities rebuilds the related products of every product from the paid orders. Gift cards are left out, as they say nothing about what else customers like. It returns how many pairs of products were stored.
+func (r *Repo) RefreshProductAffinities(ctx context.Context, p *RefreshProductAffinitiesParams) (count int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Recommendations keep being served from the old pairs until the new ones are committed
+	if _, err = tx.ExecContext(ctx, `DELETE FROM product_affinities;`); err != nil {
+		return 0, fmt.Errorf("failed to delete product affinities: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `
+		WITH items AS (
+			SELECT oi.order_id, oi.product_id
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			JOIN products p ON p.id = oi.product_id
+			WHERE o.status IN ('completed', 'partially_refunded', 'refunded') AND o.created_at >= $1 AND NOT p.is_gift_card
+		),
+		pairs AS (
+			SELECT a.product_id, b.product_id AS related_product_id, COUNT(*) AS orders
+			FROM items a
+			JOIN items b ON b.order_id = a.order_id AND b.product_id <> a.product_id
+			GROUP BY a.product_id, b.product_id
+			HAVING COUNT(*) >= $2
+		),
+		totals AS (
+			SELECT product_id, COUNT(*) AS orders FROM items GROUP BY product_id
+		),
+		ranked AS (
+			SELECT pairs.product_id, pairs.related_product_id, pairs.orders, pairs.orders::DOUBLE PRECISION / totals.orders AS score,
+				ROW_NUMBER() OVER (PARTITION BY pairs.product_id ORDER BY pairs.orders::DOUBLE PRECISION / totals.orders DESC, pairs.orders DESC, pairs.related_product_id) AS rank
+			FROM pairs
+			JOIN totals ON totals.product_id = pairs.product_id
+		)
+		INSERT INTO product_affinities(product_id, related_product_id, orders, score)
+		SELECT product_id, related_product_id, orders, score FROM ranked WHERE rank <= $3;`,
+		p.Since, p.MinOrders, p.MaxPerProduct,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create product affinities: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// GetRecommendations returns up to 'limit' products that are often bought together with the product. Products that are out of stock are left out.
+func (r *Repo) GetRecommendations(ctx context.Context, productID int, limit int) ([]Recommendation, error) {
+	return r.getRecommendations(ctx, `pa.product_id = $1`, productID, limit)
+}
+
+// GetCartRecommendations returns up to 'limit' products that are often bought together with the products in a cart, and are not in it already. Products related to more of the cart score higher.
+func (r *Repo) GetCartRecommendations(ctx context.Context, productIDs []int, limit int) ([]Recommendation, error) {
+	if len(productIDs) == 0 {
+		return make([]Recommendation, 0), nil
+	}
+	return r.getRecommendations(ctx, `pa.product_id = ANY($1) AND NOT (pa.related_product_id = ANY($1))`, productIDs, limit)
+}
+
+func (r *Repo) getRecommendations(ctx context.Context, condition string, args ...any) ([]Recommendation, error) {
+	recommendations := make([]Recommendation, 0)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.price, SUM(pa.score)
+		FROM product_affinities pa
+		JOIN products p ON p.id = pa.related_product_id
+		WHERE `+condition+` AND `+quantityAvailableColumn+` > 0
+		GROUP BY p.id
+		ORDER BY SUM(pa.score) DESC, p.id
+		LIMIT $2;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recommendation Recommendation
+		if err = rows.Scan(&recommendation.ProductID, &recommendation.ProductName, &recommendation.Price, &recommendation.Score); err != nil {
+			return nil, err
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+	return recommendations, rows.Err()
+}
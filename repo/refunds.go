@@ -0,0 +1,309 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrOrderNotRefundable     = errors.New("order is not refundable")
+	ErrOrderItemNotFound      = errors.New("order item not found")
+	ErrRefundQuantityExceeded = errors.New("refund quantity exceeded")
+	ErrNothingToRefund        = errors.New("nothing to refund")
+)
+
+type Refund struct {
+	Reason    string       `json:"reason"`
+	CreatedAt string       `json:"createdAt"`
+	UpdatedAt string       `json:"updatedAt"`
+	Items     []RefundItem `json:"items"`
+	ID        int          `json:"id"`
+	OrderID   int          `json:"orderId"`
+	Amount    int          `json:"amount"`
+	// StoreCreditAmount is the part of the amount that was credited to the store credit of the customer. The rest goes back to their payment method.
+	StoreCreditAmount int  `json:"storeCreditAmount"`
+	CreatedBy         int  `json:"createdBy"`
+	IsRestocked       bool `json:"isRestocked"`
+	// Movements are the inventory movements that restocked the refunded items, if they were restocked.
+	Movements []InventoryMovement `json:"-"`
+}
+
+type RefundItem struct {
+	CreatedAt   string `json:"createdAt"`
+	ID          int    `json:"id"`
+	RefundID    int    `json:"refundId"`
+	OrderItemID int    `json:"orderItemId"`
+	ProductID   int    `json:"productId"`
+	Quantity    int    `json:"quantity"`
+	Amount      int    `json:"amount"`
+}
+
+// RefundLine is a request to refund some quantity of a single order item.
+type RefundLine struct {
+	OrderItemID int
+	Quantity    int
+}
+
+type CreateRefundParams struct {
+	Reason string
+	// Lines to be refunded. If empty, everything that has not been refunded yet is refunded.
+	Lines     []RefundLine
+	OrderID   int
+	CreatedBy int
+	Restock   bool
+	// ToStoreCredit refunds the whole amount as store credit. Otherwise only the share of the order that was paid with gift cards and store credit is.
+	ToStoreCredit bool
+}
+
+// CreateRefund refunds the given order items. The amount refunded for each item is its share of what the customer actually paid for the items, so promotions stay with the items they discounted, and coupon discounts and tax are spread proportionally across the items of the order. Gift cards bought with the order are deactivated instead, and only what is left on them is refunded. Shipping is not refunded. Whatever was paid with gift cards and store credit is refunded as store credit, and the loyalty points the order earned are taken back in proportion to the amount refunded.
+func (r *Repo) CreateRefund(ctx context.Context, p *CreateRefundParams) (refund *Refund, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return createRefund(ctx, tx, p)
+}
+
+// createRefund is CreateRefund within a transaction, so that refunds can be part of other changes, like completing a return.
+func createRefund(ctx context.Context, tx querier, p *CreateRefundParams) (refund *Refund, err error) {
+	var status string
+	var userID, totalAmount, shippingAmount, refundedAmount, paidWithCredit, creditedAlready int
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, user_id, total_amount, shipping_amount, refunded_amount, gift_card_amount + store_credit_amount,
+			(SELECT COALESCE(SUM(store_credit_amount), 0) FROM refunds WHERE order_id = orders.id)
+		 FROM orders WHERE id = $1 FOR UPDATE`,
+		p.OrderID,
+	).Scan(&status, &userID, &totalAmount, &shippingAmount, &refundedAmount, &paidWithCredit, &creditedAlready)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if status != "completed" && status != "partially_refunded" {
+		return nil, ErrOrderNotRefundable
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT oi.id, oi.product_id, oi.quantity, oi.price, oi.discount, oi.refunded_quantity, p.is_gift_card
+		 FROM order_items oi
+		 JOIN products p ON p.id = oi.product_id
+		 WHERE oi.order_id = $1
+		 ORDER BY oi.id
+		 FOR UPDATE OF oi`,
+		p.OrderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	orderItems := make([]*OrderItem, 0)
+	orderItemsByID := make(map[int]*OrderItem)
+	isGiftCard := make(map[int]bool)
+	var subtotal, paidForGiftCards, quantityLeftToRefund, itemQuantityLeftToRefund int
+	for rows.Next() {
+		var item OrderItem
+		var giftCard bool
+		if err = rows.Scan(&item.ID, &item.ProductID, &item.Quantity, &item.Price, &item.Discount, &item.RefundedQuantity, &giftCard); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		orderItems = append(orderItems, &item)
+		orderItemsByID[item.ID] = &item
+		isGiftCard[item.ID] = giftCard
+		quantityLeftToRefund += item.Quantity - item.RefundedQuantity
+		// Gift cards are paid in full, without discounts or tax, so they don't take a share of either
+		if giftCard {
+			paidForGiftCards += item.Price * item.Quantity
+			continue
+		}
+		subtotal += item.Price*item.Quantity - item.Discount
+		itemQuantityLeftToRefund += item.Quantity - item.RefundedQuantity
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Shipping is not refunded; what was paid for the items other than gift cards, tax included, is.
+	paidForItems := totalAmount - shippingAmount - paidForGiftCards
+	var refundedForItems int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(ri.amount), 0)
+		 FROM refund_items ri
+		 JOIN order_items oi ON oi.id = ri.order_item_id
+		 JOIN products p ON p.id = oi.product_id
+		 WHERE oi.order_id = $1 AND NOT p.is_gift_card`,
+		p.OrderID,
+	).Scan(&refundedForItems)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunded amount: %w", err)
+	}
+
+	lines := p.Lines
+	if len(lines) == 0 {
+		for _, item := range orderItems {
+			if item.Quantity > item.RefundedQuantity {
+				lines = append(lines, RefundLine{OrderItemID: item.ID, Quantity: item.Quantity - item.RefundedQuantity})
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return nil, ErrNothingToRefund
+	}
+
+	refund = &Refund{
+		OrderID:     p.OrderID,
+		Reason:      p.Reason,
+		CreatedBy:   p.CreatedBy,
+		IsRestocked: p.Restock,
+		Items:       make([]RefundItem, 0, len(lines)),
+	}
+	// lastItem is the last item of the refund other than a gift card, which takes what rounding kept
+	lastItem := -1
+	for _, line := range lines {
+		item, ok := orderItemsByID[line.OrderItemID]
+		if !ok {
+			return nil, ErrOrderItemNotFound
+		}
+		if line.Quantity <= 0 || item.RefundedQuantity+line.Quantity > item.Quantity {
+			return nil, ErrRefundQuantityExceeded
+		}
+		item.RefundedQuantity += line.Quantity
+		quantityLeftToRefund -= line.Quantity
+
+		amount := 0
+		if isGiftCard[item.ID] {
+			if amount, err = refundGiftCards(ctx, tx, item.ID, line.Quantity, p.CreatedBy, p.Reason); err != nil {
+				return nil, err
+			}
+		} else {
+			itemQuantityLeftToRefund -= line.Quantity
+			if subtotal > 0 {
+				amount = (item.Price*line.Quantity - item.Discount*line.Quantity/item.Quantity) * paidForItems / subtotal
+			}
+			refundedForItems += amount
+			lastItem = len(refund.Items)
+		}
+		refund.Amount += amount
+		refund.Items = append(refund.Items, RefundItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			Quantity:    line.Quantity,
+			Amount:      amount,
+		})
+	}
+
+	if itemQuantityLeftToRefund == 0 && lastItem >= 0 {
+		// Hand back whatever rounding kept, so that fully refunded items are refunded in full.
+		remainder := paidForItems - refundedForItems
+		refund.Amount += remainder
+		refund.Items[lastItem].Amount += remainder
+		refundedForItems += remainder
+	}
+	status = "partially_refunded"
+	if quantityLeftToRefund == 0 {
+		status = "refunded"
+	}
+
+	refund.StoreCreditAmount = refund.Amount
+	if !p.ToStoreCredit {
+		// What was paid with gift cards and store credit can only go back to store credit
+		refund.StoreCreditAmount = 0
+		if totalAmount > 0 {
+			credit := (refundedAmount+refund.Amount)*paidWithCredit/totalAmount - creditedAlready
+			refund.StoreCreditAmount = min(max(credit, 0), refund.Amount)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO refunds(order_id, amount, store_credit_amount, reason, is_restocked, created_by)
+		 VALUES($1, $2, $3, $4, $5, $6)
+		 RETURNING id, created_at, updated_at`,
+		refund.OrderID, refund.Amount, refund.StoreCreditAmount, refund.Reason, refund.IsRestocked, refund.CreatedBy,
+	).Scan(&refund.ID, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	if refund.StoreCreditAmount > 0 {
+		_, err = changeStoreCredit(ctx, tx, &StoreCreditTransaction{
+			UserID:    userID,
+			Kind:      StoreCreditRefund,
+			Amount:    refund.StoreCreditAmount,
+			OrderID:   &refund.OrderID,
+			RefundID:  &refund.ID,
+			CreatedBy: &refund.CreatedBy,
+			Note:      refund.Reason,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range refund.Items {
+		item := &refund.Items[i]
+		item.RefundID = refund.ID
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO refund_items(refund_id, order_item_id, quantity, amount)
+			 VALUES($1, $2, $3, $4)
+			 RETURNING id, created_at`,
+			item.RefundID, item.OrderItemID, item.Quantity, item.Amount,
+		).Scan(&item.ID, &item.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create refund item: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE order_items SET refunded_quantity = refunded_quantity + $1 WHERE id = $2`,
+			item.Quantity, item.OrderItemID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update order item: %w", err)
+		}
+
+		if p.Restock {
+			var warehouseID int
+			if warehouseID, err = restockWarehouseID(ctx, tx, item.OrderItemID); err != nil {
+				return nil, err
+			}
+			movement := InventoryMovement{
+				ProductID:   item.ProductID,
+				Kind:        MovementRefund,
+				Quantity:    item.Quantity,
+				WarehouseID: &warehouseID,
+				ActorID:     &p.CreatedBy,
+				OrderID:     &p.OrderID,
+				Reason:      p.Reason,
+			}
+			if err = moveStock(ctx, tx, &movement); err != nil {
+				return nil, err
+			}
+			refund.Movements = append(refund.Movements, movement)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE orders SET status = $1, refunded_amount = refunded_amount + $2 WHERE id = $3`,
+		status, refund.Amount, refund.OrderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	// Gift cards didn't earn points, so refunding them doesn't take any back
+	if err = reversePoints(ctx, tx, refund.OrderID, refundedForItems, paidForItems); err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrStockHeld         = errors.New("stock is held by checkouts")
+)
+
+// Reservation holds some stock of a product for a user while they check out, so that nobody else can buy it in the meantime.
+type Reservation struct {
+	ExpiresAt string `json:"expiresAt"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+	ID        int    `json:"id"`
+	UserID    int    `json:"userId"`
+	ProductID int    `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// quantityAvailableColumn computes the stock of product 'p' that is not held by any active reservation.
+const quantityAvailableColumn = `p.quantity_left - COALESCE((SELECT SUM(sr.quantity) FROM stock_reservations sr WHERE sr.product_id = p.id AND sr.expires_at > current_timestamp), 0)`
+
+// heldByOthers returns the quantity of a product held by active reservations of users other than the given user.
+func heldByOthers(ctx context.Context, q querier, productID int, userID int) (int, error) {
+	var held int
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = $1 AND user_id <> $2 AND expires_at > current_timestamp`,
+		productID, userID,
+	).Scan(&held)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get reserved quantity: %w", err)
+	}
+	return held, nil
+}
+
+// ReserveCart holds the stock of every item in the user's cart for the given duration. Any previous reservations of the user are replaced.
+func (r *Repo) ReserveCart(ctx context.Context, userID int, duration time.Duration) (reservations []Reservation, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = releaseReservations(ctx, tx, "replaced by a new checkout", "user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	// Lock products in a stable order so that concurrent checkouts can't deadlock.
+	rows, err := tx.QueryContext(ctx, `SELECT product_id, quantity FROM cart_items WHERE user_id = $1 ORDER BY product_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+	cart := make([]CartItem, 0)
+	for rows.Next() {
+		var cartItem CartItem
+		if err = rows.Scan(&cartItem.ProductID, &cartItem.Quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan cart item: %w", err)
+		}
+		cart = append(cart, cartItem)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(cart) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	reservations = make([]Reservation, 0, len(cart))
+	for _, cartItem := range cart {
+		var quantityLeft int
+		err = tx.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id = $1 FOR UPDATE`, cartItem.ProductID).Scan(&quantityLeft)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check product quantity: %w", err)
+		}
+		var held int
+		if held, err = heldByOthers(ctx, tx, cartItem.ProductID, userID); err != nil {
+			return nil, err
+		}
+		if quantityLeft-held < cartItem.Quantity {
+			return nil, fmt.Errorf("%w for product %d", ErrInsufficientStock, cartItem.ProductID)
+		}
+
+		var reservation Reservation
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO stock_reservations(user_id, product_id, quantity, expires_at)
+			 VALUES($1, $2, $3, current_timestamp + $4::BIGINT * INTERVAL '1 second')
+			 RETURNING id, user_id, product_id, quantity, expires_at, created_at, updated_at`,
+			userID, cartItem.ProductID, cartItem.Quantity, int(duration.Seconds()),
+		).Scan(&reservation.ID, &reservation.UserID, &reservation.ProductID, &reservation.Quantity, &reservation.ExpiresAt, &reservation.CreatedAt, &reservation.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create reservation: %w", err)
+		}
+		err = recordMovement(ctx, tx, &InventoryMovement{
+			ProductID: reservation.ProductID,
+			Kind:      MovementReservation,
+			Quantity:  -reservation.Quantity,
+			ActorID:   &userID,
+			Reason:    "checkout started",
+		})
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, nil
+}
+
+func (r *Repo) GetReservations(ctx context.Context, userID int) ([]Reservation, error) {
+	reservations := make([]Reservation, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, product_id, quantity, expires_at, created_at, updated_at FROM stock_reservations WHERE user_id=$1 AND expires_at > current_timestamp ORDER BY product_id;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation Reservation
+		err = rows.Scan(&reservation.ID, &reservation.UserID, &reservation.ProductID, &reservation.Quantity, &reservation.ExpiresAt, &reservation.CreatedAt, &reservation.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, nil
+}
+
+func (r *Repo) ReleaseReservations(ctx context.Context, userID int) error {
+	_, err := releaseReservations(ctx, r.db, "checkout cancelled", "user_id = $1", userID)
+	return err
+}
+
+// DeleteExpiredReservations releases the stock held by expired reservations. It returns the product of each released reservation.
+func (r *Repo) DeleteExpiredReservations(ctx context.Context) ([]int, error) {
+	return releaseReservations(ctx, r.db, "expired", "expires_at <= current_timestamp")
+}
@@ -0,0 +1,304 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrReturnNotFound          = errors.New("return not found")
+	ErrOrderNotReturnable      = errors.New("order is not returnable")
+	ErrReturnQuantityExceeded  = errors.New("return quantity exceeded")
+	ErrReturnStatusInvalid     = errors.New("return can't move to this status")
+	ErrReturnPhotoLimitReached = errors.New("return photo limit reached")
+)
+
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+	ReturnReceived  = "received"
+	ReturnInspected = "inspected"
+	ReturnCompleted = "completed"
+)
+
+// returnTransitions are the statuses that a return can move to from each status.
+var returnTransitions = map[string][]string{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnInspected},
+	ReturnInspected: {ReturnCompleted},
+}
+
+// MaxReturnPhotos is how many photos a return can have.
+const MaxReturnPhotos = 5
+
+type Return struct {
+	Reason    string `json:"reason"`
+	Comment   string `json:"comment"`
+	Status    string `json:"status"`
+	AdminNote string `json:"adminNote"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+	// IsResellable is set when the item is inspected. Resellable items are restocked when the return is completed.
+	IsResellable *bool `json:"isResellable"`
+	// RefundID is set when the return is completed.
+	RefundID    *int          `json:"refundId"`
+	Photos      []ReturnPhoto `json:"photos,omitempty"`
+	ID          int           `json:"id"`
+	OrderID     int           `json:"orderId"`
+	OrderItemID int           `json:"orderItemId"`
+	UserID      int           `json:"userId"`
+	Quantity    int           `json:"quantity"`
+}
+
+type ReturnPhoto struct {
+	// FileName is the key of the photo in the blob store.
+	FileName string `json:"-"`
+	// URL is a short-lived link to the photo, set by the handler.
+	URL       string `json:"url"`
+	CreatedAt string `json:"createdAt"`
+	ID        int    `json:"id"`
+}
+
+const returnColumns = `id, order_id, order_item_id, user_id, quantity, reason, comment, status, admin_note, is_resellable, refund_id, created_at, updated_at`
+
+func scanReturn(row interface{ Scan(...any) error }, rt *Return) error {
+	return row.Scan(&rt.ID, &rt.OrderID, &rt.OrderItemID, &rt.UserID, &rt.Quantity, &rt.Reason, &rt.Comment, &rt.Status, &rt.AdminNote, &rt.IsResellable, &rt.RefundID, &rt.CreatedAt, &rt.UpdatedAt)
+}
+
+type CreateReturnParams struct {
+	Reason      string
+	Comment     string
+	UserID      int
+	OrderID     int
+	OrderItemID int
+	Quantity    int
+}
+
+// CreateReturn requests the return of some quantity of an item of a completed order of the user. Quantities that were refunded, or are being returned already, can't be returned again.
+func (r *Repo) CreateReturn(ctx context.Context, p *CreateReturnParams) (rt *Return, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = $1 AND user_id = $2;`, p.OrderID, p.UserID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if status != "completed" && status != "partially_refunded" {
+		return nil, ErrOrderNotReturnable
+	}
+
+	// Locking the item makes concurrent returns of it wait, so that they can't return more than was bought together
+	var returnable int
+	err = tx.QueryRowContext(ctx, `
+		SELECT quantity - refunded_quantity FROM order_items WHERE id = $1 AND order_id = $2 FOR UPDATE;`,
+		p.OrderItemID, p.OrderID,
+	).Scan(&returnable)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderItemNotFound
+		}
+		return nil, fmt.Errorf("failed to get order item: %w", err)
+	}
+	var returning int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(quantity), 0) FROM returns WHERE order_item_id = $1 AND status NOT IN ($2, $3);`,
+		p.OrderItemID, ReturnRejected, ReturnCompleted,
+	).Scan(&returning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get returns: %w", err)
+	}
+	if p.Quantity > returnable-returning {
+		return nil, ErrReturnQuantityExceeded
+	}
+
+	rt = &Return{}
+	err = scanReturn(tx.QueryRowContext(ctx,
+		`INSERT INTO returns(order_id, order_item_id, user_id, quantity, reason, comment) VALUES($1, $2, $3, $4, $5, $6) RETURNING `+returnColumns+`;`,
+		p.OrderID, p.OrderItemID, p.UserID, p.Quantity, p.Reason, p.Comment,
+	), rt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create return: %w", err)
+	}
+	return rt, nil
+}
+
+// GetReturn returns the return with its photos.
+func (r *Repo) GetReturn(ctx context.Context, id int) (*Return, error) {
+	var rt Return
+	if err := scanReturn(r.db.QueryRowContext(ctx, `SELECT `+returnColumns+` FROM returns WHERE id = $1;`, id), &rt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReturnNotFound
+		}
+		return nil, fmt.Errorf("failed to get return: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT id, file_name, created_at FROM return_photos WHERE return_id = $1 ORDER BY id;`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get return photos: %w", err)
+	}
+	defer rows.Close()
+
+	rt.Photos = make([]ReturnPhoto, 0)
+	for rows.Next() {
+		var photo ReturnPhoto
+		if err = rows.Scan(&photo.ID, &photo.FileName, &photo.CreatedAt); err != nil {
+			return nil, err
+		}
+		rt.Photos = append(rt.Photos, photo)
+	}
+	return &rt, rows.Err()
+}
+
+// GetReturnsForUser returns the returns of the user without their photos, latest first.
+func (r *Repo) GetReturnsForUser(ctx context.Context, userID int, page int, pageSize int) ([]Return, error) {
+	return r.getReturns(ctx, `user_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`, userID, pageSize, page*pageSize)
+}
+
+// GetReturnsByStatus returns the returns with the given status without their photos, oldest first, so that admins handle them in order.
+func (r *Repo) GetReturnsByStatus(ctx context.Context, status string, page int, pageSize int) ([]Return, error) {
+	return r.getReturns(ctx, `status = $1 ORDER BY id LIMIT $2 OFFSET $3`, status, pageSize, page*pageSize)
+}
+
+func (r *Repo) getReturns(ctx context.Context, condition string, args ...any) ([]Return, error) {
+	returns := make([]Return, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+returnColumns+` FROM returns WHERE `+condition+`;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rt Return
+		if err = scanReturn(rows, &rt); err != nil {
+			return nil, err
+		}
+		returns = append(returns, rt)
+	}
+	return returns, rows.Err()
+}
+
+// AddReturnPhoto records a photo of a return. Photos can only be added until the return is approved or rejected, up to MaxReturnPhotos.
+func (r *Repo) AddReturnPhoto(ctx context.Context, returnID int, fileName string) (photo *ReturnPhoto, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var status string
+	var count int
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, (SELECT COUNT(*) FROM return_photos WHERE return_id = returns.id) FROM returns WHERE id = $1 FOR UPDATE;`,
+		returnID,
+	).Scan(&status, &count)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReturnNotFound
+		}
+		return nil, fmt.Errorf("failed to get return: %w", err)
+	}
+	if status != ReturnRequested {
+		return nil, ErrReturnStatusInvalid
+	}
+	if count >= MaxReturnPhotos {
+		return nil, ErrReturnPhotoLimitReached
+	}
+
+	photo = &ReturnPhoto{FileName: fileName}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO return_photos(return_id, file_name) VALUES($1, $2) RETURNING id, created_at;`,
+		returnID, fileName,
+	).Scan(&photo.ID, &photo.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create return photo: %w", err)
+	}
+	return photo, nil
+}
+
+type UpdateReturnStatusParams struct {
+	Status string
+	Note   string
+	// IsResellable is required when the return is inspected.
+	IsResellable *bool
+	ID           int
+	// ActorID is the admin who changed the status. Completed returns are refunded in their name.
+	ActorID int
+}
+
+// UpdateReturnStatus moves the return to the next status. Completing a return refunds the returned quantity, and restocks it if the item was found resellable. The refund is returned for completed returns.
+func (r *Repo) UpdateReturnStatus(ctx context.Context, p *UpdateReturnStatusParams) (rt *Return, refund *Refund, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	rt = &Return{}
+	if err = scanReturn(tx.QueryRowContext(ctx, `SELECT `+returnColumns+` FROM returns WHERE id = $1 FOR UPDATE;`, p.ID), rt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrReturnNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get return: %w", err)
+	}
+	if !slices.Contains(returnTransitions[rt.Status], p.Status) || (p.Status == ReturnInspected && p.IsResellable == nil) {
+		return nil, nil, ErrReturnStatusInvalid
+	}
+
+	isResellable := rt.IsResellable
+	if p.Status == ReturnInspected {
+		isResellable = p.IsResellable
+	}
+	var refundID *int
+	if p.Status == ReturnCompleted {
+		refund, err = createRefund(ctx, tx, &CreateRefundParams{
+			Reason:    fmt.Sprintf("Return #%d: %s", rt.ID, rt.Reason),
+			Lines:     []RefundLine{{OrderItemID: rt.OrderItemID, Quantity: rt.Quantity}},
+			OrderID:   rt.OrderID,
+			CreatedBy: p.ActorID,
+			Restock:   isResellable != nil && *isResellable,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		refundID = &refund.ID
+	}
+
+	err = scanReturn(tx.QueryRowContext(ctx,
+		`UPDATE returns SET status = $1, admin_note = $2, is_resellable = $3::BOOLEAN, refund_id = COALESCE($4::BIGINT, refund_id) WHERE id = $5 RETURNING `+returnColumns+`;`,
+		p.Status, p.Note, isResellable, refundID, rt.ID,
+	), rt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update return: %w", err)
+	}
+	return rt, refund, nil
+}
@@ -0,0 +1,224 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrReviewNotFound      = errors.New("review not found")
+	ErrReviewAlreadyExists = errors.New("review already exists")
+	ErrReviewNotPurchased  = errors.New("product was not purchased")
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+type Review struct {
+	Body string `json:"body"`
+	// Status is ReviewPending until an admin approves or rejects the review. Only approved reviews are shown with the product.
+	Status    string `json:"status"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+	ID        int    `json:"id"`
+	ProductID int    `json:"productId"`
+	UserID    int    `json:"userId"`
+	// OrderID is the order in which the user bought the product.
+	OrderID int `json:"orderId"`
+	Rating  int `json:"rating"`
+}
+
+const reviewColumns = `id, product_id, user_id, order_id, rating, body, status, created_at, updated_at`
+
+func scanReview(row interface{ Scan(...any) error }, rv *Review) error {
+	return row.Scan(&rv.ID, &rv.ProductID, &rv.UserID, &rv.OrderID, &rv.Rating, &rv.Body, &rv.Status, &rv.CreatedAt, &rv.UpdatedAt)
+}
+
+// CreateReview adds the review of the user for the product, pending approval. Users can only review products they bought and kept, once each.
+func (r *Repo) CreateReview(ctx context.Context, userID int, productID int, rating int, body string) (*Review, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT TRUE FROM products WHERE id = $1;`, productID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	// Items that were refunded in full don't count as a purchase
+	var orderID int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT o.id FROM orders o
+		JOIN order_items oi ON oi.order_id = o.id
+		WHERE o.user_id = $1 AND oi.product_id = $2 AND oi.quantity > oi.refunded_quantity AND o.status IN ('completed', 'partially_refunded', 'refunded')
+		ORDER BY o.id DESC
+		LIMIT 1;`, userID, productID).Scan(&orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReviewNotPurchased
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	var review Review
+	err = scanReview(r.db.QueryRowContext(ctx,
+		`INSERT INTO reviews(product_id, user_id, order_id, rating, body) VALUES($1, $2, $3, $4, $5)
+		 ON CONFLICT (product_id, user_id) DO NOTHING
+		 RETURNING `+reviewColumns+`;`,
+		productID, userID, orderID, rating, body,
+	), &review)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReviewAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create review: %w", err)
+	}
+	return &review, nil
+}
+
+// UpdateReview changes the rating and text of the review of the user for the product. The review goes back to pending approval.
+func (r *Repo) UpdateReview(ctx context.Context, userID int, productID int, rating int, body string) (review *Review, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var old Review
+	if err = scanReview(tx.QueryRowContext(ctx, `SELECT `+reviewColumns+` FROM reviews WHERE product_id = $1 AND user_id = $2 FOR UPDATE;`, productID, userID), &old); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReviewNotFound
+		}
+		return nil, fmt.Errorf("failed to get review: %w", err)
+	}
+	if err = updateProductRating(ctx, tx, &old, ReviewPending); err != nil {
+		return nil, err
+	}
+
+	review = &Review{}
+	err = scanReview(tx.QueryRowContext(ctx,
+		`UPDATE reviews SET rating = $1, body = $2, status = $3 WHERE id = $4 RETURNING `+reviewColumns+`;`,
+		rating, body, ReviewPending, old.ID,
+	), review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update review: %w", err)
+	}
+	return review, nil
+}
+
+// DeleteReview deletes the review of the user for the product.
+func (r *Repo) DeleteReview(ctx context.Context, userID int, productID int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var review Review
+	if err = scanReview(tx.QueryRowContext(ctx, `DELETE FROM reviews WHERE product_id = $1 AND user_id = $2 RETURNING `+reviewColumns+`;`, productID, userID), &review); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrReviewNotFound
+		}
+		return fmt.Errorf("failed to delete review: %w", err)
+	}
+	return updateProductRating(ctx, tx, &review, "")
+}
+
+// SetReviewStatus approves or rejects the review.
+func (r *Repo) SetReviewStatus(ctx context.Context, id int, status string) (review *Review, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	review = &Review{}
+	var oldStatus string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM reviews WHERE id = $1 FOR UPDATE;`, id).Scan(&oldStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReviewNotFound
+		}
+		return nil, fmt.Errorf("failed to get review: %w", err)
+	}
+	if err = scanReview(tx.QueryRowContext(ctx, `UPDATE reviews SET status = $1 WHERE id = $2 RETURNING `+reviewColumns+`;`, status, id), review); err != nil {
+		return nil, fmt.Errorf("failed to update review: %w", err)
+	}
+	old := *review
+	old.Status = oldStatus
+	if err = updateProductRating(ctx, tx, &old, status); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// updateProductRating keeps the rating counters of the product in step with a review whose status changes to newStatus. An empty newStatus means the review is deleted.
+func updateProductRating(ctx context.Context, q querier, review *Review, newStatus string) error {
+	var count int
+	switch {
+	case review.Status != ReviewApproved && newStatus == ReviewApproved:
+		count = 1
+	case review.Status == ReviewApproved && newStatus != ReviewApproved:
+		count = -1
+	default:
+		return nil
+	}
+	_, err := q.ExecContext(ctx,
+		`UPDATE products SET rating_count = rating_count + $1, rating_sum = rating_sum + $2 WHERE id = $3;`,
+		count, count*review.Rating, review.ProductID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update product rating: %w", err)
+	}
+	return nil
+}
+
+// GetProductReviews returns the approved reviews of the product, latest first.
+func (r *Repo) GetProductReviews(ctx context.Context, productID int, page int, pageSize int) ([]Review, error) {
+	return r.getReviews(ctx, `product_id = $1 AND status = $2 ORDER BY id DESC LIMIT $3 OFFSET $4`, productID, ReviewApproved, pageSize, page*pageSize)
+}
+
+// GetReviewsByStatus returns the reviews with the given status, oldest first, so that admins moderate them in order.
+func (r *Repo) GetReviewsByStatus(ctx context.Context, status string, page int, pageSize int) ([]Review, error) {
+	return r.getReviews(ctx, `status = $1 ORDER BY id LIMIT $2 OFFSET $3`, status, pageSize, page*pageSize)
+}
+
+func (r *Repo) getReviews(ctx context.Context, condition string, args ...any) ([]Review, error) {
+	reviews := make([]Review, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+reviewColumns+` FROM reviews WHERE `+condition+`;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review Review
+		if err = scanReview(rows, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	ErrRewardRuleNotFound = errors.New("reward rule not found")
+)
+
+// Kinds of reward rules.
+const (
+	// RewardEveryNthOrder rewards every OrderInterval-th order of a user.
+	RewardEveryNthOrder = "every_nth_order"
+	// RewardSpendThreshold rewards the order that takes the total spend of a user to SpendThreshold.
+	RewardSpendThreshold = "spend_threshold"
+	// RewardFirstOrder rewards the first order of a user.
+	RewardFirstOrder = "first_order"
+)
+
+// RewardRule issues a personal coupon to users whose order earns it.
+type RewardRule struct {
+	OrderInterval  *int `json:"orderInterval"`
+	SpendThreshold *int `json:"spendThreshold"`
+	MaxDiscount    *int `json:"maxDiscount"`
+	// CouponValidDays is how long the issued coupon can be redeemed for. nil means it doesn't expire.
+	CouponValidDays *int   `json:"couponValidDays"`
+	Name            string `json:"name"`
+	Kind            string `json:"kind"`
+	DiscountType    string `json:"discountType"`
+	CreatedAt       string `json:"createdAt"`
+	UpdatedAt       string `json:"updatedAt"`
+	ID              int    `json:"id"`
+	DiscountValue   int    `json:"discountValue"`
+	// IssuedCount is how many coupons the rule has issued.
+	IssuedCount int  `json:"issuedCount"`
+	IsActive    bool `json:"isActive"`
+}
+
+// isEarnedBy reports whether an order earns the reward. orderCount includes the order, and previousSpend doesn't.
+func (rule *RewardRule) isEarnedBy(orderCount int, previousSpend int, orderTotal int) bool {
+	switch rule.Kind {
+	case RewardEveryNthOrder:
+		return rule.OrderInterval != nil && orderCount%*rule.OrderInterval == 0
+	case RewardSpendThreshold:
+		return rule.SpendThreshold != nil && previousSpend < *rule.SpendThreshold && previousSpend+orderTotal >= *rule.SpendThreshold
+	case RewardFirstOrder:
+		return orderCount == 1
+	}
+	return false
+}
+
+type RewardRuleParams struct {
+	OrderInterval   *int
+	SpendThreshold  *int
+	MaxDiscount     *int
+	CouponValidDays *int
+	Name            string
+	Kind            string
+	DiscountType    string
+	DiscountValue   int
+	IsActive        bool
+}
+
+const rewardRuleColumns = `r.id, r.name, r.kind, r.order_interval, r.spend_threshold, r.discount_type, r.discount_value, r.max_discount, r.coupon_valid_days, r.is_active, r.created_at, r.updated_at`
+
+func scanRewardRule(row interface{ Scan(...any) error }, rule *RewardRule, extra ...any) error {
+	return row.Scan(append([]any{&rule.ID, &rule.Name, &rule.Kind, &rule.OrderInterval, &rule.SpendThreshold, &rule.DiscountType, &rule.DiscountValue, &rule.MaxDiscount, &rule.CouponValidDays, &rule.IsActive, &rule.CreatedAt, &rule.UpdatedAt}, extra...)...)
+}
+
+func (r *Repo) CreateRewardRule(ctx context.Context, p *RewardRuleParams) (*RewardRule, error) {
+	var rule RewardRule
+	err := scanRewardRule(r.db.QueryRowContext(ctx, `
+		INSERT INTO reward_rules AS r(name, kind, order_interval, spend_threshold, discount_type, discount_value, max_discount, coupon_valid_days, is_active)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+rewardRuleColumns+`;`,
+		p.Name, p.Kind, p.OrderInterval, p.SpendThreshold, p.DiscountType, p.DiscountValue, p.MaxDiscount, p.CouponValidDays, p.IsActive,
+	), &rule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reward rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// UpdateRewardRule replaces the rule. Coupons the rule has already issued are left as they are.
+func (r *Repo) UpdateRewardRule(ctx context.Context, id int, p *RewardRuleParams) (*RewardRule, error) {
+	var rule RewardRule
+	err := scanRewardRule(r.db.QueryRowContext(ctx, `
+		UPDATE reward_rules AS r
+		SET name = $2, kind = $3, order_interval = $4, spend_threshold = $5, discount_type = $6, discount_value = $7, max_discount = $8, coupon_valid_days = $9, is_active = $10
+		WHERE r.id = $1
+		RETURNING `+rewardRuleColumns+`, (SELECT COUNT(*) FROM reward_issuances ri WHERE ri.rule_id = r.id);`,
+		id, p.Name, p.Kind, p.OrderInterval, p.SpendThreshold, p.DiscountType, p.DiscountValue, p.MaxDiscount, p.CouponValidDays, p.IsActive,
+	), &rule, &rule.IssuedCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRewardRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to update reward rule: %w", err)
+	}
+	return &rule, nil
+}
+
+func (r *Repo) GetRewardRules(ctx context.Context) ([]RewardRule, error) {
+	rules := make([]RewardRule, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+rewardRuleColumns+`, (SELECT COUNT(*) FROM reward_issuances ri WHERE ri.rule_id = r.id) FROM reward_rules r ORDER BY r.id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule RewardRule
+		if err = scanRewardRule(rows, &rule, &rule.IssuedCount); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// issueRewards issues a coupon for every active rule that the order earns. A rule is issued at most once per order, so it is safe to call again for the same order. The user must be locked, so that their concurrent orders are counted one after the other.
+func issueRewards(ctx context.Context, q querier, order *Order) ([]Coupon, error) {
+	var orderCount, previousSpend int
+	err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(total_amount) FILTER (WHERE id <> $2), 0)
+		FROM orders
+		WHERE user_id = $1 AND status NOT IN ('cancelled', 'refunded');`,
+		order.UserID, order.ID,
+	).Scan(&orderCount, &previousSpend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT `+rewardRuleColumns+` FROM reward_rules r WHERE r.is_active ORDER BY r.id;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reward rules: %w", err)
+	}
+	var earned []RewardRule
+	for rows.Next() {
+		var rule RewardRule
+		if err = scanRewardRule(rows, &rule); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if rule.isEarnedBy(orderCount, previousSpend, order.TotalAmount) {
+			earned = append(earned, rule)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	coupons := make([]Coupon, 0, len(earned))
+	for _, rule := range earned {
+		var issuanceID int
+		err = q.QueryRowContext(ctx,
+			`INSERT INTO reward_issuances(rule_id, order_id, user_id) VALUES($1, $2, $3)
+			 ON CONFLICT (rule_id, order_id) DO NOTHING
+			 RETURNING id;`,
+			rule.ID, order.ID, order.UserID,
+		).Scan(&issuanceID)
+		if err != nil {
+			// Already issued for this order
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return nil, fmt.Errorf("failed to issue reward: %w", err)
+		}
+
+		params := CreateCouponParams{
+			Code:          ulid.Make().String(),
+			UserID:        &order.UserID,
+			DiscountType:  rule.DiscountType,
+			DiscountValue: rule.DiscountValue,
+			MaxDiscount:   rule.MaxDiscount,
+		}
+		if rule.CouponValidDays != nil {
+			expiresAt := time.Now().AddDate(0, 0, *rule.CouponValidDays)
+			params.ExpiresAt = &expiresAt
+		}
+		var coupon *Coupon
+		if coupon, err = createCoupon(ctx, q, &params); err != nil {
+			return nil, err
+		}
+		if _, err = q.ExecContext(ctx, `UPDATE reward_issuances SET coupon_id = $1 WHERE id = $2;`, coupon.ID, issuanceID); err != nil {
+			return nil, fmt.Errorf("failed to issue reward: %w", err)
+		}
+		coupons = append(coupons, *coupon)
+	}
+	return coupons, nil
+}
@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	StatsIntervalDay  = "day"
+	StatsIntervalWeek = "week"
+)
+
+// SalesStatsPeriod is a day or a week of sales. Amounts are in the smallest unit of the currency.
+type SalesStatsPeriod struct {
+	// Start is the first day of the period, e.g. 2026-10-12. Weeks start on Monday.
+	Start          string `json:"start"`
+	Orders         int    `json:"orders"`
+	ItemsPurchased int    `json:"itemsPurchased"`
+	// Revenue is what the orders were worth after discounts, and RefundedAmount what was refunded of it since.
+	Revenue        int `json:"revenue"`
+	RefundedAmount int `json:"refundedAmount"`
+	// Discount is what promotions, coupons and loyalty points took off the orders.
+	Discount int `json:"discount"`
+}
+
+// SalesStats are the sales of a range of time, in total and per period.
+type SalesStats struct {
+	Orders            int `json:"orders"`
+	ItemsPurchased    int `json:"itemsPurchased"`
+	Revenue           int `json:"revenue"`
+	RefundedAmount    int `json:"refundedAmount"`
+	Discount          int `json:"discount"`
+	AverageOrderValue int `json:"averageOrderValue"`
+	// CouponsIssued are the coupons created in the range, and CouponsRedeemed how often any coupon was redeemed in it.
+	CouponsIssued   int `json:"couponsIssued"`
+	CouponsRedeemed int `json:"couponsRedeemed"`
+	// Periods are the sales of every day or week in the range that had any.
+	Periods []SalesStatsPeriod `json:"periods"`
+}
+
+type SalesStatsParams struct {
+	// From and To limit the stats to orders placed from 'From' until 'To'. Either may be nil for no limit.
+	From *time.Time
+	To   *time.Time
+	// Interval is either StatsIntervalDay or StatsIntervalWeek.
+	Interval string
+}
+
+// GetSalesStats sums up the paid orders in the range, per day or week. Cancelled and unpaid orders are left out.
+func (r *Repo) GetSalesStats(ctx context.Context, p *SalesStatsParams) (*SalesStats, error) {
+	// Items are summed per order first, so that joining them doesn't count the order amounts more than once
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT to_char(date_trunc($3, o.created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD'),
+			COUNT(*), COALESCE(SUM(oi.quantity), 0), COALESCE(SUM(o.total_amount), 0), COALESCE(SUM(o.refunded_amount), 0),
+			COALESCE(SUM(o.promotion_discount + o.discounted_amount + o.points_discount), 0)
+		FROM orders o
+		LEFT JOIN LATERAL (SELECT SUM(quantity) AS quantity FROM order_items WHERE order_id = o.id) oi ON true
+		WHERE o.status IN ('completed', 'partially_refunded', 'refunded')
+		AND ($1::timestamptz IS NULL OR o.created_at >= $1) AND ($2::timestamptz IS NULL OR o.created_at < $2)
+		GROUP BY 1
+		ORDER BY 1;`,
+		p.From, p.To, p.Interval,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sales stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := SalesStats{Periods: make([]SalesStatsPeriod, 0)}
+	for rows.Next() {
+		var period SalesStatsPeriod
+		if err = rows.Scan(&period.Start, &period.Orders, &period.ItemsPurchased, &period.Revenue, &period.RefundedAmount, &period.Discount); err != nil {
+			return nil, err
+		}
+		stats.Orders += period.Orders
+		stats.ItemsPurchased += period.ItemsPurchased
+		stats.Revenue += period.Revenue
+		stats.RefundedAmount += period.RefundedAmount
+		stats.Discount += period.Discount
+		stats.Periods = append(stats.Periods, period)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if stats.Orders > 0 {
+		stats.AverageOrderValue = stats.Revenue / stats.Orders
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM coupons WHERE ($1::timestamptz IS NULL OR created_at >= $1) AND ($2::timestamptz IS NULL OR created_at < $2)),
+			(SELECT COUNT(*) FROM coupon_redemptions WHERE ($1::timestamptz IS NULL OR created_at >= $1) AND ($2::timestamptz IS NULL OR created_at < $2));`,
+		p.From, p.To,
+	).Scan(&stats.CouponsIssued, &stats.CouponsRedeemed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupon stats: %w", err)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Kinds of store credit transactions.
+const (
+	StoreCreditIssue  = "issue"
+	StoreCreditRedeem = "redeem"
+	StoreCreditRefund = "refund"
+)
+
+// StoreCreditTransaction is an entry in the audit log of a store credit account. Amount is positive when the balance goes up.
+type StoreCreditTransaction struct {
+	OrderID      *int   `json:"orderId"`
+	RefundID     *int   `json:"refundId"`
+	CreatedBy    *int   `json:"createdBy"`
+	Kind         string `json:"kind"`
+	Note         string `json:"note"`
+	CreatedAt    string `json:"createdAt"`
+	ID           int    `json:"id"`
+	UserID       int    `json:"userId"`
+	Amount       int    `json:"amount"`
+	BalanceAfter int    `json:"balanceAfter"`
+}
+
+// GetStoreCreditBalance returns the store credit of the user. Users without an account have none.
+func (r *Repo) GetStoreCreditBalance(ctx context.Context, userID int) (int, error) {
+	var balance int
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE((SELECT balance FROM store_credit_accounts WHERE user_id=$1), 0);`, userID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get store credit: %w", err)
+	}
+	return balance, nil
+}
+
+// GetStoreCreditTransactions returns the audit log of the store credit account of the user, latest first.
+func (r *Repo) GetStoreCreditTransactions(ctx context.Context, userID int, page int, pageSize int) ([]StoreCreditTransaction, error) {
+	transactions := make([]StoreCreditTransaction, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, kind, amount, balance_after, order_id, refund_id, created_by, note, created_at FROM store_credit_transactions WHERE user_id=$1 ORDER BY id DESC LIMIT $2 OFFSET $3;`, userID, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t StoreCreditTransaction
+		if err = rows.Scan(&t.ID, &t.UserID, &t.Kind, &t.Amount, &t.BalanceAfter, &t.OrderID, &t.RefundID, &t.CreatedBy, &t.Note, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// IssueStoreCredit credits the account of the user, opening it if needed.
+func (r *Repo) IssueStoreCredit(ctx context.Context, userID int, amount int, issuedBy int, note string) (transaction *StoreCreditTransaction, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return changeStoreCredit(ctx, tx, &StoreCreditTransaction{
+		UserID:    userID,
+		Kind:      StoreCreditIssue,
+		Amount:    amount,
+		CreatedBy: &issuedBy,
+		Note:      note,
+	})
+}
+
+// lockStoreCredit returns the store credit of the user, locking their account until the end of the transaction.
+func lockStoreCredit(ctx context.Context, q querier, userID int) (int, error) {
+	var balance int
+	err := q.QueryRowContext(ctx, `SELECT COALESCE((SELECT balance FROM store_credit_accounts WHERE user_id=$1 FOR UPDATE), 0);`, userID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get store credit: %w", err)
+	}
+	return balance, nil
+}
+
+// changeStoreCredit moves the balance of the account of the user by the amount of the transaction, and records it. The account is opened if needed. A debit that would take the balance below zero fails.
+func changeStoreCredit(ctx context.Context, q querier, t *StoreCreditTransaction) (*StoreCreditTransaction, error) {
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO store_credit_accounts(user_id, balance) VALUES($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET balance = store_credit_accounts.balance + EXCLUDED.balance
+		RETURNING balance;`,
+		t.UserID, t.Amount,
+	).Scan(&t.BalanceAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update store credit: %w", err)
+	}
+	err = q.QueryRowContext(ctx,
+		`INSERT INTO store_credit_transactions(user_id, kind, amount, balance_after, order_id, refund_id, created_by, note)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id, created_at;`,
+		t.UserID, t.Kind, t.Amount, t.BalanceAfter, t.OrderID, t.RefundID, t.CreatedBy, t.Note,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record store credit transaction: %w", err)
+	}
+	return t, nil
+}
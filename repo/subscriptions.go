@@ -0,0 +1,256 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrSubscriptionNotFound      = errors.New("subscription not found")
+	ErrSubscriptionStatusInvalid = errors.New("subscription can't be changed in its status")
+)
+
+const (
+	SubscriptionActive    = "active"
+	SubscriptionPaused    = "paused"
+	SubscriptionCancelled = "cancelled"
+)
+
+type Subscription struct {
+	Status string `json:"status"`
+	// NextOrderAt is when the next order is due. Paused subscriptions catch up from the time they are resumed.
+	NextOrderAt string `json:"nextOrderAt"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+	// LastOrderID is the order the subscription placed last.
+	LastOrderID  *int               `json:"lastOrderId"`
+	Items        []SubscriptionItem `json:"items"`
+	ID           int                `json:"id"`
+	UserID       int                `json:"userId"`
+	IntervalDays int                `json:"intervalDays"`
+}
+
+type SubscriptionItem struct {
+	ProductName string `json:"productName"`
+	ProductID   int    `json:"productId"`
+	Quantity    int    `json:"quantity"`
+}
+
+const subscriptionColumns = `id, user_id, interval_days, status, next_order_at, last_order_id, created_at, updated_at`
+
+func scanSubscription(row interface{ Scan(...any) error }, s *Subscription) error {
+	return row.Scan(&s.ID, &s.UserID, &s.IntervalDays, &s.Status, &s.NextOrderAt, &s.LastOrderID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// advancedNextOrderAt is the time of the order after the next one. If that is in the past too, e.g. after the subscription was paused for a while, it is a whole interval from now instead, so that missed orders are not placed all at once.
+const advancedNextOrderAt = `CASE
+	WHEN next_order_at + interval_days * INTERVAL '1 day' > current_timestamp THEN next_order_at + interval_days * INTERVAL '1 day'
+	ELSE current_timestamp + interval_days * INTERVAL '1 day'
+END`
+
+type CreateSubscriptionParams struct {
+	FirstOrderAt time.Time
+	UserID       int
+	IntervalDays int
+}
+
+// CreateSubscription subscribes the user to the items in their cart, as they are now.
+func (r *Repo) CreateSubscription(ctx context.Context, p *CreateSubscriptionParams) (subscription *Subscription, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	subscription = &Subscription{}
+	err = scanSubscription(tx.QueryRowContext(ctx,
+		`INSERT INTO subscriptions(user_id, interval_days, next_order_at) VALUES($1, $2, $3) RETURNING `+subscriptionColumns+`;`,
+		p.UserID, p.IntervalDays, p.FirstOrderAt,
+	), subscription)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO subscription_items(subscription_id, product_id, quantity) SELECT $1, product_id, quantity FROM cart_items WHERE user_id = $2;`,
+		subscription.ID, p.UserID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription items: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	if err = getSubscriptionItems(ctx, tx, []*Subscription{subscription}); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// GetSubscriptions returns the subscriptions of the user that are not cancelled, with their items.
+func (r *Repo) GetSubscriptions(ctx context.Context, userID int) ([]Subscription, error) {
+	return r.getSubscriptions(ctx, `user_id = $1 AND status <> $2 ORDER BY id`, userID, SubscriptionCancelled)
+}
+
+// GetSubscription returns a subscription of the user with its items.
+func (r *Repo) GetSubscription(ctx context.Context, userID int, id int) (*Subscription, error) {
+	subscriptions, err := r.getSubscriptions(ctx, `id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+	return &subscriptions[0], nil
+}
+
+// GetDueSubscriptions returns up to 'limit' active subscriptions whose next order is due, with their items.
+func (r *Repo) GetDueSubscriptions(ctx context.Context, limit int) ([]Subscription, error) {
+	return r.getSubscriptions(ctx, `status = $1 AND next_order_at <= current_timestamp ORDER BY next_order_at LIMIT $2`, SubscriptionActive, limit)
+}
+
+func (r *Repo) getSubscriptions(ctx context.Context, condition string, args ...any) ([]Subscription, error) {
+	subscriptions := make([]Subscription, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE `+condition+`;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subscription Subscription
+		if err = scanSubscription(rows, &subscription); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pointers := make([]*Subscription, len(subscriptions))
+	for i := range subscriptions {
+		pointers[i] = &subscriptions[i]
+	}
+	if err = getSubscriptionItems(ctx, r.db, pointers); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// getSubscriptionItems sets the items of the subscriptions, with a single query for all of them.
+func getSubscriptionItems(ctx context.Context, q querier, subscriptions []*Subscription) error {
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	ids := make([]int, len(subscriptions))
+	byID := make(map[int]*Subscription, len(subscriptions))
+	for i, subscription := range subscriptions {
+		ids[i] = subscription.ID
+		byID[subscription.ID] = subscription
+		subscription.Items = make([]SubscriptionItem, 0)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT si.subscription_id, si.product_id, p.name, si.quantity
+		FROM subscription_items si
+		JOIN products p ON p.id = si.product_id
+		WHERE si.subscription_id = ANY($1)
+		ORDER BY si.id;`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subscriptionID int
+		var item SubscriptionItem
+		if err = rows.Scan(&subscriptionID, &item.ProductID, &item.ProductName, &item.Quantity); err != nil {
+			return err
+		}
+		byID[subscriptionID].Items = append(byID[subscriptionID].Items, item)
+	}
+	return rows.Err()
+}
+
+// PauseSubscription stops the subscription from placing orders until it is resumed.
+func (r *Repo) PauseSubscription(ctx context.Context, userID int, id int) (*Subscription, error) {
+	return r.updateSubscription(ctx, userID, id, `status = '`+SubscriptionPaused+`'`, SubscriptionActive)
+}
+
+// ResumeSubscription resumes a paused subscription. If its next order was due while it was paused, it is placed a whole interval from now.
+func (r *Repo) ResumeSubscription(ctx context.Context, userID int, id int) (*Subscription, error) {
+	return r.updateSubscription(ctx, userID, id, `status = '`+SubscriptionActive+`', next_order_at = CASE
+		WHEN next_order_at > current_timestamp THEN next_order_at
+		ELSE current_timestamp + interval_days * INTERVAL '1 day'
+	END`, SubscriptionPaused)
+}
+
+// SkipSubscriptionOrder skips the next order of the subscription.
+func (r *Repo) SkipSubscriptionOrder(ctx context.Context, userID int, id int) (*Subscription, error) {
+	return r.updateSubscription(ctx, userID, id, `next_order_at = `+advancedNextOrderAt, SubscriptionActive, SubscriptionPaused)
+}
+
+// CancelSubscription cancels the subscription for good.
+func (r *Repo) CancelSubscription(ctx context.Context, userID int, id int) (*Subscription, error) {
+	return r.updateSubscription(ctx, userID, id, `status = '`+SubscriptionCancelled+`'`, SubscriptionActive, SubscriptionPaused)
+}
+
+// updateSubscription applies the assignments to a subscription of the user, if it is in one of the given statuses.
+func (r *Repo) updateSubscription(ctx context.Context, userID int, id int, assignments string, statuses ...string) (*Subscription, error) {
+	var subscription Subscription
+	err := scanSubscription(r.db.QueryRowContext(ctx,
+		`UPDATE subscriptions SET `+assignments+` WHERE id = $1 AND user_id = $2 AND status = ANY($3) RETURNING `+subscriptionColumns+`;`,
+		id, userID, statuses,
+	), &subscription)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to update subscription: %w", err)
+		}
+		// Either there is no such subscription, or it is in another status
+		if _, err = r.GetSubscription(ctx, userID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrSubscriptionStatusInvalid
+	}
+
+	if err = getSubscriptionItems(ctx, r.db, []*Subscription{&subscription}); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// ClaimSubscriptionOrder moves the next order of a due subscription one interval on, and reports whether it did. Only the caller that claims the order places it, so that it is placed once even if several schedulers run.
+func (r *Repo) ClaimSubscriptionOrder(ctx context.Context, id int) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE subscriptions SET next_order_at = `+advancedNextOrderAt+` WHERE id = $1 AND status = $2 AND next_order_at <= current_timestamp;`,
+		id, SubscriptionActive,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim subscription order: %w", err)
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// SetSubscriptionLastOrder records the order the subscription placed.
+func (r *Repo) SetSubscriptionLastOrder(ctx context.Context, id int, orderID int) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE subscriptions SET last_order_id = $1 WHERE id = $2;`, orderID, id); err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return nil
+}
@@ -113,3 +113,30 @@ func (r *Repo) SetIsVerified(ctx context.Context, id int, isVerified bool) error
 	_, err := r.db.ExecContext(ctx, `UPDATE users SET is_verified=$1 WHERE id=$2;`, isVerified, id)
 	return err
 }
+
+// GetUserEmailsByRole returns the emails of all active users with the given role.
+func (r *Repo) GetUserEmailsByRole(ctx context.Context, role string) ([]string, error) {
+	emails := make([]string, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT email FROM users WHERE role=$1 AND account_status='active' ORDER BY id;`, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email string
+		if err = rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, nil
+}
+
+// lockUser locks the user until the end of the transaction, so that their checkouts run one after the other.
+func lockUser(ctx context.Context, q querier, userID int) error {
+	if _, err := q.ExecContext(ctx, `SELECT id FROM users WHERE id=$1 FOR UPDATE;`, userID); err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"context"
+)
+
+// StockNotification is a request from a user to be emailed when an out of stock product is back in stock.
+type StockNotification struct {
+	UserEmail string `json:"userEmail"`
+	CreatedAt string `json:"createdAt"`
+	ID        int    `json:"id"`
+	UserID    int    `json:"userId"`
+	ProductID int    `json:"productId"`
+}
+
+// SubscribeToStockNotification adds the user to the waitlist of a product. Subscribing twice is a no-op.
+func (r *Repo) SubscribeToStockNotification(ctx context.Context, userID int, productID int) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO stock_notifications(user_id, product_id) VALUES($1, $2) ON CONFLICT (user_id, product_id) DO NOTHING;`, userID, productID)
+	return err
+}
+
+func (r *Repo) UnsubscribeFromStockNotification(ctx context.Context, userID int, productID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM stock_notifications WHERE user_id=$1 AND product_id=$2;`, userID, productID)
+	return err
+}
+
+// GetStockNotifications returns up to 'limit' subscribers of a product with an ID greater than 'afterID', so that the waitlist can be walked in batches.
+func (r *Repo) GetStockNotifications(ctx context.Context, productID int, afterID int, limit int) ([]StockNotification, error) {
+	notifications := make([]StockNotification, 0)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT sn.id, sn.user_id, sn.product_id, u.email, sn.created_at
+		FROM stock_notifications sn
+		JOIN users u ON u.id = sn.user_id
+		WHERE sn.product_id = $1 AND sn.id > $2
+		ORDER BY sn.id
+		LIMIT $3;`, productID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n StockNotification
+		err = rows.Scan(&n.ID, &n.UserID, &n.ProductID, &n.UserEmail, &n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+func (r *Repo) DeleteStockNotifications(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, `DELETE FROM stock_notifications WHERE id = ANY($1);`, ids)
+	return err
+}
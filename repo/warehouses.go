@@ -0,0 +1,430 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rohitxdev/go-api-starter/allocation"
+)
+
+var (
+	ErrWarehouseNotFound      = errors.New("warehouse not found")
+	ErrWarehouseAlreadyExists = errors.New("warehouse already exists")
+)
+
+type Warehouse struct {
+	Name      string  `json:"name"`
+	CreatedAt string  `json:"createdAt"`
+	UpdatedAt string  `json:"updatedAt"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	ID        int     `json:"id"`
+	// Quantity is the stock of all products in the warehouse.
+	Quantity int `json:"quantity"`
+	// IsDefault is set for the warehouse that takes the stock products are created with, and stock that is restocked without a warehouse of its own.
+	IsDefault bool `json:"isDefault"`
+}
+
+const warehouseColumns = `id, name, latitude, longitude, is_default, COALESCE((SELECT SUM(ws.quantity) FROM warehouse_stock ws WHERE ws.warehouse_id = warehouses.id), 0), created_at, updated_at`
+
+func scanWarehouse(row interface{ Scan(...any) error }, w *Warehouse) error {
+	return row.Scan(&w.ID, &w.Name, &w.Latitude, &w.Longitude, &w.IsDefault, &w.Quantity, &w.CreatedAt, &w.UpdatedAt)
+}
+
+// WarehouseStock is the stock of a product in a warehouse.
+type WarehouseStock struct {
+	WarehouseName string `json:"warehouseName"`
+	ProductName   string `json:"productName"`
+	UpdatedAt     string `json:"updatedAt"`
+	WarehouseID   int    `json:"warehouseId"`
+	ProductID     int    `json:"productId"`
+	Quantity      int    `json:"quantity"`
+}
+
+type StockTransfer struct {
+	Reason          string `json:"reason"`
+	CreatedAt       string `json:"createdAt"`
+	ID              int    `json:"id"`
+	ProductID       int    `json:"productId"`
+	FromWarehouseID int    `json:"fromWarehouseId"`
+	ToWarehouseID   int    `json:"toWarehouseId"`
+	Quantity        int    `json:"quantity"`
+	CreatedBy       int    `json:"createdBy"`
+}
+
+// OrderAllocation is the quantity of an order item that ships from a warehouse.
+type OrderAllocation struct {
+	WarehouseName string `json:"warehouseName"`
+	OrderItemID   int    `json:"orderItemId"`
+	ProductID     int    `json:"productId"`
+	WarehouseID   int    `json:"warehouseId"`
+	Quantity      int    `json:"quantity"`
+}
+
+type CreateWarehouseParams struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+// CreateWarehouse adds a warehouse without any stock. Names are unique.
+func (r *Repo) CreateWarehouse(ctx context.Context, p *CreateWarehouseParams) (*Warehouse, error) {
+	var warehouse Warehouse
+	err := scanWarehouse(r.db.QueryRowContext(ctx,
+		`INSERT INTO warehouses(name, latitude, longitude) VALUES($1, $2, $3)
+		 ON CONFLICT (name) DO NOTHING
+		 RETURNING `+warehouseColumns+`;`,
+		p.Name, p.Latitude, p.Longitude,
+	), &warehouse)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWarehouseAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create warehouse: %w", err)
+	}
+	return &warehouse, nil
+}
+
+type UpdateWarehouseParams struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+	ID        int
+	// MakeDefault makes the warehouse the default one in place of the current one.
+	MakeDefault bool
+}
+
+// UpdateWarehouse renames or moves a warehouse, and makes it the default one if asked to.
+func (r *Repo) UpdateWarehouse(ctx context.Context, p *UpdateWarehouseParams) (warehouse *Warehouse, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if p.MakeDefault {
+		// There can only be one default warehouse at a time
+		if _, err = tx.ExecContext(ctx, `UPDATE warehouses SET is_default = FALSE WHERE is_default AND id <> $1;`, p.ID); err != nil {
+			return nil, fmt.Errorf("failed to update default warehouse: %w", err)
+		}
+	}
+
+	warehouse = &Warehouse{}
+	err = scanWarehouse(tx.QueryRowContext(ctx,
+		`UPDATE warehouses SET name = $1, latitude = $2, longitude = $3, is_default = is_default OR $4
+		 WHERE id = $5 AND NOT EXISTS (SELECT 1 FROM warehouses w WHERE w.name = $1 AND w.id <> $5)
+		 RETURNING `+warehouseColumns+`;`,
+		p.Name, p.Latitude, p.Longitude, p.MakeDefault, p.ID,
+	), warehouse)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to update warehouse: %w", err)
+		}
+		// Either there is no such warehouse, or another one has the name
+		if _, err = getWarehouseID(ctx, tx, &p.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrWarehouseAlreadyExists
+	}
+	return warehouse, nil
+}
+
+// GetWarehouses returns every warehouse with the stock in it, the default one first.
+func (r *Repo) GetWarehouses(ctx context.Context) ([]Warehouse, error) {
+	warehouses := make([]Warehouse, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+warehouseColumns+` FROM warehouses ORDER BY is_default DESC, id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var warehouse Warehouse
+		if err = scanWarehouse(rows, &warehouse); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	return warehouses, rows.Err()
+}
+
+// GetWarehouseStock returns the products in stock in a warehouse, most stock first.
+func (r *Repo) GetWarehouseStock(ctx context.Context, warehouseID int, page int, pageSize int) ([]WarehouseStock, error) {
+	if _, err := getWarehouseID(ctx, r.db, &warehouseID); err != nil {
+		return nil, err
+	}
+	return r.getWarehouseStock(ctx, `ws.warehouse_id = $1 AND ws.quantity > 0 ORDER BY ws.quantity DESC, ws.product_id LIMIT $2 OFFSET $3`, warehouseID, pageSize, page*pageSize)
+}
+
+// GetProductStock returns the stock of a product in each warehouse that has had it, the default warehouse first.
+func (r *Repo) GetProductStock(ctx context.Context, productID int) ([]WarehouseStock, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT TRUE FROM products WHERE id = $1;`, productID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	return r.getWarehouseStock(ctx, `ws.product_id = $1 ORDER BY w.is_default DESC, w.id`, productID)
+}
+
+func (r *Repo) getWarehouseStock(ctx context.Context, condition string, args ...any) ([]WarehouseStock, error) {
+	stock := make([]WarehouseStock, 0)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ws.warehouse_id, w.name, ws.product_id, p.name, ws.quantity, ws.updated_at
+		FROM warehouse_stock ws
+		JOIN warehouses w ON w.id = ws.warehouse_id
+		JOIN products p ON p.id = ws.product_id
+		WHERE `+condition+`;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s WarehouseStock
+		if err = rows.Scan(&s.WarehouseID, &s.WarehouseName, &s.ProductID, &s.ProductName, &s.Quantity, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+	return stock, rows.Err()
+}
+
+type TransferStockParams struct {
+	Reason          string
+	ProductID       int
+	FromWarehouseID int
+	ToWarehouseID   int
+	Quantity        int
+	ActorID         int
+}
+
+// TransferStock moves stock of a product from one warehouse to another. The stock of the product across warehouses stays the same, but both sides of the transfer are recorded in the inventory ledger.
+func (r *Repo) TransferStock(ctx context.Context, p *TransferStockParams) (transfer *StockTransfer, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for _, id := range []int{p.FromWarehouseID, p.ToWarehouseID} {
+		if _, err = getWarehouseID(ctx, tx, &id); err != nil {
+			return nil, err
+		}
+	}
+	if err = lockProduct(ctx, tx, p.ProductID); err != nil {
+		return nil, err
+	}
+	quantity, err := getWarehouseQuantity(ctx, tx, p.FromWarehouseID, p.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if quantity < p.Quantity {
+		return nil, ErrInsufficientStock
+	}
+
+	var quantityLeft int
+	if err = tx.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id = $1;`, p.ProductID).Scan(&quantityLeft); err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	for _, side := range []struct{ warehouseID, quantity int }{{p.FromWarehouseID, -p.Quantity}, {p.ToWarehouseID, p.Quantity}} {
+		if err = changeWarehouseStock(ctx, tx, side.warehouseID, p.ProductID, side.quantity); err != nil {
+			return nil, err
+		}
+		err = recordMovement(ctx, tx, &InventoryMovement{
+			ProductID:     p.ProductID,
+			Kind:          MovementTransfer,
+			Quantity:      side.quantity,
+			QuantityAfter: &quantityLeft,
+			WarehouseID:   &side.warehouseID,
+			ActorID:       &p.ActorID,
+			Reason:        p.Reason,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	transfer = &StockTransfer{}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO stock_transfers(product_id, from_warehouse_id, to_warehouse_id, quantity, reason, created_by)
+		 VALUES($1, $2, $3, $4, $5, $6)
+		 RETURNING id, product_id, from_warehouse_id, to_warehouse_id, quantity, reason, created_by, created_at`,
+		p.ProductID, p.FromWarehouseID, p.ToWarehouseID, p.Quantity, p.Reason, p.ActorID,
+	).Scan(&transfer.ID, &transfer.ProductID, &transfer.FromWarehouseID, &transfer.ToWarehouseID, &transfer.Quantity, &transfer.Reason, &transfer.CreatedBy, &transfer.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stock transfer: %w", err)
+	}
+	return transfer, nil
+}
+
+// GetStockTransfers returns the stock transfers, of a single product if productID is not nil, latest first.
+func (r *Repo) GetStockTransfers(ctx context.Context, productID *int, page int, pageSize int) ([]StockTransfer, error) {
+	transfers := make([]StockTransfer, 0)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, product_id, from_warehouse_id, to_warehouse_id, quantity, reason, created_by, created_at
+		FROM stock_transfers
+		WHERE $1::BIGINT IS NULL OR product_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3;`, productID, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t StockTransfer
+		if err = rows.Scan(&t.ID, &t.ProductID, &t.FromWarehouseID, &t.ToWarehouseID, &t.Quantity, &t.Reason, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+// GetOrderAllocations returns the warehouses that the items of an order ship from.
+func (r *Repo) GetOrderAllocations(ctx context.Context, orderID int) ([]OrderAllocation, error) {
+	allocations := make([]OrderAllocation, 0)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT oa.order_item_id, oi.product_id, oa.warehouse_id, w.name, oa.quantity
+		FROM order_allocations oa
+		JOIN order_items oi ON oi.id = oa.order_item_id
+		JOIN warehouses w ON w.id = oa.warehouse_id
+		WHERE oi.order_id = $1
+		ORDER BY oa.order_item_id, oa.id;`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a OrderAllocation
+		if err = rows.Scan(&a.OrderItemID, &a.ProductID, &a.WarehouseID, &a.WarehouseName, &a.Quantity); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, rows.Err()
+}
+
+// allocateStock sells the quantity of an order item from the warehouses that the strategy picks, and records where it ships from. The caller should hold a lock on the product.
+func allocateStock(ctx context.Context, q querier, item *OrderItem, userID int, strategy allocation.Strategy, destination *allocation.Location) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT ws.warehouse_id, ws.quantity, w.latitude, w.longitude
+		FROM warehouse_stock ws
+		JOIN warehouses w ON w.id = ws.warehouse_id
+		WHERE ws.product_id = $1 AND ws.quantity > 0;`, item.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to get warehouse stock: %w", err)
+	}
+	stock := make([]allocation.Stock, 0)
+	for rows.Next() {
+		var s allocation.Stock
+		if err = rows.Scan(&s.WarehouseID, &s.Quantity, &s.Location.Latitude, &s.Location.Longitude); err != nil {
+			rows.Close()
+			return err
+		}
+		stock = append(stock, s)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	allocated := 0
+	for _, a := range allocation.Allocate(stock, item.Quantity, strategy, destination) {
+		err = moveStock(ctx, q, &InventoryMovement{
+			ProductID:   item.ProductID,
+			Kind:        MovementSale,
+			Quantity:    -a.Quantity,
+			WarehouseID: &a.WarehouseID,
+			ActorID:     &userID,
+			OrderID:     &item.OrderID,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(ctx,
+			`INSERT INTO order_allocations(order_item_id, warehouse_id, quantity) VALUES($1, $2, $3)`,
+			item.ID, a.WarehouseID, a.Quantity,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create order allocation: %w", err)
+		}
+		allocated += a.Quantity
+	}
+	if allocated < item.Quantity {
+		return fmt.Errorf("%w in warehouses for product %d", ErrInsufficientStock, item.ProductID)
+	}
+	return nil
+}
+
+// restockWarehouseID returns the warehouse that refunded quantities of an order item go back to: the one that shipped most of it, or the default warehouse if none is on record.
+func restockWarehouseID(ctx context.Context, q querier, orderItemID int) (int, error) {
+	var warehouseID int
+	err := q.QueryRowContext(ctx,
+		`SELECT warehouse_id FROM order_allocations WHERE order_item_id = $1 ORDER BY quantity DESC, id LIMIT 1;`,
+		orderItemID,
+	).Scan(&warehouseID)
+	if err == sql.ErrNoRows {
+		return getWarehouseID(ctx, q, nil)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get order allocation: %w", err)
+	}
+	return warehouseID, nil
+}
+
+// getWarehouseID checks that the warehouse exists and returns its ID, or the ID of the default warehouse if id is nil.
+func getWarehouseID(ctx context.Context, q querier, id *int) (int, error) {
+	var warehouseID int
+	err := q.QueryRowContext(ctx, `SELECT id FROM warehouses WHERE CASE WHEN $1::BIGINT IS NULL THEN is_default ELSE id = $1 END;`, id).Scan(&warehouseID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrWarehouseNotFound
+		}
+		return 0, fmt.Errorf("failed to get warehouse: %w", err)
+	}
+	return warehouseID, nil
+}
+
+// getWarehouseQuantity returns the stock of a product in a warehouse.
+func getWarehouseQuantity(ctx context.Context, q querier, warehouseID int, productID int) (int, error) {
+	var quantity int
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE((SELECT quantity FROM warehouse_stock WHERE warehouse_id = $1 AND product_id = $2), 0);`,
+		warehouseID, productID,
+	).Scan(&quantity)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get warehouse stock: %w", err)
+	}
+	return quantity, nil
+}
+
+// lockProduct locks the product until the end of the transaction. Every change to the stock of a product, in any warehouse, happens under this lock.
+func lockProduct(ctx context.Context, q querier, productID int) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, `SELECT TRUE FROM products WHERE id = $1 FOR UPDATE;`, productID).Scan(&exists)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to get product: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrWishlistNotFound      = errors.New("wishlist not found")
+	ErrWishlistAlreadyExists = errors.New("wishlist already exists")
+	ErrWishlistItemNotFound  = errors.New("wishlist item not found")
+)
+
+type Wishlist struct {
+	// ShareToken lets anyone with the link view the wishlist. It is nil if the wishlist is not shared.
+	ShareToken *string        `json:"shareToken,omitempty"`
+	Name       string         `json:"name"`
+	CreatedAt  string         `json:"createdAt"`
+	UpdatedAt  string         `json:"updatedAt"`
+	Items      []WishlistItem `json:"items,omitempty"`
+	ID         int            `json:"id"`
+	UserID     int            `json:"userId"`
+}
+
+type WishlistItem struct {
+	ProductName string `json:"productName"`
+	CreatedAt   string `json:"createdAt"`
+	ID          int    `json:"id"`
+	WishlistID  int    `json:"wishlistId"`
+	ProductID   int    `json:"productId"`
+	// Price is the current price of the product.
+	Price          int  `json:"price"`
+	PriceWhenAdded int  `json:"priceWhenAdded"`
+	IsPriceDropped bool `json:"isPriceDropped"`
+	IsInStock      bool `json:"isInStock"`
+}
+
+// CreateWishlist creates a named wishlist. Names are unique per user.
+func (r *Repo) CreateWishlist(ctx context.Context, userID int, name string) (*Wishlist, error) {
+	var wishlist Wishlist
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO wishlists(user_id, name) VALUES($1, $2)
+		 ON CONFLICT (user_id, name) DO NOTHING
+		 RETURNING id, user_id, name, share_token, created_at, updated_at;`,
+		userID, name,
+	).Scan(&wishlist.ID, &wishlist.UserID, &wishlist.Name, &wishlist.ShareToken, &wishlist.CreatedAt, &wishlist.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWishlistAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create wishlist: %w", err)
+	}
+	return &wishlist, nil
+}
+
+// GetWishlists returns the wishlists of the user without their items.
+func (r *Repo) GetWishlists(ctx context.Context, userID int) ([]Wishlist, error) {
+	wishlists := make([]Wishlist, 0)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, name, share_token, created_at, updated_at FROM wishlists WHERE user_id=$1 ORDER BY created_at;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var wishlist Wishlist
+		err = rows.Scan(&wishlist.ID, &wishlist.UserID, &wishlist.Name, &wishlist.ShareToken, &wishlist.CreatedAt, &wishlist.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		wishlists = append(wishlists, wishlist)
+	}
+	return wishlists, nil
+}
+
+// GetWishlist returns a wishlist of the user with its items.
+func (r *Repo) GetWishlist(ctx context.Context, userID int, id int) (*Wishlist, error) {
+	return r.getWishlist(ctx, `id=$1 AND user_id=$2`, id, userID)
+}
+
+// GetSharedWishlist returns the wishlist with the given share token, with its items.
+func (r *Repo) GetSharedWishlist(ctx context.Context, shareToken string) (*Wishlist, error) {
+	return r.getWishlist(ctx, `share_token=$1`, shareToken)
+}
+
+func (r *Repo) getWishlist(ctx context.Context, condition string, args ...any) (*Wishlist, error) {
+	var wishlist Wishlist
+	err := r.db.QueryRowContext(ctx, `SELECT id, user_id, name, share_token, created_at, updated_at FROM wishlists WHERE `+condition+` LIMIT 1;`, args...).Scan(&wishlist.ID, &wishlist.UserID, &wishlist.Name, &wishlist.ShareToken, &wishlist.CreatedAt, &wishlist.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWishlistNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT wi.id, wi.wishlist_id, wi.product_id, p.name, p.price, wi.price_when_added, `+quantityAvailableColumn+`, wi.created_at
+		FROM wishlist_items wi
+		JOIN products p ON p.id = wi.product_id
+		WHERE wi.wishlist_id = $1
+		ORDER BY wi.created_at DESC;`, wishlist.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wishlist.Items = make([]WishlistItem, 0)
+	for rows.Next() {
+		var item WishlistItem
+		var quantityAvailable int
+		err = rows.Scan(&item.ID, &item.WishlistID, &item.ProductID, &item.ProductName, &item.Price, &item.PriceWhenAdded, &quantityAvailable, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		item.IsPriceDropped = item.Price < item.PriceWhenAdded
+		item.IsInStock = quantityAvailable > 0
+		wishlist.Items = append(wishlist.Items, item)
+	}
+	return &wishlist, nil
+}
+
+func (r *Repo) DeleteWishlist(ctx context.Context, userID int, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM wishlists WHERE id=$1 AND user_id=$2;`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWishlistNotFound
+	}
+	return nil
+}
+
+// SetWishlistShareToken shares the wishlist under the given token, or stops sharing it if the token is nil.
+func (r *Repo) SetWishlistShareToken(ctx context.Context, userID int, id int, shareToken *string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE wishlists SET share_token=$1 WHERE id=$2 AND user_id=$3;`, shareToken, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWishlistNotFound
+	}
+	return nil
+}
+
+// lockWishlist checks that the wishlist belongs to the user and locks it for the rest of the transaction.
+func lockWishlist(ctx context.Context, q querier, userID int, id int) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, `SELECT TRUE FROM wishlists WHERE id=$1 AND user_id=$2 FOR UPDATE;`, id, userID).Scan(&exists)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrWishlistNotFound
+		}
+		return fmt.Errorf("failed to get wishlist: %w", err)
+	}
+	return nil
+}
+
+// addToWishlist puts a product in a wishlist at its current price. Adding a product that is already in the wishlist leaves it as is.
+func addToWishlist(ctx context.Context, q querier, wishlistID int, productID int) error {
+	res, err := q.ExecContext(ctx,
+		`INSERT INTO wishlist_items(wishlist_id, product_id, price_when_added)
+		 SELECT $1, id, price FROM products WHERE id = $2
+		 ON CONFLICT (wishlist_id, product_id) DO NOTHING;`,
+		wishlistID, productID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add to wishlist: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Either the product doesn't exist or it is already in the wishlist.
+		var exists bool
+		if err = q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id=$1);`, productID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrProductNotFound
+		}
+	}
+	return nil
+}
+
+func (r *Repo) AddToWishlist(ctx context.Context, userID int, wishlistID int, productID int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = lockWishlist(ctx, tx, userID, wishlistID); err != nil {
+		return err
+	}
+	return addToWishlist(ctx, tx, wishlistID, productID)
+}
+
+func (r *Repo) RemoveFromWishlist(ctx context.Context, userID int, wishlistID int, productID int) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM wishlist_items wi USING wishlists w
+		 WHERE wi.wishlist_id = w.id AND w.id = $1 AND w.user_id = $2 AND wi.product_id = $3;`,
+		wishlistID, userID, productID,
+	)
+	return err
+}
+
+// MoveWishlistItemToCart takes a product off the wishlist and puts one of it in the cart, if it is in stock.
+func (r *Repo) MoveWishlistItemToCart(ctx context.Context, userID int, wishlistID int, productID int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = lockWishlist(ctx, tx, userID, wishlistID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM wishlist_items WHERE wishlist_id=$1 AND product_id=$2;`, wishlistID, productID)
+	if err != nil {
+		return fmt.Errorf("failed to delete wishlist item: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWishlistItemNotFound
+	}
+
+	var quantityLeft, held int
+	err = tx.QueryRowContext(ctx, `SELECT quantity_left FROM products WHERE id=$1 FOR UPDATE;`, productID).Scan(&quantityLeft)
+	if err != nil {
+		return fmt.Errorf("failed to check product quantity: %w", err)
+	}
+	if held, err = heldByOthers(ctx, tx, productID, userID); err != nil {
+		return err
+	}
+	if quantityLeft-held <= 0 {
+		return ErrInsufficientStock
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO cart_items(user_id, product_id, quantity) VALUES($1, $2, $3) ON CONFLICT (user_id, product_id) DO NOTHING;`, userID, productID, 1)
+	return err
+}
+
+// MoveCartItemToWishlist saves a product in the cart for later by moving it to a wishlist.
+func (r *Repo) MoveCartItemToWishlist(ctx context.Context, userID int, productID int, wishlistID int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = lockWishlist(ctx, tx, userID, wishlistID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM cart_items WHERE user_id=$1 AND product_id=$2;`, userID, productID)
+	if err != nil {
+		return fmt.Errorf("failed to delete cart item: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCartItemNotFound
+	}
+	return addToWishlist(ctx, tx, wishlistID, productID)
+}